@@ -0,0 +1,66 @@
+package iota
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// accessRule allows or denies the clients of a network.
+type accessRule struct {
+	allow   bool
+	network *net.IPNet
+}
+
+// parseAccessRule parses the CIDR of an allow or deny option. A single IP is treated
+// as a network containing only that IP.
+func parseAccessRule(allow bool, cidr string) (accessRule, error) {
+	if !strings.Contains(cidr, "/") {
+		if ip := net.ParseIP(cidr); ip != nil && ip.To4() != nil {
+			cidr += "/32"
+		} else {
+			cidr += "/128"
+		}
+	}
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return accessRule{}, err
+	}
+	return accessRule{allow: allow, network: network}, nil
+}
+
+// checkAdminAccess rejects requests to the admin endpoints from IPs outside of the
+// admin networks with a 403.
+func (interc *Interceptor) checkAdminAccess(r *http.Request) (int, error) {
+	if len(interc.Config.AdminAllowedIPs) == 0 {
+		return 0, nil
+	}
+	if ip := interc.clientIP(r); !trustedIP(ip, interc.Config.AdminAllowedIPs) {
+		interc.logEntry(levelWarn, "admin_access_denied", logFields{RemoteAddr: ip}, "denying %s request to admin endpoint %s from %s\n", r.Method, r.URL.Path, ip)
+		return http.StatusForbidden, ErrAccessDenied
+	}
+	return 0, nil
+}
+
+// accessAllowed reports whether the rules let the given IP in. The last matching rule wins
+// and IPs matching no rule are only allowed if there are no allow rules. Unparsable IPs
+// are only allowed without rules.
+func accessAllowed(rules []accessRule, ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return len(rules) == 0
+	}
+	allowed := true
+	for _, rule := range rules {
+		if rule.allow {
+			allowed = false
+			break
+		}
+	}
+	for _, rule := range rules {
+		if rule.network.Contains(parsed) {
+			allowed = rule.allow
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,94 @@
+package iota
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAccessAllowed(t *testing.T) {
+	rule := func(allow bool, cidr string) accessRule {
+		r, err := parseAccessRule(allow, cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+	allowLAN := rule(true, "10.0.0.0/8")
+	denyHost := rule(false, "10.0.0.13")
+	denyAll := rule(false, "0.0.0.0/0")
+	for _, test := range []struct {
+		name    string
+		rules   []accessRule
+		ip      string
+		allowed bool
+	}{
+		{"no rules", nil, "1.1.1.1", true},
+		{"allowed network", []accessRule{allowLAN}, "10.1.2.3", true},
+		{"outside of allow list", []accessRule{allowLAN}, "1.1.1.1", false},
+		{"denied host within allowed network", []accessRule{allowLAN, denyHost}, "10.0.0.13", false},
+		{"other host within allowed network", []accessRule{allowLAN, denyHost}, "10.0.0.14", true},
+		{"last rule wins", []accessRule{denyHost, allowLAN}, "10.0.0.13", true},
+		{"deny list only", []accessRule{denyHost}, "1.1.1.1", true},
+		{"deny all but the network", []accessRule{denyAll, allowLAN}, "10.0.0.1", true},
+		{"IPv6 outside of allow list", []accessRule{allowLAN, rule(true, "fd00::/8")}, "2001:db8::1", false},
+		{"IPv6 host", []accessRule{rule(false, "2001:db8::1")}, "2001:db8::1", false},
+		{"unparsable IP with rules", []accessRule{denyHost}, "unix", false},
+	} {
+		if allowed := accessAllowed(test.rules, test.ip); allowed != test.allowed {
+			t.Errorf("%s: expected %s to be allowed=%v", test.name, test.ip, test.allowed)
+		}
+	}
+}
+
+func TestAccessRules(t *testing.T) {
+	cfg := newConfig()
+	allow, _ := parseAccessRule(true, "10.0.0.0/8")
+	cfg.AccessRules = []accessRule{allow}
+	interc, next := newTestInterceptor(t, cfg)
+
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusForbidden || err != ErrAccessDenied {
+		t.Errorf("expected clients outside of the allow list to receive a 403, got %d: %v", status, err)
+	}
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "10.0.0.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusOK || err != nil {
+		t.Errorf("expected allowed clients to be served, got %d: %v", status, err)
+	}
+	if next.calls != 0 {
+		t.Error("expected nothing to be forwarded")
+	}
+}
+
+func TestAdminAllowedIPs(t *testing.T) {
+	cfg := newConfig()
+	cfg.MaxTagStatsEntries = 10
+	cfg.MetricsPath = "/metrics"
+	cfg.AdminPath = "/_iotacaddy/status"
+	rule, err := parseAccessRule(true, "10.1.0.0/16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AdminAllowedIPs = []*net.IPNet{rule.network}
+	interc, _ := newTestInterceptor(t, cfg)
+	admin := &adminStatus{interc: interc, next: interc}
+
+	for _, tt := range []struct {
+		remoteAddr string
+		expected   int
+	}{
+		{"10.1.2.3:1234", http.StatusOK},
+		{"1.1.1.1:1234", http.StatusForbidden},
+	} {
+		for _, path := range []string{statsPath, cfg.MetricsPath, cfg.AdminPath} {
+			r := httptest.NewRequest(http.MethodGet, path, nil)
+			r.RemoteAddr = tt.remoteAddr
+			if status, err := admin.ServeHTTP(httptest.NewRecorder(), r); status != tt.expected {
+				t.Errorf("%s %s: expected %d, got %d: %v", tt.remoteAddr, path, tt.expected, status, err)
+			}
+		}
+		// the PoW endpoint stays public
+		if status, err := admin.ServeHTTP(httptest.NewRecorder(), attachRequest(t, tt.remoteAddr, 1, txTrytes(t, "TEST", 0))); status != http.StatusOK {
+			t.Errorf("%s: expected attachToTangle to be served, got %d: %v", tt.remoteAddr, status, err)
+		}
+	}
+}
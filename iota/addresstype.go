@@ -0,0 +1,112 @@
+package iota
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/iotaledger/iota.go/address"
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	addressTypeNormal     = "normal"
+	addressTypeRestricted = "restricted"
+	addressTypeBoth       = "both"
+)
+
+const (
+	addressSchemeKerl    = "kerl"
+	addressSchemeEd25519 = "ed25519"
+	addressSchemeUnknown = "unknown"
+)
+
+// Chrysalis migration addresses encode an Ed25519 address and the first 4 bytes of its
+// BLAKE2b-256 hash in B1T6 (2 trytes per byte) between this prefix and a 9 tryte.
+const (
+	migrationAddressPrefix      = "TRANSFER"
+	migrationAddressChecksumLen = 4
+)
+
+// restricted (tokenized) addresses are marked by this first tryte in the protocol
+// versions supporting address types, any other first tryte denotes a normal address
+const restrictedAddressTryte = 'R'
+
+// addressType returns the type of the given address.
+func addressType(address string) string {
+	if len(address) > 0 && address[0] == restrictedAddressTryte {
+		return addressTypeRestricted
+	}
+	return addressTypeNormal
+}
+
+// detectAddressScheme returns whether the address, with or without checksum, is a Kerl address,
+// an Ed25519 address in the Chrysalis migration format or unknown. Kerl addresses are recognised
+// by their last trit, which Kerl always sets to 0. Addresses in the migration format with an
+// invalid checksum are unknown as they are most likely corrupted Ed25519 addresses.
+func detectAddressScheme(addr trinary.Trytes) string {
+	if len(addr) == consts.AddressWithChecksumTrytesSize {
+		if address.ValidChecksum(addr[:consts.HashTrytesSize], addr[consts.HashTrytesSize:]) != nil {
+			return addressSchemeUnknown
+		}
+		addr = addr[:consts.HashTrytesSize]
+	}
+	if len(addr) != consts.HashTrytesSize || trinary.ValidTrytes(addr) != nil {
+		return addressSchemeUnknown
+	}
+	if strings.HasPrefix(addr, migrationAddressPrefix) && addr[len(addr)-1] == '9' {
+		if validMigrationChecksum(addr) {
+			return addressSchemeEd25519
+		}
+		return addressSchemeUnknown
+	}
+	if trits := trinary.MustTrytesToTrits(addr); trits[len(trits)-1] == 0 {
+		return addressSchemeKerl
+	}
+	return addressSchemeUnknown
+}
+
+// validMigrationChecksum reports whether the migration address holds an Ed25519 address
+// with a valid checksum.
+func validMigrationChecksum(addr trinary.Trytes) bool {
+	encoded := addr[len(migrationAddressPrefix) : len(addr)-1]
+	decoded := make([]byte, len(encoded)/2)
+	for i := range decoded {
+		v := trinary.TritsToInt(trinary.MustTrytesToTrits(encoded[2*i : 2*i+2]))
+		if v < -128 || v > 127 {
+			return false
+		}
+		decoded[i] = byte(int8(v))
+	}
+	ed25519Addr, checksum := decoded[:len(decoded)-migrationAddressChecksumLen], decoded[len(decoded)-migrationAddressChecksumLen:]
+	hash := blake2b.Sum256(ed25519Addr)
+	return bytes.Equal(checksum, hash[:migrationAddressChecksumLen])
+}
+
+// validateAddressSchemes returns ErrUnknownAddressScheme if one of the transactions uses an address
+// of an unknown scheme.
+func validateAddressSchemes(txs []transaction.Transaction) error {
+	for i := range txs {
+		if detectAddressScheme(txs[i].Address) == addressSchemeUnknown {
+			return errors.Wrapf(ErrUnknownAddressScheme, "address %s", txs[i].Address)
+		}
+	}
+	return nil
+}
+
+// validateAddressTypes returns ErrAddressTypeForbidden if one of the transactions uses an address
+// whose type isn't allowed.
+func validateAddressTypes(txs []transaction.Transaction, allowed string) error {
+	if allowed == addressTypeBoth {
+		return nil
+	}
+	for i := range txs {
+		if typ := addressType(txs[i].Address); typ != allowed {
+			return errors.Wrapf(ErrAddressTypeForbidden, "%s address %s", typ, txs[i].Address)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iotaledger/iota.go/address"
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+// addressTx returns a data transaction to the given address.
+func addressTx(address trinary.Hash) transaction.Transaction {
+	tx := testTx("TEST", 0)
+	tx.Address = trinary.Pad(address, consts.HashTrytesSize)
+	return tx
+}
+
+func addressTxTrytes(t *testing.T, address trinary.Hash) trinary.Trytes {
+	return bundleTrytes(t, defaultBundleHashAlgorithm, addressTx(address))[0]
+}
+
+func TestAllowedAddressTypes(t *testing.T) {
+	normal := addressTxTrytes(t, "NORMAL")
+	restricted := addressTxTrytes(t, "RESTRICTED")
+	both := bundleTrytes(t, defaultBundleHashAlgorithm, addressTx("NORMAL"), addressTx("RESTRICTED"))
+
+	tests := []struct {
+		allowed string
+		trytes  []trinary.Trytes
+		ok      bool
+	}{
+		{addressTypeBoth, both, true},
+		{addressTypeNormal, []trinary.Trytes{normal}, true},
+		{addressTypeNormal, []trinary.Trytes{restricted}, false},
+		{addressTypeNormal, both, false},
+		{addressTypeRestricted, []trinary.Trytes{restricted}, true},
+		{addressTypeRestricted, []trinary.Trytes{normal}, false},
+	}
+	for _, test := range tests {
+		cfg := newConfig()
+		cfg.MaxTxInBundle = 2
+		cfg.AllowedAddressTypes = test.allowed
+		interc, _ := newTestInterceptor(t, cfg)
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, test.trytes...))
+		if test.ok {
+			if status != http.StatusOK {
+				t.Errorf("%s: expected %d transactions to be accepted, got %d: %v", test.allowed, len(test.trytes), status, err)
+			}
+			continue
+		}
+		if status != http.StatusBadRequest || errors.Cause(err) != ErrAddressTypeForbidden {
+			t.Errorf("%s: expected forbidden address type, got %d: %v", test.allowed, status, err)
+		}
+	}
+}
+
+// migrationAddress encodes the Ed25519 address in the Chrysalis migration format.
+func migrationAddress(ed25519Addr []byte) trinary.Trytes {
+	hash := blake2b.Sum256(ed25519Addr)
+	addr := migrationAddressPrefix
+	for _, b := range append(append([]byte(nil), ed25519Addr...), hash[:migrationAddressChecksumLen]...) {
+		addr += trinary.MustTritsToTrytes(trinary.PadTrits(trinary.IntToTrits(int64(int8(b))), 6))
+	}
+	return addr + "9"
+}
+
+func TestDetectAddressScheme(t *testing.T) {
+	kerlAddr, err := address.GenerateAddress(trinary.Pad("SEED", consts.HashTrytesSize), 0, consts.SecurityLevelMedium, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ed25519Addr := make([]byte, 32)
+	for i := range ed25519Addr {
+		ed25519Addr[i] = byte(i * 9)
+	}
+	migration := migrationAddress(ed25519Addr)
+	if len(migration) != consts.HashTrytesSize {
+		t.Fatalf("expected the migration address to be %d trytes, got %d", consts.HashTrytesSize, len(migration))
+	}
+	// another address with the original checksum
+	ed25519Addr[0]++
+	checksumStart := len(migration) - 2*migrationAddressChecksumLen - 1
+	corrupted := migrationAddress(ed25519Addr)[:checksumStart] + migration[checksumStart:]
+
+	for _, tt := range []struct {
+		name     string
+		addr     trinary.Trytes
+		expected string
+	}{
+		{"kerl", kerlAddr[:consts.HashTrytesSize], addressSchemeKerl},
+		{"kerl with checksum", kerlAddr, addressSchemeKerl},
+		{"kerl with invalid checksum", kerlAddr[:consts.HashTrytesSize] + "999999999", addressSchemeUnknown},
+		{"null address", consts.NullHashTrytes, addressSchemeKerl},
+		{"last trit set", trinary.Pad("", consts.HashTrytesSize-1) + "M", addressSchemeUnknown},
+		{"ed25519", migration, addressSchemeEd25519},
+		{"ed25519 with invalid checksum", corrupted, addressSchemeUnknown},
+		{"too short", "ABC", addressSchemeUnknown},
+		{"invalid trytes", trinary.Pad("abc", consts.HashTrytesSize), addressSchemeUnknown},
+	} {
+		if scheme := detectAddressScheme(tt.addr); scheme != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, scheme)
+		}
+	}
+}
+
+func TestUnknownAddressScheme(t *testing.T) {
+	interc, _ := newTestInterceptor(t, newConfig())
+	unknown := trinary.Pad("", consts.HashTrytesSize-1) + "M"
+	for _, tt := range []struct {
+		name     string
+		value    int64
+		expected int
+	}{
+		{"value bundle", 1, http.StatusUnprocessableEntity},
+		{"data bundle", 0, http.StatusOK},
+	} {
+		input, output := testTx("TEST", -tt.value), testTx("TEST", tt.value)
+		output.Address = unknown
+		bundle := bundleTrytes(t, "kerl", output, input)
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+		if status != tt.expected {
+			t.Errorf("%s: expected %d, got %d: %v", tt.name, tt.expected, status, err)
+		}
+		if tt.expected == http.StatusUnprocessableEntity && errors.Cause(err) != ErrUnknownAddressScheme {
+			t.Errorf("%s: expected the address scheme to be unknown, got %v", tt.name, err)
+		}
+	}
+}
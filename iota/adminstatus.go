@@ -0,0 +1,59 @@
+package iota
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+)
+
+type adminStatusRes struct {
+	PoWImplName   string `json:"pow_impl_name"`
+	MaxMWM        int    `json:"max_mwm"`
+	MaxTxInBundle int    `json:"max_tx_in_bundle"`
+	ActiveWorkers int    `json:"active_workers"`
+	QueuedJobs    int    `json:"queued_jobs"`
+	TotalRequests uint64 `json:"total_requests"`
+	TotalErrors   uint64 `json:"total_errors"`
+	UptimeSeconds int64  `json:"uptime_seconds"`
+	// only set if statsevery is configured
+	PoWP99Ms int64 `json:"pow_p99_ms,omitempty"`
+	DryRun   bool  `json:"dry_run,omitempty"`
+}
+
+// adminStatus serves the runtime status of the interceptor on the admin path. It runs as its own
+// middleware in front of the interceptor, so status requests never wait for a PoW slot.
+type adminStatus struct {
+	interc *Interceptor
+	next   httpserver.Handler
+}
+
+func (s *adminStatus) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
+	interc := s.interc
+	if r.URL.Path != interc.Config.AdminPath || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return s.next.ServeHTTP(w, r)
+	}
+	if ip := interc.clientIP(r); !accessAllowed(interc.Config.AccessRules, ip) {
+		interc.logEntry(levelWarn, "access_denied", logFields{RemoteAddr: ip}, "denying %s request to %s from %s\n", r.Method, r.URL.Path, ip)
+		return http.StatusForbidden, ErrAccessDenied
+	}
+	if status, err := interc.checkAdminAccess(r); err != nil {
+		return status, err
+	}
+	res := &adminStatusRes{
+		PoWImplName:   interc.powImplName,
+		MaxMWM:        interc.Config.MaxMWM,
+		MaxTxInBundle: interc.Config.MaxTxInBundle,
+		ActiveWorkers: interc.scheduler.active(),
+		QueuedJobs:    interc.scheduler.queued(),
+		TotalRequests: atomic.LoadUint64(&interc.totalRequests),
+		TotalErrors:   atomic.LoadUint64(&interc.totalErrors),
+		UptimeSeconds: int64(time.Since(interc.started).Seconds()),
+		DryRun:        interc.Config.DryRun,
+	}
+	if interc.powStats != nil {
+		res.PoWP99Ms = interc.powStats.percentile(99)
+	}
+	return writeJSON(w, res)
+}
@@ -0,0 +1,43 @@
+package iota
+
+import (
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+const defaultTrytesAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ9"
+
+// trytesAlphabetSize is the amount of values a tryte can hold.
+const trytesAlphabetSize = 27
+
+// trytesAlphabet is the character set of the configured trytes alphabet.
+type trytesAlphabet [256]bool
+
+// newTrytesAlphabet compiles the given alphabet of 27 distinct ASCII characters.
+func newTrytesAlphabet(alphabet string) (*trytesAlphabet, error) {
+	if len(alphabet) != trytesAlphabetSize {
+		return nil, errors.Errorf("the alphabet must have %d characters, got %d", trytesAlphabetSize, len(alphabet))
+	}
+	a := &trytesAlphabet{}
+	for i := 0; i < len(alphabet); i++ {
+		c := alphabet[i]
+		if c >= 0x80 {
+			return nil, errors.Errorf("the alphabet must only contain ASCII characters, got '%c'", c)
+		}
+		if a[c] {
+			return nil, errors.Errorf("the alphabet contains '%c' more than once", c)
+		}
+		a[c] = true
+	}
+	return a, nil
+}
+
+// check returns ErrInvalidTrytes if the trytes contain a character outside the alphabet.
+func (a *trytesAlphabet) check(trytes trinary.Trytes) error {
+	for i := 0; i < len(trytes); i++ {
+		if !a[trytes[i]] {
+			return errors.Wrapf(ErrInvalidTrytes, "'%c' at position %d", trytes[i], i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func TestNewTrytesAlphabet(t *testing.T) {
+	for _, tt := range []struct {
+		alphabet string
+		valid    bool
+	}{
+		{defaultTrytesAlphabet, true},
+		{"9ABCDEFGHIJKLMNOPQRSTUVWXYZ", true},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXY9-", true},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZ", false},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYZ99", false},
+		{"AACDEFGHIJKLMNOPQRSTUVWXYZ9", false},
+		{"ABCDEFGHIJKLMNOPQRSTUVWXYÄ", false},
+	} {
+		if _, err := newTrytesAlphabet(tt.alphabet); (err == nil) != tt.valid {
+			t.Errorf("%s: expected valid %v, got %v", tt.alphabet, tt.valid, err)
+		}
+	}
+}
+
+func TestStrictTrytesValidation(t *testing.T) {
+	cfg := newConfig()
+	cfg.StrictTrytesValidation = true
+	// Z is replaced by - in the custom alphabet
+	cfg.TrytesAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXY9-"
+	interc, _ := newTestInterceptor(t, cfg)
+
+	// bump the obsolete tag until the bundle hash doesn't contain Z either
+	tx := testTx("TEST", 0)
+	valid := bundleTrytes(t, defaultBundleHashAlgorithm, tx)[0]
+	for i := 1; strings.Contains(valid, "Z"); i++ {
+		if i > 1000 {
+			t.Fatal("unable to build a test transaction without Z")
+		}
+		tx.ObsoleteTag = trinary.MustTritsToTrytes(trinary.PadTrits(trinary.IntToTrits(int64(i)), consts.ObsoleteTagTrinarySize))
+		valid = bundleTrytes(t, defaultBundleHashAlgorithm, tx)[0]
+	}
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, valid)); status != http.StatusOK {
+		t.Errorf("expected trytes within the alphabet to be accepted, got %d: %v", status, err)
+	}
+
+	outside := txTrytes(t, "ZEBRA", 0)
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, outside))
+	if status != http.StatusBadRequest || errors.Cause(err) != ErrInvalidTrytes {
+		t.Errorf("expected trytes outside the alphabet to be rejected, got %d: %v", status, err)
+	}
+}
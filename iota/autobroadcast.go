@@ -0,0 +1,57 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+const autoBroadcastTimeout = 10 * time.Second
+
+// API version IRI requires in the X-IOTA-API-Version header
+const iriAPIVersion = "1"
+
+// autoBroadcaster sends the PoWed bundles to a secondary IRI via broadcastTransactions.
+type autoBroadcaster struct {
+	url    string
+	client *http.Client
+}
+
+func newAutoBroadcaster(iriURL string) (*autoBroadcaster, error) {
+	u, err := url.Parse(iriURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid auto broadcast URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("auto broadcast URL must be an http or https URL, got %s", iriURL)
+	}
+	return &autoBroadcaster{url: iriURL, client: &http.Client{Timeout: autoBroadcastTimeout}}, nil
+}
+
+// broadcast posts a broadcastTransactions call with the given trytes to the IRI.
+func (b *autoBroadcaster) broadcast(trytes []trinary.Trytes) error {
+	body, err := json.Marshal(&broadcastTransactionsReq{Command: broadcastTransactionsCommand, Trytes: trytes})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set(contentType, contentTypeJSON)
+	req.Header.Set("X-IOTA-API-Version", iriAPIVersion)
+	res, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("IRI returned status %d", res.StatusCode)
+	}
+	return nil
+}
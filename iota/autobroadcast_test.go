@@ -0,0 +1,64 @@
+package iota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAutoBroadcast(t *testing.T) {
+	broadcasts := make(chan *broadcastTransactionsReq, 10)
+	fail := make(chan bool, 1)
+	iri := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &broadcastTransactionsReq{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil || r.Header.Get("X-IOTA-API-Version") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		broadcasts <- req
+		select {
+		case <-fail:
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte("{}"))
+		}
+	}))
+	defer iri.Close()
+
+	cfg := newConfig()
+	cfg.AutoBroadcastURL = iri.URL
+	interc, next := newTestInterceptor(t, cfg)
+	for i, tag := range []string{"FIRST", "SECOND"} {
+		// failing broadcasts don't affect the response
+		if i == 1 {
+			fail <- true
+		}
+		w := httptest.NewRecorder()
+		if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, tag, 0))); status != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %v", tag, status, err)
+		}
+		res := &AttachToTangleRes{}
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case req := <-broadcasts:
+			if req.Command != broadcastTransactionsCommand || !reflect.DeepEqual(req.Trytes, res.Trytes) {
+				t.Errorf("%s: expected the PoWed trytes to be broadcast, got %+v", tag, req)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: expected the bundle to be broadcast", tag)
+		}
+	}
+	if next.calls != 0 {
+		t.Errorf("expected nothing to be sent to the next handler, got %d calls", next.calls)
+	}
+
+	cfg.AutoBroadcastURL = "ftp://10.0.0.2"
+	if _, err := newInterceptor(cfg, "Null", nullPoW, logger); err == nil {
+		t.Error("expected a non HTTP URL to be rejected")
+	}
+}
@@ -0,0 +1,131 @@
+package iota
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultBalanceCheckTimeout = 5 * time.Second
+	defaultBalanceCacheTTL     = 10 * time.Second
+)
+
+const getBalancesCommand = "getBalances"
+
+// confirmation threshold in percent IRI requires for getBalances
+const getBalancesThreshold = 100
+
+type getBalancesReq struct {
+	Command   string         `json:"command"`
+	Addresses []trinary.Hash `json:"addresses"`
+	Threshold int            `json:"threshold"`
+}
+
+type getBalancesRes struct {
+	Balances []string `json:"balances"`
+}
+
+type balanceEntry struct {
+	balance uint64
+	fetched time.Time
+}
+
+// balanceChecker rejects bundles spending more than their input addresses hold. The balances
+// are looked up via getBalances on the next handler and cached for the configured TTL.
+type balanceChecker struct {
+	timeout time.Duration
+	ttl     time.Duration
+
+	mu    sync.Mutex
+	cache map[trinary.Hash]balanceEntry
+}
+
+func newBalanceChecker(timeout, ttl time.Duration) *balanceChecker {
+	return &balanceChecker{timeout: timeout, ttl: ttl, cache: map[trinary.Hash]balanceEntry{}}
+}
+
+// check returns ErrInsufficientBalance if an input address of the given transactions
+// holds less than the bundle spends from it.
+func (c *balanceChecker) check(next httpserver.Handler, r *http.Request, txs []transaction.Transaction) error {
+	spent := map[trinary.Hash]uint64{}
+	var inputs []trinary.Hash
+	for i := range txs {
+		if txs[i].Value >= 0 {
+			continue
+		}
+		if _, has := spent[txs[i].Address]; !has {
+			inputs = append(inputs, txs[i].Address)
+		}
+		spent[txs[i].Address] += uint64(-txs[i].Value)
+	}
+	if len(inputs) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	balances := make(map[trinary.Hash]uint64, len(inputs))
+	var missing []trinary.Hash
+	c.mu.Lock()
+	for _, address := range inputs {
+		if entry, ok := c.cache[address]; ok && now.Sub(entry.fetched) < c.ttl {
+			balances[address] = entry.balance
+			continue
+		}
+		missing = append(missing, address)
+	}
+	c.mu.Unlock()
+
+	if len(missing) > 0 {
+		fetched, err := c.lookup(next, r, missing)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		for address, entry := range c.cache {
+			if now.Sub(entry.fetched) >= c.ttl {
+				delete(c.cache, address)
+			}
+		}
+		for i, address := range missing {
+			c.cache[address] = balanceEntry{balance: fetched[i], fetched: now}
+			balances[address] = fetched[i]
+		}
+		c.mu.Unlock()
+	}
+
+	for _, address := range inputs {
+		if balances[address] < spent[address] {
+			return errors.Wrapf(ErrInsufficientBalance, "%s holds %di but %di are spent", address, balances[address], spent[address])
+		}
+	}
+	return nil
+}
+
+// lookup fetches the balances of the given addresses via getBalances within the configured timeout.
+func (c *balanceChecker) lookup(next httpserver.Handler, r *http.Request, addresses []trinary.Hash) ([]uint64, error) {
+	ctx, cancel := context.WithTimeout(r.Context(), c.timeout)
+	defer cancel()
+	res := &getBalancesRes{}
+	if err := callIRI(ctx, next, r, &getBalancesReq{Command: getBalancesCommand, Addresses: addresses, Threshold: getBalancesThreshold}, res); err != nil {
+		return nil, errors.Wrap(err, "getBalances failed")
+	}
+	if len(res.Balances) != len(addresses) {
+		return nil, errors.Errorf("getBalances returned %d balances for %d addresses", len(res.Balances), len(addresses))
+	}
+	balances := make([]uint64, len(addresses))
+	for i, balance := range res.Balances {
+		var err error
+		if balances[i], err = strconv.ParseUint(balance, 10, 64); err != nil {
+			return nil, errors.Wrapf(err, "invalid balance of %s in getBalances response", addresses[i])
+		}
+	}
+	return balances, nil
+}
@@ -0,0 +1,59 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func TestCheckBalances(t *testing.T) {
+	// the input is signed with the test seed's first address
+	input := testInputAt(t, 0).address
+	outputTx := testTx("TEST", 100)
+	outputTx.Address = trinary.Pad("OUTPUT", consts.HashTrytesSize)
+	bundle := bundleTrytes(t, "kerl", outputTx, testTx("TEST", -100))
+
+	cfg := newConfig()
+	cfg.CheckBalances = true
+	cfg.BalanceCacheTTL = time.Minute
+	interc, _ := newTestInterceptor(t, cfg)
+	iri := &mockIRI{balances: map[trinary.Hash]uint64{input: 99}}
+	interc.Next = iri
+
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+	if status != http.StatusBadRequest || errors.Cause(err) != ErrInsufficientBalance {
+		t.Errorf("expected an insufficient balance to be rejected, got %d: %v", status, err)
+	}
+
+	// the cached balance is still insufficient
+	iri.balances[input] = 100
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusBadRequest {
+		t.Errorf("expected the cached balance to be used, got %d", status)
+	}
+	if iri.balanceLookups != 1 {
+		t.Errorf("expected the balance to be looked up once, got %d lookups", iri.balanceLookups)
+	}
+
+	// expire the cached balance
+	interc.balances.mu.Lock()
+	entry := interc.balances.cache[input]
+	entry.fetched = entry.fetched.Add(-cfg.BalanceCacheTTL)
+	interc.balances.cache[input] = entry
+	interc.balances.mu.Unlock()
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Errorf("expected a sufficient balance to be accepted, got %d: %v", status, err)
+	}
+	if iri.balanceLookups != 2 {
+		t.Errorf("expected the balance to be looked up again after the TTL, got %d lookups", iri.balanceLookups)
+	}
+
+	// data bundles don't spend anything
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusOK || iri.balanceLookups != 2 {
+		t.Errorf("expected data bundles not to be checked, got %d: %v", status, err)
+	}
+}
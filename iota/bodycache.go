@@ -0,0 +1,90 @@
+package iota
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+)
+
+const (
+	defaultBodyCacheKeep = 100
+	bodyCacheExt         = ".json.gz"
+)
+
+// bodyCache stores gzip compressed request bodies in a directory for later analysis,
+// only keeping the most recent files.
+type bodyCache struct {
+	mu   sync.Mutex
+	dir  string
+	keep int
+}
+
+func newBodyCache(dir string, keep int) (*bodyCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &bodyCache{dir: dir, keep: keep}, nil
+}
+
+// store writes the body of the given request and removes the oldest files exceeding the keep limit.
+func (bc *bodyCache) store(r *http.Request, body []byte) error {
+	reqID, _ := r.Context().Value(httpserver.RequestIDCtxKey).(string)
+	if reqID == "" {
+		reqID = uuid.New().String()
+	}
+	// the timestamp prefix makes the file names sort chronologically
+	name := fmt.Sprintf("%s-%s%s", time.Now().UTC().Format("20060102T150405.000000000"), reqID, bodyCacheExt)
+
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	f, err := os.Create(filepath.Join(bc.dir, name))
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write(body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return bc.rotate()
+}
+
+func (bc *bodyCache) rotate() error {
+	infos, err := ioutil.ReadDir(bc.dir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	for _, info := range infos {
+		if !info.IsDir() && strings.HasSuffix(info.Name(), bodyCacheExt) {
+			names = append(names, info.Name())
+		}
+	}
+	if len(names) <= bc.keep {
+		return nil
+	}
+	sort.Strings(names)
+	for _, name := range names[:len(names)-bc.keep] {
+		if err := os.Remove(filepath.Join(bc.dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
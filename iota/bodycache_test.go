@@ -0,0 +1,53 @@
+package iota
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBodyCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iota-body-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := newConfig()
+	cfg.BodyCachePath = dir
+	cfg.BodyCacheKeep = 3
+	interc, _ := newTestInterceptor(t, cfg)
+	for i := 0; i < 5; i++ {
+		interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"+bodyCacheExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Fatalf("expected 3 cached bodies, got %d", len(files))
+	}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("%s is not gzip compressed: %v", file, err)
+		}
+		req := &AttachToTangleReq{}
+		if err := json.NewDecoder(gz).Decode(req); err != nil {
+			t.Errorf("%s doesn't contain valid JSON: %v", file, err)
+		}
+		if req.Command != attachToTangleCommand {
+			t.Errorf("expected cached command %s, got %s", attachToTangleCommand, req.Command)
+		}
+		f.Close()
+	}
+}
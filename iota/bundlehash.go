@@ -0,0 +1,84 @@
+package iota
+
+import (
+	"sort"
+
+	"github.com/iotaledger/iota.go/bundle"
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/signing"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+const defaultBundleHashAlgorithm = "kerl"
+
+// sponge functions which can be used to compute the bundle hash
+var bundleHashAlgorithms = map[string]signing.SpongeFunctionCreator{
+	"kerl":    signing.NewKerl,
+	"curlp81": signing.NewCurlP81,
+}
+
+// the essence of a transaction which goes into the bundle hash: address, value,
+// obsolete tag, timestamp, current and last index
+const (
+	bundleEssenceOffset = consts.AddressTrinaryOffset / 3
+	bundleEssenceSize   = (consts.BundleTrinaryOffset - consts.AddressTrinaryOffset) / 3
+)
+
+// computeBundleHash computes the bundle hash of the given transactions in the order of their current index.
+func computeBundleHash(txs []transaction.Transaction, newSponge signing.SpongeFunctionCreator) (trinary.Hash, error) {
+	sorted := make([]*transaction.Transaction, len(txs))
+	for i := range txs {
+		sorted[i] = &txs[i]
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CurrentIndex < sorted[j].CurrentIndex })
+
+	sponge := newSponge()
+	for _, tx := range sorted {
+		txTrytes, err := transaction.TransactionToTrytes(tx)
+		if err != nil {
+			return "", err
+		}
+		essence := txTrytes[bundleEssenceOffset : bundleEssenceOffset+bundleEssenceSize]
+		if err := sponge.Absorb(trinary.MustTrytesToTrits(essence)); err != nil {
+			return "", err
+		}
+	}
+	hashTrits, err := sponge.Squeeze(consts.HashTrinarySize)
+	if err != nil {
+		return "", err
+	}
+	return trinary.TritsToTrytes(hashTrits)
+}
+
+// validateBundleHash checks that every transaction carries the bundle hash computed over the bundle
+// and returns ErrInvalidBundle otherwise.
+func validateBundleHash(txs []transaction.Transaction, newSponge signing.SpongeFunctionCreator) error {
+	hash, err := computeBundleHash(txs, newSponge)
+	if err != nil {
+		return errors.Wrap(ErrInvalidBundle, err.Error())
+	}
+	for i := range txs {
+		if txs[i].Bundle != hash {
+			return errors.Wrapf(ErrInvalidBundle, "transaction %d has bundle hash %s but computed %s", txs[i].CurrentIndex, txs[i].Bundle, hash)
+		}
+	}
+	return nil
+}
+
+// validateBundleSignatures checks the signatures of the bundle's input transactions
+// against the bundle hash and returns ErrInvalidBundle if one doesn't match.
+func validateBundleSignatures(txs []transaction.Transaction) error {
+	sorted := make(bundle.Bundle, len(txs))
+	copy(sorted, txs)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CurrentIndex < sorted[j].CurrentIndex })
+	valid, err := bundle.ValidateBundleSignatures(sorted)
+	if err != nil {
+		return errors.Wrap(ErrInvalidBundle, err.Error())
+	}
+	if !valid {
+		return errors.Wrap(ErrInvalidBundle, "an input signature doesn't match the bundle hash")
+	}
+	return nil
+}
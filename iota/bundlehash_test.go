@@ -0,0 +1,81 @@
+package iota
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func TestValidateBundleHash(t *testing.T) {
+	for algorithm := range bundleHashAlgorithms {
+		bundle := bundleTrytes(t, algorithm, testTx("FIRST", 0), testTx("SECOND", 0))
+		for other := range bundleHashAlgorithms {
+			cfg := newConfig()
+			cfg.BundleHashAlgorithm = other
+			interc, _ := newTestInterceptor(t, cfg)
+			status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+			if other == algorithm {
+				if status != http.StatusOK {
+					t.Errorf("%s bundle validated with %s: expected 200, got %d: %v", algorithm, other, status, err)
+				}
+				continue
+			}
+			if status != http.StatusUnprocessableEntity || errors.Cause(err) != ErrInvalidBundle {
+				t.Errorf("%s bundle validated with %s: expected invalid bundle hash, got %d: %v", algorithm, other, status, err)
+			}
+		}
+	}
+}
+
+func TestValidateBundleHashTampered(t *testing.T) {
+	first, second := testTx("FIRST", 0), testTx("SECOND", 0)
+	bundle := bundleTrytes(t, "kerl", first, second)
+	// a transaction of another bundle
+	bundle[0] = bundleTrytes(t, "kerl", testTx("OTHER", 0), testTx("SECOND", 0))[0]
+
+	cfg := newConfig()
+	interc, _ := newTestInterceptor(t, cfg)
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusUnprocessableEntity || errors.Cause(err) != ErrInvalidBundle {
+		t.Errorf("expected tampered bundle to be rejected, got %d: %v", status, err)
+	}
+}
+
+func TestValidateBundleHashDisabled(t *testing.T) {
+	bundle := bundleTrytes(t, "kerl", testTx("FIRST", 0), testTx("SECOND", 0))
+	bundle[0] = bundleTrytes(t, "kerl", testTx("OTHER", 0), testTx("SECOND", 0))[0]
+
+	cfg := newConfig()
+	cfg.ValidateBundleHash = false
+	interc, _ := newTestInterceptor(t, cfg)
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Errorf("expected the bundle hash not to be validated, got %d: %v", status, err)
+	}
+}
+
+func TestValidateSignaturesBeforePoW(t *testing.T) {
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "bundles", "invalid_signature.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var powCalls int
+	cfg := newConfig()
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.powFn = func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		powCalls++
+		return consts.NullNonceTrytes, nil
+	}
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+	if status != http.StatusUnprocessableEntity || errors.Cause(err) != ErrInvalidBundle {
+		t.Errorf("expected the tampered signature to be rejected with 422, got %d: %v", status, err)
+	}
+	if powCalls != 0 {
+		t.Errorf("expected no PoW to be done, got %d calls", powCalls)
+	}
+}
@@ -0,0 +1,93 @@
+package iota
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/iotaledger/iota.go/pow"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+// status logged for requests whose client disconnected before the response, as used by nginx
+const statusClientClosedRequest = 499
+
+// header clients request a shorter PoW timeout in milliseconds with
+const headerPoWTimeout = "X-IOTA-PoW-Timeout-Ms"
+
+type powResult struct {
+	powed []trinary.Trytes
+	err   error
+}
+
+// powTimeout returns how long the PoW of the request may take, 0 if it isn't limited, and whether
+// the client requested a shorter timeout than the configured one. Requested timeouts exceeding
+// the configured one are clamped to it, invalid ones are ignored.
+func (interc *Interceptor) powTimeout(r *http.Request) (time.Duration, bool) {
+	timeout := interc.Config.PoWTimeout
+	if !interc.Config.ClientRequestedTimeout {
+		return timeout, false
+	}
+	ms, err := strconv.Atoi(r.Header.Get(headerPoWTimeout))
+	if err != nil || ms <= 0 {
+		return timeout, false
+	}
+	if requested := time.Duration(ms) * time.Millisecond; timeout == 0 || requested < timeout {
+		return requested, true
+	}
+	return timeout, false
+}
+
+// jobContext returns the context the PoW job of the request is derived from, bounded by the
+// client's connection if enabled.
+func (interc *Interceptor) jobContext(r *http.Request) context.Context {
+	if interc.Config.CancelPoWOnDisconnect {
+		return r.Context()
+	}
+	return context.Background()
+}
+
+// powContext returns the given job context bounded by the given timeout, if any.
+func powContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// doCancelablePoW does the PoW like safeDoPoW but stops it once the given context is done.
+// The implementations can't be interrupted within a transaction, so the PoW stops before
+// the next transaction. If the client disconnected, ErrPoWCanceled is returned once the
+// current transaction is done to keep the PoW slot until then. If the deadline passed,
+// ErrPoWTimeout is returned right away as the implementation may hang. The given release
+// func frees the PoW slots and is called once the PoW returned, so a hanging PoW keeps
+// its slots even after the timeout was answered.
+func (interc *Interceptor) doCancelablePoW(ctx context.Context, trunk, branch trinary.Hash, txTrytes []trinary.Trytes, mwm uint64, fn pow.ProofOfWorkFunc, release func()) ([]trinary.Trytes, error) {
+	done := make(chan powResult, 1)
+	go func() {
+		powed, err := interc.safeDoPoW(trunk, branch, txTrytes, mwm, cancelablePoW(ctx, fn))
+		release()
+		done <- powResult{powed, err}
+	}()
+	select {
+	case res := <-done:
+		return res.powed, res.err
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, ErrPoWTimeout
+		}
+		<-done
+		return nil, ErrPoWCanceled
+	}
+}
+
+// cancelablePoW returns a PoW function which fails once the context is done.
+func cancelablePoW(ctx context.Context, fn pow.ProofOfWorkFunc) pow.ProofOfWorkFunc {
+	return func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		if ctx.Err() != nil {
+			return "", ErrPoWCanceled
+		}
+		return fn(trytes, mwm, parallelism...)
+	}
+}
@@ -0,0 +1,163 @@
+package iota
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+func TestCancelPoWOnDisconnect(t *testing.T) {
+	var buf bytes.Buffer
+	origLogger := logger
+	logger = log.New(&buf, "", 0)
+	defer func() { logger = origLogger }()
+
+	var calls int32
+	started := make(chan struct{}, 10)
+	slowPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		atomic.AddInt32(&calls, 1)
+		started <- struct{}{}
+		time.Sleep(50 * time.Millisecond)
+		return consts.NullNonceTrytes, nil
+	}
+	cfg := newConfig()
+	cfg.CancelPoWOnDisconnect = true
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.powFn = slowPoW
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := attachRequest(t, "1.1.1.1:1234", 1, dataBundleTrytes(t, "TEST", 10)...).WithContext(ctx)
+	var canceled atomic.Value
+	go func() {
+		<-started
+		canceled.Store(time.Now())
+		cancel()
+	}()
+
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), req)
+	if status != statusClientClosedRequest || err != ErrPoWCanceled {
+		t.Fatalf("expected the PoW to be canceled, got %d: %v", status, err)
+	}
+	if elapsed := time.Since(canceled.Load().(time.Time)); elapsed > 150*time.Millisecond {
+		t.Errorf("expected the PoW to stop after the current transaction, took %v", elapsed)
+	}
+	after := atomic.LoadInt32(&calls)
+	time.Sleep(100 * time.Millisecond)
+	if calls := atomic.LoadInt32(&calls); calls != after || calls > 2 {
+		t.Errorf("expected the PoW goroutine to exit, got %d PoW calls", calls)
+	}
+	if !strings.Contains(buf.String(), "client 1.1.1.1 disconnected") {
+		t.Errorf("expected the cancellation to be logged, got:\n%s", buf.String())
+	}
+}
+
+func TestPoWTimeout(t *testing.T) {
+	hang := make(chan struct{})
+	var calls int32
+	// only the first PoW hangs
+	hangingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			<-hang
+		}
+		return consts.NullNonceTrytes, nil
+	}
+	cfg := newConfig()
+	cfg.PoWTimeout = 50 * time.Millisecond
+	cfg.MaxConcurrentPoWPerIP = 1
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.powFn = hangingPoW
+
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+	if status != http.StatusServiceUnavailable || err != ErrPoWTimeout {
+		close(hang)
+		t.Fatalf("expected the PoW to time out with 503, got %d: %v", status, err)
+	}
+	// the hanging PoW keeps its slots until it returns
+	if active := interc.scheduler.active(); active != 1 || active > interc.scheduler.capacity() {
+		t.Errorf("expected the timed out PoW to keep its slot, %d of %d slots active", active, interc.scheduler.capacity())
+	}
+	statuses := make(chan int, 2)
+	for _, addr := range []string{"1.1.1.1:1234", "2.2.2.2:1234"} {
+		go func(addr string) {
+			status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, addr, 1, txTrytes(t, "TEST", 0)))
+			statuses <- status
+		}(addr)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if active := interc.scheduler.active(); active > interc.scheduler.capacity() {
+		t.Errorf("expected at most %d active slots, got %d", interc.scheduler.capacity(), active)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("expected no PoW to start beside the timed out one, %d started", n)
+	}
+
+	close(hang)
+	for range []int{0, 1} {
+		select {
+		case status := <-statuses:
+			if status != http.StatusOK {
+				t.Errorf("expected the waiting PoWs to succeed once the slots are free, got %d", status)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected the waiting PoWs to run once the timed out PoW returned")
+		}
+	}
+}
+
+func TestClientRequestedTimeout(t *testing.T) {
+	slowPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		time.Sleep(100 * time.Millisecond)
+		return consts.NullNonceTrytes, nil
+	}
+	cfg := newConfig()
+	cfg.ClientRequestedTimeout = true
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.powFn = slowPoW
+
+	attach := func(timeout string) (int, time.Duration, error) {
+		r := attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))
+		if timeout != "" {
+			r.Header.Set(headerPoWTimeout, timeout)
+		}
+		start := time.Now()
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), r)
+		return status, time.Since(start), err
+	}
+
+	status, elapsed, err := attach("50")
+	if status != http.StatusGatewayTimeout || err != ErrPoWTimeout {
+		t.Fatalf("expected the PoW to time out with 504, got %d: %v", status, err)
+	}
+	if elapsed < 50*time.Millisecond || elapsed > 90*time.Millisecond {
+		t.Errorf("expected the request to fail after about 50ms, took %v", elapsed)
+	}
+	// lets the PoW of the timed out request finish
+	time.Sleep(100 * time.Millisecond)
+
+	for _, timeout := range []string{"", "invalid", "-1", "5000"} {
+		if status, _, err := attach(timeout); status != http.StatusOK {
+			t.Errorf("expected a requested timeout of %q not to fail the request, got %d: %v", timeout, status, err)
+		}
+	}
+
+	// longer timeouts than the configured one are clamped to it
+	cfg.PoWTimeout = 50 * time.Millisecond
+	if status, _, err := attach("5000"); status != http.StatusServiceUnavailable || err != ErrPoWTimeout {
+		t.Errorf("expected the configured timeout to apply with 503, got %d: %v", status, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	cfg.ClientRequestedTimeout = false
+	if status, _, err := attach("50"); status != http.StatusServiceUnavailable || err != ErrPoWTimeout {
+		t.Errorf("expected requested timeouts to be ignored if disabled, got %d: %v", status, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+}
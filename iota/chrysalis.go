@@ -0,0 +1,52 @@
+package iota
+
+import (
+	"encoding/hex"
+	"hash/crc32"
+	"strings"
+
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+const (
+	outputFormatLegacy    = "legacy"
+	outputFormatChrysalis = "chrysalis"
+)
+
+// ChrysalisAttachToTangleRes is the attachToTangle response in the chrysalis output format.
+type ChrysalisAttachToTangleRes struct {
+	// hex encoded transaction bytes, see legacyTrytesToChrysalis
+	Transactions []string `json:"transactions"`
+	Duration     int64    `json:"duration"`
+	// CRC32 (IEEE) of the concatenated hex encoded transactions
+	Checksum       uint32 `json:"checksum"`
+	SkippedIndices []int  `json:"skippedIndices,omitempty"`
+}
+
+// legacyTrytesToChrysalis converts the given transaction trytes to bytes by packing
+// 5 trits into each byte (T5B1), the encoding Chrysalis uses for legacy trinary data.
+func legacyTrytesToChrysalis(trytes trinary.Trytes) ([]byte, error) {
+	trits, err := trinary.TrytesToTrits(trytes)
+	if err != nil {
+		return nil, err
+	}
+	return trinary.TritsToBytes(trits), nil
+}
+
+// toChrysalisRes converts the given response into the chrysalis output format.
+func toChrysalisRes(res *AttachToTangleRes) (*ChrysalisAttachToTangleRes, error) {
+	chrysalisRes := &ChrysalisAttachToTangleRes{
+		Transactions:   make([]string, len(res.Trytes)),
+		Duration:       res.Duration,
+		SkippedIndices: res.SkippedIndices,
+	}
+	for i, trytes := range res.Trytes {
+		txBytes, err := legacyTrytesToChrysalis(trytes)
+		if err != nil {
+			return nil, err
+		}
+		chrysalisRes.Transactions[i] = hex.EncodeToString(txBytes)
+	}
+	chrysalisRes.Checksum = crc32.ChecksumIEEE([]byte(strings.Join(chrysalisRes.Transactions, "")))
+	return chrysalisRes, nil
+}
@@ -0,0 +1,89 @@
+package iota
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+// chrysalisToTx decodes hex encoded chrysalis transaction bytes back into a transaction.
+func chrysalisToTx(t *testing.T, encoded string) *transaction.Transaction {
+	txBytes, err := hex.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("invalid hex: %v", err)
+	}
+	trits, err := trinary.BytesToTrits(txBytes, consts.TransactionTrinarySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := transaction.AsTransactionObject(trinary.MustTritsToTrytes(trits))
+	if err != nil {
+		t.Fatalf("decoded bytes aren't a transaction: %v", err)
+	}
+	return tx
+}
+
+func TestLegacyTrytesToChrysalis(t *testing.T) {
+	tx := testTx("CHRYSALIS", 42)
+	tx.Address = trinary.Pad("ADDRESS", consts.HashTrytesSize)
+	tx.Timestamp = 1561000000
+	trytes, err := transaction.TransactionToTrytes(&tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	txBytes, err := legacyTrytesToChrysalis(trytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := (consts.TransactionTrinarySize + 4) / 5; len(txBytes) != expected {
+		t.Fatalf("expected %d bytes, got %d", expected, len(txBytes))
+	}
+	decoded := chrysalisToTx(t, hex.EncodeToString(txBytes))
+	if decoded.Address != tx.Address || decoded.Value != tx.Value || decoded.Tag != tx.Tag || decoded.Timestamp != tx.Timestamp {
+		t.Errorf("decoded transaction doesn't match, got %+v", decoded)
+	}
+
+	if _, err := legacyTrytesToChrysalis("invalid trytes"); err == nil {
+		t.Error("expected invalid trytes to fail")
+	}
+}
+
+func TestOutputFormatChrysalis(t *testing.T) {
+	cfg := newConfig()
+	cfg.OutputFormat = outputFormatChrysalis
+	interc, _ := newTestInterceptor(t, cfg)
+	bundle := bundleTrytes(t, "kerl", testTx("FIRST", 0), testTx("SECOND", 0))
+
+	w := httptest.NewRecorder()
+	if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(w.Body.Bytes(), &fields); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if _, has := fields["trytes"]; has {
+		t.Error("expected no trytes in the chrysalis format")
+	}
+	res := &ChrysalisAttachToTangleRes{}
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Transactions) != 2 {
+		t.Fatalf("expected 2 transactions, got %d", len(res.Transactions))
+	}
+	tags := map[trinary.Trytes]bool{}
+	for _, encoded := range res.Transactions {
+		tags[chrysalisToTx(t, encoded).Tag] = true
+	}
+	if !tags[trinary.Pad("FIRST", 27)] || !tags[trinary.Pad("SECOND", 27)] {
+		t.Errorf("expected both transactions in the response, got tags %v", tags)
+	}
+}
@@ -0,0 +1,176 @@
+package iota
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/external"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultCloudWatchFlushInterval = 5 * time.Second
+	// the most events CloudWatch Logs accepts per PutLogEvents call
+	cloudWatchMaxBatch    = 1000
+	cloudWatchSendTimeout = 10 * time.Second
+)
+
+// auditEvent is the JSON message of a PoW audit event.
+type auditEvent struct {
+	Timestamp string `json:"timestamp"`
+	Event     string `json:"event"`
+	Status    int    `json:"status"`
+	logFields
+}
+
+// cloudWatchClient is the part of the CloudWatch Logs API the auditor uses.
+type cloudWatchClient interface {
+	PutLogEvents(ctx context.Context, input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error)
+}
+
+type sdkCloudWatchClient struct {
+	client *cloudwatchlogs.Client
+}
+
+// newSDKCloudWatchClient returns a client using the credentials of the environment
+// and the given region, or the one of the environment if empty.
+func newSDKCloudWatchClient(region string) (cloudWatchClient, error) {
+	var configs []external.Config
+	if region != "" {
+		configs = append(configs, external.WithRegion(region))
+	}
+	cfg, err := external.LoadDefaultAWSConfig(configs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load AWS config")
+	}
+	return &sdkCloudWatchClient{client: cloudwatchlogs.New(cfg)}, nil
+}
+
+func (c *sdkCloudWatchClient) PutLogEvents(ctx context.Context, input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	res, err := c.client.PutLogEventsRequest(input).Send(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return res.PutLogEventsOutput, nil
+}
+
+// cloudWatchAuditor batches PoW audit events and sends them to a CloudWatch Logs stream
+// every flush interval or once the batch is full.
+type cloudWatchAuditor struct {
+	client cloudWatchClient
+	group  string
+	stream string
+	logger *log.Logger
+
+	mu      sync.Mutex
+	pending []cloudwatchlogs.InputLogEvent
+	// serializes the sends as each needs the sequence token returned by the previous one
+	sendMu sync.Mutex
+	token  *string
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func newCloudWatchAuditor(client cloudWatchClient, group, stream string, interval time.Duration, logger *log.Logger) *cloudWatchAuditor {
+	a := &cloudWatchAuditor{client: client, group: group, stream: stream, logger: logger, stop: make(chan struct{}), done: make(chan struct{})}
+	go a.run(interval)
+	return a
+}
+
+func (a *cloudWatchAuditor) run(interval time.Duration) {
+	defer close(a.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			a.flush()
+		case <-a.stop:
+			return
+		}
+	}
+}
+
+// add queues the event and sends the batch if it is full.
+func (a *cloudWatchAuditor) add(event *auditEvent) {
+	msg, err := json.Marshal(event)
+	if err != nil {
+		a.logger.Printf("unable to encode audit event: %v\n", err)
+		return
+	}
+	a.mu.Lock()
+	a.pending = append(a.pending, cloudwatchlogs.InputLogEvent{
+		Message:   aws.String(string(msg)),
+		Timestamp: aws.Int64(time.Now().UnixNano() / int64(time.Millisecond)),
+	})
+	full := len(a.pending) >= cloudWatchMaxBatch
+	a.mu.Unlock()
+	if full {
+		a.flush()
+	}
+}
+
+// flush sends the queued events in batches of at most the maximum size.
+func (a *cloudWatchAuditor) flush() {
+	a.mu.Lock()
+	events := a.pending
+	a.pending = nil
+	a.mu.Unlock()
+	if len(events) == 0 {
+		return
+	}
+
+	// events of concurrent requests may be queued slightly out of order but
+	// CloudWatch Logs requires them in chronological order
+	sort.SliceStable(events, func(i, j int) bool { return *events[i].Timestamp < *events[j].Timestamp })
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+	for len(events) > 0 {
+		n := len(events)
+		if n > cloudWatchMaxBatch {
+			n = cloudWatchMaxBatch
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), cloudWatchSendTimeout)
+		out, err := a.client.PutLogEvents(ctx, &cloudwatchlogs.PutLogEventsInput{
+			LogEvents:     events[:n],
+			LogGroupName:  aws.String(a.group),
+			LogStreamName: aws.String(a.stream),
+			SequenceToken: a.token,
+		})
+		cancel()
+		if err != nil {
+			a.logger.Printf("unable to send %d audit events to CloudWatch Logs: %v\n", n, err)
+		} else {
+			a.token = out.NextSequenceToken
+		}
+		events = events[n:]
+	}
+}
+
+// close stops the periodic flushes and sends the queued events.
+func (a *cloudWatchAuditor) close() error {
+	close(a.stop)
+	<-a.done
+	a.flush()
+	return nil
+}
+
+// audit emits a PoW audit event if an audit destination is configured.
+func (interc *Interceptor) audit(event string, status int, fields logFields) {
+	if interc.auditor == nil {
+		return
+	}
+	interc.auditor.add(&auditEvent{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Event:     event,
+		Status:    status,
+		logFields: fields,
+	})
+}
@@ -0,0 +1,93 @@
+package iota
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+)
+
+// mockCloudWatch records the PutLogEvents calls and returns increasing sequence tokens.
+type mockCloudWatch struct {
+	mu     sync.Mutex
+	inputs []*cloudwatchlogs.PutLogEventsInput
+}
+
+func (m *mockCloudWatch) PutLogEvents(ctx context.Context, input *cloudwatchlogs.PutLogEventsInput) (*cloudwatchlogs.PutLogEventsOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inputs = append(m.inputs, input)
+	return &cloudwatchlogs.PutLogEventsOutput{NextSequenceToken: aws.String(strconv.Itoa(len(m.inputs)))}, nil
+}
+
+func (m *mockCloudWatch) calls() []*cloudwatchlogs.PutLogEventsInput {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*cloudwatchlogs.PutLogEventsInput(nil), m.inputs...)
+}
+
+func TestCloudWatchAuditor(t *testing.T) {
+	mock := &mockCloudWatch{}
+	interc, _ := newTestInterceptor(t, newConfig())
+	interc.auditor = newCloudWatchAuditor(mock, "iotacaddy", "pow", time.Hour, logger)
+
+	interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+	if calls := mock.calls(); len(calls) != 0 {
+		t.Fatalf("expected the event to be batched until the flush, got %d calls", len(calls))
+	}
+	interc.auditor.flush()
+	calls := mock.calls()
+	if len(calls) != 1 || len(calls[0].LogEvents) != 1 {
+		t.Fatalf("expected one call with the event, got %+v", calls)
+	}
+	input := calls[0]
+	if *input.LogGroupName != "iotacaddy" || *input.LogStreamName != "pow" || input.SequenceToken != nil {
+		t.Errorf("expected the configured stream without a sequence token, got %v", input)
+	}
+	event := &auditEvent{}
+	if err := json.Unmarshal([]byte(*input.LogEvents[0].Message), event); err != nil {
+		t.Fatalf("invalid audit event %s: %v", *input.LogEvents[0].Message, err)
+	}
+	if event.Event != "pow_done" || event.Status != http.StatusOK || event.RemoteAddr != "1.1.1.1" || event.TxCount != 1 || event.BundleHash == "" {
+		t.Errorf("expected the PoW's audit event, got %+v", event)
+	}
+	if *input.LogEvents[0].Timestamp <= 0 {
+		t.Error("expected the event to have a timestamp")
+	}
+
+	// full batches are sent right away, the rest on close
+	for i := 0; i < cloudWatchMaxBatch+1; i++ {
+		interc.audit("pow_done", http.StatusOK, logFields{})
+	}
+	calls = mock.calls()
+	if len(calls) != 2 || len(calls[1].LogEvents) != cloudWatchMaxBatch {
+		t.Fatalf("expected a full batch to be sent, got %d calls", len(calls))
+	}
+	if calls[1].SequenceToken == nil || *calls[1].SequenceToken != "1" {
+		t.Errorf("expected the sequence token of the previous call, got %v", calls[1].SequenceToken)
+	}
+	if err := interc.auditor.close(); err != nil {
+		t.Fatal(err)
+	}
+	if calls = mock.calls(); len(calls) != 3 || len(calls[2].LogEvents) != 1 {
+		t.Errorf("expected the remaining event to be sent on close, got %d calls", len(calls))
+	}
+}
+
+func TestCloudWatchFlushInterval(t *testing.T) {
+	mock := &mockCloudWatch{}
+	auditor := newCloudWatchAuditor(mock, "iotacaddy", "pow", 20*time.Millisecond, logger)
+	defer auditor.close()
+	auditor.add(&auditEvent{Event: "pow_failed", Status: http.StatusInternalServerError})
+	time.Sleep(100 * time.Millisecond)
+	if calls := mock.calls(); len(calls) != 1 {
+		t.Errorf("expected the event to be sent after the flush interval, got %d calls", len(calls))
+	}
+}
@@ -0,0 +1,92 @@
+package iota
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+	"github.com/pkg/errors"
+)
+
+const defaultConfirmationCacheTTL = 30 * time.Second
+
+type confirmationEntry struct {
+	confirmed bool
+	fetched   time.Time
+}
+
+// confirmationChecker rejects branch transactions which aren't confirmed. The inclusion
+// states are looked up via getInclusionStates on the next handler and cached for the configured TTL.
+type confirmationChecker struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	cache map[trinary.Hash]confirmationEntry
+}
+
+func newConfirmationChecker(ttl time.Duration) *confirmationChecker {
+	return &confirmationChecker{ttl: ttl, cache: map[trinary.Hash]confirmationEntry{}}
+}
+
+// check returns ErrBranchNotConfirmed if the given branch transaction isn't confirmed.
+func (c *confirmationChecker) check(next httpserver.Handler, r *http.Request, branch trinary.Hash) error {
+	now := time.Now()
+	c.mu.Lock()
+	entry, ok := c.cache[branch]
+	c.mu.Unlock()
+
+	if !ok || now.Sub(entry.fetched) >= c.ttl {
+		states := &getInclusionStatesRes{}
+		if err := callIRI(r.Context(), next, r, &getInclusionStatesReq{Command: getInclusionStatesCommand, Transactions: []trinary.Hash{branch}}, states); err != nil {
+			return errors.Wrap(err, "getInclusionStates failed")
+		}
+		if len(states.States) != 1 {
+			return errors.Errorf("getInclusionStates returned %d states for 1 transaction", len(states.States))
+		}
+		entry = confirmationEntry{confirmed: states.States[0], fetched: now}
+		c.mu.Lock()
+		for hash, cached := range c.cache {
+			if now.Sub(cached.fetched) >= c.ttl {
+				delete(c.cache, hash)
+			}
+		}
+		c.cache[branch] = entry
+		c.mu.Unlock()
+	}
+
+	if !entry.confirmed {
+		return errors.Wrapf(ErrBranchNotConfirmed, "%s", branch)
+	}
+	return nil
+}
+
+// checkAlreadyConfirmed returns ErrAlreadyConfirmed if at least min transactions of the bundle
+// are confirmed. IRI only reports whether a transaction is confirmed, not how often. Re-attached
+// bundles still carry the trytes of their previous attachment, so their transaction hashes are
+// those of the attached transactions.
+func checkAlreadyConfirmed(next httpserver.Handler, r *http.Request, transactions []transaction.Transaction, min int) error {
+	hashes := make([]trinary.Hash, len(transactions))
+	for i := range transactions {
+		hashes[i] = transactions[i].Hash
+	}
+	states := &getInclusionStatesRes{}
+	if err := callIRI(r.Context(), next, r, &getInclusionStatesReq{Command: getInclusionStatesCommand, Transactions: hashes}, states); err != nil {
+		return errors.Wrap(err, "getInclusionStates failed")
+	}
+	if len(states.States) != len(hashes) {
+		return errors.Errorf("getInclusionStates returned %d states for %d transactions", len(states.States), len(hashes))
+	}
+	var confirmed int
+	for _, state := range states.States {
+		if state {
+			confirmed++
+		}
+	}
+	if confirmed >= min {
+		return errors.Wrapf(ErrAlreadyConfirmed, "%d of %d transactions are confirmed", confirmed, len(hashes))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func TestRequireConfirmedBranch(t *testing.T) {
+	confirmed := trinary.Pad("CONFIRMED", consts.HashTrytesSize)
+	unconfirmed := trinary.Pad("UNCONFIRMED", consts.HashTrytesSize)
+
+	cfg := newConfig()
+	cfg.RequireConfirmedBranch = true
+	cfg.ConfirmationCacheTTL = 50 * time.Millisecond
+	interc, _ := newTestInterceptor(t, cfg)
+	iri := &mockIRI{txs: map[trinary.Hash]trinary.Trytes{}, confirmed: map[trinary.Hash]bool{confirmed: true}}
+	interc.Next = iri
+
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), tipAttachRequest(t, confirmed, unconfirmed))
+	if status != http.StatusBadRequest || errors.Cause(err) != ErrBranchNotConfirmed {
+		t.Errorf("expected an unconfirmed branch to be rejected, got %d: %v", status, err)
+	}
+	for i := 0; i < 2; i++ {
+		if status, err := interc.ServeHTTP(httptest.NewRecorder(), tipAttachRequest(t, unconfirmed, confirmed)); status != http.StatusOK {
+			t.Fatalf("expected a confirmed branch to be accepted, got %d: %v", status, err)
+		}
+	}
+	if iri.inclusionLookups != 2 {
+		t.Errorf("expected the inclusion state of each branch to be looked up once, got %d lookups", iri.inclusionLookups)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), tipAttachRequest(t, unconfirmed, confirmed)); status != http.StatusOK {
+		t.Fatalf("expected a confirmed branch to be accepted, got %d: %v", status, err)
+	}
+	if iri.inclusionLookups != 3 {
+		t.Errorf("expected the inclusion state to be looked up again after the TTL, got %d lookups", iri.inclusionLookups)
+	}
+}
+
+func TestMinConfirmationsBeforeReattach(t *testing.T) {
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 0), testTx("TEST", 0), testTx("TEST", 0))
+	hashes := make([]trinary.Hash, len(bundle))
+	for i, trytes := range bundle {
+		tx, err := transaction.AsTransactionObject(trytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashes[i] = tx.Hash
+	}
+
+	for _, test := range []struct {
+		name      string
+		min       int
+		confirmed []trinary.Hash
+		expected  int
+	}{
+		{"none confirmed", 1, nil, http.StatusOK},
+		{"one confirmed", 1, hashes[:1], http.StatusConflict},
+		{"fewer confirmed than required", 3, hashes[:2], http.StatusOK},
+		{"all confirmed", 3, hashes, http.StatusConflict},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := newConfig()
+			cfg.MinConfirmationsBeforeReattach = test.min
+			interc, _ := newTestInterceptor(t, cfg)
+			iri := &mockIRI{txs: map[trinary.Hash]trinary.Trytes{}, confirmed: map[trinary.Hash]bool{}}
+			for _, hash := range test.confirmed {
+				iri.confirmed[hash] = true
+			}
+			interc.Next = iri
+
+			status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+			if status != test.expected {
+				t.Fatalf("expected %d, got %d: %v", test.expected, status, err)
+			}
+			if test.expected == http.StatusConflict && errors.Cause(err) != ErrAlreadyConfirmed {
+				t.Errorf("expected the bundle to be already confirmed, got %v", err)
+			}
+			if iri.inclusionLookups != 1 {
+				t.Errorf("expected the inclusion states to be looked up once, got %d lookups", iri.inclusionLookups)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package iota
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+)
+
+// read-only IRI commands which are deduplicated if no explicit list is configured
+var defaultDedupCommands = []string{"getBalances", "getInclusionStates", "getTrytes", "findTransactions"}
+
+// bufferedResponse captures a response written by the next handler so it can be
+// replayed to every caller waiting on the same upstream call.
+type bufferedResponse struct {
+	header http.Header
+	code   int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.code == 0 {
+		b.code = code
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.code == 0 {
+		b.code = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+type forwardResult struct {
+	res    *bufferedResponse
+	status int
+}
+
+// forwardDeduplicated forwards the request but collapses concurrent identical requests
+// into a single call to the next handler.
+func (interc *Interceptor) forwardDeduplicated(w http.ResponseWriter, r *http.Request, command string, contents []byte) (int, error) {
+	hash := sha256.Sum256(contents)
+	key := command + ":" + hex.EncodeToString(hash[:])
+	v, err, _ := interc.dedupGroup.Do(key, func() (interface{}, error) {
+		res := &bufferedResponse{header: http.Header{}}
+		status, err := interc.Next.ServeHTTP(res, r)
+		return &forwardResult{res: res, status: status}, err
+	})
+	result := v.(*forwardResult)
+	for k, values := range result.res.header {
+		w.Header()[k] = append([]string(nil), values...)
+	}
+	if result.res.code != 0 {
+		w.WriteHeader(result.res.code)
+		if _, err := w.Write(result.res.body.Bytes()); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	return result.status, err
+}
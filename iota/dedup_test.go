@@ -0,0 +1,64 @@
+package iota
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+)
+
+func TestDedupReadOnlyCommands(t *testing.T) {
+	var upstreamCalls int32
+	cfg := newConfig()
+	cfg.DedupCommands = defaultDedupCommands
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.Next = httpserver.HandlerFunc(func(w http.ResponseWriter, r *http.Request) (int, error) {
+		atomic.AddInt32(&upstreamCalls, 1)
+		// keep the call in flight until all clients have sent their request
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set(contentType, contentTypeJSON)
+		w.Write([]byte(`{"balances":["100"]}`))
+		return http.StatusOK, nil
+	})
+
+	body := []byte(`{"command":"getBalances","addresses":["ABC"],"threshold":100}`)
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			if _, err := interc.ServeHTTP(w, r); err != nil {
+				t.Error(err)
+			}
+			if w.Header().Get(contentType) != contentTypeJSON {
+				t.Error("expected upstream headers to be replayed")
+			}
+			bodies[i] = w.Body.String()
+		}(i)
+	}
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&upstreamCalls); calls != 1 {
+		t.Errorf("expected 1 upstream call, got %d", calls)
+	}
+	for i, b := range bodies {
+		if b != `{"balances":["100"]}` {
+			t.Errorf("client %d: unexpected response %q", i, b)
+		}
+	}
+
+	// commands not in the list are always forwarded
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"command":"getNodeInfo"}`)))
+	interc.ServeHTTP(httptest.NewRecorder(), r)
+	if calls := atomic.LoadInt32(&upstreamCalls); calls != 2 {
+		t.Errorf("expected getNodeInfo to be forwarded, got %d upstream calls", calls)
+	}
+}
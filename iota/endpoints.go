@@ -0,0 +1,93 @@
+package iota
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// build information, set via -ldflags "-X github.com/mholt/caddy/iota.Version=v1.2.3 ..."
+var (
+	Version   = "v0.0.0-dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+const (
+	versionPath = "/iota/version"
+	pubKeyPath  = "/iota/pubkey"
+)
+
+type versionRes struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+type pubKeyRes struct {
+	Algorithm string `json:"algorithm"`
+	// base64url encoded like the response signatures
+	PublicKey string `json:"public_key"`
+}
+
+// serveEndpoint serves the plugin's own GET endpoints and reports whether the request was handled.
+func (interc *Interceptor) serveEndpoint(w http.ResponseWriter, r *http.Request) (bool, int, error) {
+	if r.Method != http.MethodGet {
+		return false, 0, nil
+	}
+	if interc.metrics != nil && r.URL.Path == interc.Config.MetricsPath {
+		if status, err := interc.checkAdminAccess(r); err != nil {
+			return true, status, err
+		}
+		interc.metrics.ServeHTTP(w, r)
+		return true, http.StatusOK, nil
+	}
+	if interc.resultRouter != nil && strings.HasPrefix(r.URL.Path, resultsPath) {
+		status, err := interc.serveResult(w, r)
+		return true, status, err
+	}
+	switch r.URL.Path {
+	case statsPath:
+		if interc.tagStats == nil {
+			return false, 0, nil
+		}
+		if status, err := interc.checkAdminAccess(r); err != nil {
+			return true, status, err
+		}
+		status, err := writeJSON(w, &statsRes{
+			TotalRequests: atomic.LoadUint64(&interc.totalRequests),
+			TotalErrors:   atomic.LoadUint64(&interc.totalErrors),
+			TagStats:      interc.tagStats.snapshot(),
+		})
+		return true, status, err
+	case versionPath:
+		status, err := writeJSON(w, &versionRes{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()})
+		return true, status, err
+	case pubKeyPath:
+		if interc.signingKey == nil {
+			return false, 0, nil
+		}
+		pubKey := interc.signingKey.Public().(ed25519.PublicKey)
+		status, err := writeJSON(w, &pubKeyRes{Algorithm: "ed25519", PublicKey: signatureEncoding.EncodeToString(pubKey)})
+		return true, status, err
+	}
+	return false, 0, nil
+}
+
+// writeJSON writes the given object as the JSON response body.
+func writeJSON(w http.ResponseWriter, obj interface{}) (int, error) {
+	resBytes, err := json.Marshal(obj)
+	if err != nil {
+		return http.StatusInternalServerError, ErrBuildingRes
+	}
+	w.Header().Set(contentType, contentTypeJSON)
+	if _, err := w.Write(resBytes); err != nil {
+		return http.StatusInternalServerError, ErrBuildingRes
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,118 @@
+package iota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var semverPattern = regexp.MustCompile(`^v\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?$`)
+
+func TestVersionEndpoint(t *testing.T) {
+	interc, next := newTestInterceptor(t, newConfig())
+	w := httptest.NewRecorder()
+	status, err := interc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, versionPath, nil))
+	if status != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	if next.calls != 0 {
+		t.Error("expected the version request not to be forwarded")
+	}
+	res := &versionRes{}
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if !semverPattern.MatchString(res.Version) {
+		t.Errorf("expected a semantic version, got %s", res.Version)
+	}
+	if res.Commit == "" || res.BuildTime == "" || res.GoVersion == "" {
+		t.Errorf("expected all fields to be set, got %+v", res)
+	}
+
+	// other methods on the same path go to IRI
+	interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, versionPath, nil))
+	if next.calls != 1 {
+		t.Error("expected POST to be forwarded")
+	}
+}
+
+func TestMetricsEndpoint(t *testing.T) {
+	cfg := newConfig()
+	cfg.MetricsPath = "/metrics"
+	interc, next := newTestInterceptor(t, cfg)
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "", 0))); status != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+
+	w := httptest.NewRecorder()
+	status, err := interc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if status != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	if next.calls != 0 {
+		t.Error("expected the metrics request not to be forwarded")
+	}
+	for _, name := range []string{"iotacaddy_pow_requests_total", "iotacaddy_pow_failures_total", "iotacaddy_pow_duration_ms_count", "iotacaddy_active_pow_workers"} {
+		if !strings.Contains(w.Body.String(), name) {
+			t.Errorf("expected metric %s in %s", name, w.Body.String())
+		}
+	}
+
+	// without the option the path goes to IRI
+	interc, next = newTestInterceptor(t, newConfig())
+	interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if next.calls != 1 {
+		t.Error("expected the metrics request to be forwarded")
+	}
+}
+
+func TestAdminStatus(t *testing.T) {
+	cfg := newConfig()
+	cfg.AdminPath = "/_iotacaddy/status"
+	rule, err := parseAccessRule(true, "1.1.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AccessRules = []accessRule{rule}
+	interc, next := newTestInterceptor(t, cfg)
+	admin := &adminStatus{interc: interc, next: interc}
+
+	admin.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+	admin.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", cfg.MaxMWM+1, txTrytes(t, "TEST", 0)))
+
+	r := httptest.NewRequest(http.MethodGet, cfg.AdminPath, nil)
+	r.RemoteAddr = "1.1.1.1:1234"
+	w := httptest.NewRecorder()
+	if status, err := admin.ServeHTTP(w, r); status != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	res := &adminStatusRes{}
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+	}
+	if res.PoWImplName != "Null" || res.MaxMWM != cfg.MaxMWM || res.MaxTxInBundle != cfg.MaxTxInBundle {
+		t.Errorf("expected the configuration in the status, got %+v", res)
+	}
+	if res.TotalRequests != 2 || res.TotalErrors != 1 || res.ActiveWorkers != 0 || res.QueuedJobs != 0 {
+		t.Errorf("expected 2 requests of which 1 failed and no PoWs, got %+v", res)
+	}
+	if next.calls != 0 {
+		t.Error("expected the status request not to be forwarded")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, cfg.AdminPath, nil)
+	r.RemoteAddr = "2.2.2.2:1234"
+	if status, err := admin.ServeHTTP(httptest.NewRecorder(), r); status != http.StatusForbidden || err != ErrAccessDenied {
+		t.Errorf("expected IPs denied by the access rules to be rejected, got %d: %v", status, err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "1.1.1.1:1234"
+	admin.ServeHTTP(httptest.NewRecorder(), r)
+	if next.calls != 1 {
+		t.Error("expected other paths to be passed to the interceptor")
+	}
+}
@@ -0,0 +1,58 @@
+package iota
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/iotaledger/iota.go/pow"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+// PoWImpl is a named Proof of Work implementation.
+type PoWImpl struct {
+	Name string
+	Fn   pow.ProofOfWorkFunc
+}
+
+// fallbackPoWFunc returns a ProofOfWorkFunc which tries the given implementations in order
+// and returns the result of the first one succeeding. A panicking implementation counts as failed.
+func (interc *Interceptor) fallbackPoWFunc(impls ...PoWImpl) pow.ProofOfWorkFunc {
+	return func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		var lastErr error
+		for i, impl := range impls {
+			nonce, err := safePoW(impl.Fn, trytes, mwm, parallelism...)
+			if err == nil {
+				return nonce, nil
+			}
+			lastErr = err
+			if i < len(impls)-1 {
+				interc.logEntry(levelWarn, "pow_fallback", logFields{}, "PoW implementation %s failed (%v), falling back to %s\n", impl.Name, err, impls[i+1].Name)
+			}
+		}
+		return "", errors.Wrap(lastErr, "all PoW implementations failed")
+	}
+}
+
+func safePoW(fn pow.ProofOfWorkFunc, trytes trinary.Trytes, mwm int, parallelism ...int) (nonce trinary.Trytes, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return fn(trytes, mwm, parallelism...)
+}
+
+// lookupPoWImpl finds the PoW implementation with the given case-insensitive name.
+func lookupPoWImpl(name string) (PoWImpl, error) {
+	for _, available := range pow.GetProofOfWorkImplementations() {
+		if strings.EqualFold(available, name) {
+			fn, err := pow.GetProofOfWorkImpl(available)
+			if err != nil {
+				return PoWImpl{}, err
+			}
+			return PoWImpl{Name: available, Fn: fn}, nil
+		}
+	}
+	return PoWImpl{}, errors.Errorf("PoW implementation %s is not available in this build, available are %v", name, pow.GetProofOfWorkImplementations())
+}
@@ -0,0 +1,59 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func TestFallbackPoWFunc(t *testing.T) {
+	var calls []string
+	failing := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		calls = append(calls, "failing")
+		return "", errors.New("device lost")
+	}
+	panicking := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		calls = append(calls, "panicking")
+		panic("driver crash")
+	}
+	working := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		calls = append(calls, "working")
+		return consts.NullNonceTrytes, nil
+	}
+
+	cfg := newConfig()
+	interc, _ := newTestInterceptor(t, cfg)
+	fn := interc.fallbackPoWFunc(PoWImpl{"failing", failing}, PoWImpl{"panicking", panicking}, PoWImpl{"working", working})
+	nonce, err := fn(consts.NullHashTrytes, 1)
+	if err != nil {
+		t.Fatalf("expected the third implementation to succeed, got: %v", err)
+	}
+	if nonce != consts.NullNonceTrytes {
+		t.Errorf("unexpected nonce %s", nonce)
+	}
+	if len(calls) != 3 || calls[2] != "working" {
+		t.Errorf("expected implementations to be tried in order, got %v", calls)
+	}
+
+	interc.powFn = interc.fallbackPoWFunc(PoWImpl{"failing", failing}, PoWImpl{"panicking", panicking})
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusInternalServerError || errors.Cause(err) != ErrExecutingProofOfWork {
+		t.Errorf("expected PoW to fail when all implementations fail, got %d: %v", status, err)
+	}
+}
+
+func TestLookupPoWImpl(t *testing.T) {
+	impl, err := lookupPoWImpl("syncgo")
+	if err != nil {
+		t.Fatalf("expected SyncGo to be available: %v", err)
+	}
+	if impl.Name != "SyncGo" || impl.Fn == nil {
+		t.Errorf("unexpected implementation %+v", impl)
+	}
+	if _, err := lookupPoWImpl("openCL"); err == nil {
+		t.Error("expected unknown implementation to fail")
+	}
+}
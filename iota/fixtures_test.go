@@ -0,0 +1,142 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+// fixtureExpectation is the content of a testdata/bundles/<name>_expected.json file.
+type fixtureExpectation struct {
+	Description string `json:"description"`
+	Status      int    `json:"status"`
+	// message of the returned error
+	Error string `json:"error"`
+	// response headers which have to be set to the given values
+	Headers map[string]string `json:"headers"`
+	// the response body normalized by normalizeFixtureBody, absent for errors
+	Body json.RawMessage `json:"body"`
+}
+
+// normalizeFixtureBody zeroes the fields of an attachToTangle response which depend on
+// the time of the PoW: the attachment timestamps of the trytes, trunks pointing to another
+// transaction of the response as those hash the timestamps, the duration and the checksum,
+// which is recomputed over the normalized trytes after verifying it.
+func normalizeFixtureBody(t *testing.T, body []byte) map[string]interface{} {
+	res := &AttachToTangleRes{}
+	if err := json.Unmarshal(body, res); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	if res.Checksum != trytesChecksum(res.Trytes) {
+		t.Errorf("expected checksum %d, got %d", trytesChecksum(res.Trytes), res.Checksum)
+	}
+	txs, err := transaction.AsTransactionObjects(res.Trytes, nil)
+	if err != nil {
+		t.Fatalf("invalid trytes in response: %v", err)
+	}
+	hashes := map[trinary.Hash]bool{}
+	for i := range txs {
+		hashes[txs[i].Hash] = true
+	}
+	normalized := make([]trinary.Trytes, len(txs))
+	for i := range txs {
+		tx := &txs[i]
+		tx.AttachmentTimestamp, tx.AttachmentTimestampLowerBound, tx.AttachmentTimestampUpperBound = 0, 0, 0
+		if hashes[tx.TrunkTransaction] {
+			tx.TrunkTransaction = consts.NullHashTrytes
+		}
+		normalized[i] = transaction.MustTransactionToTrytes(tx)
+	}
+
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	fields["trytes"] = normalized
+	fields["duration"] = 0
+	fields["checksum"] = trytesChecksum(normalized)
+	// round trip so the values have the same types as the decoded expectation
+	data, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields = map[string]interface{}{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		t.Fatal(err)
+	}
+	return fields
+}
+
+func TestBundleFixtures(t *testing.T) {
+	expectations, err := filepath.Glob(filepath.Join("testdata", "bundles", "*_expected.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expectations) == 0 {
+		t.Fatal("no fixtures found")
+	}
+
+	cfg := newConfig()
+	cfg.MaxTxInBundle = 4
+	for _, expectationFile := range expectations {
+		name := strings.TrimSuffix(filepath.Base(expectationFile), "_expected.json")
+		t.Run(name, func(t *testing.T) {
+			exp := &fixtureExpectation{}
+			readJSON(t, expectationFile, exp)
+			body, err := ioutil.ReadFile(filepath.Join("testdata", "bundles", name+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			interc, _ := newTestInterceptor(t, cfg)
+			w := httptest.NewRecorder()
+			status, err := interc.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+			if status != exp.Status {
+				t.Fatalf("%s: expected status %d, got %d: %v", exp.Description, exp.Status, status, err)
+			}
+			for header, value := range exp.Headers {
+				if got := w.Header().Get(header); got != value {
+					t.Errorf("expected header %s to be %q, got %q", header, value, got)
+				}
+			}
+			if exp.Error != "" {
+				if err == nil || err.Error() != exp.Error {
+					t.Errorf("expected error %q, got %v", exp.Error, err)
+				}
+				// the error response is written by caddy
+				if w.Body.Len() != 0 {
+					t.Errorf("expected no body to be written, got %s", w.Body.String())
+				}
+				return
+			}
+			expected := map[string]interface{}{}
+			if err := json.Unmarshal(exp.Body, &expected); err != nil {
+				t.Fatalf("invalid expected body: %v", err)
+			}
+			if actual := normalizeFixtureBody(t, w.Body.Bytes()); !reflect.DeepEqual(actual, expected) {
+				data, _ := json.Marshal(actual)
+				t.Errorf("unexpected response body, got %s", data)
+			}
+		})
+	}
+}
+
+func readJSON(t *testing.T, file string, obj interface{}) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, obj); err != nil {
+		t.Fatalf("invalid JSON in %s: %v", file, err)
+	}
+}
@@ -0,0 +1,190 @@
+//go:build integration
+// +build integration
+
+package iota_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/mholt/caddy"
+	_ "github.com/mholt/caddy/caddyhttp/httpserver"
+	_ "github.com/mholt/caddy/caddyhttp/proxy"
+	"github.com/mholt/caddy/iota"
+)
+
+// startCaddy starts a Caddy instance intercepting the calls to the given IRI
+// and returns the URL it listens on.
+func startCaddy(t *testing.T, iriURL string) (*caddy.Instance, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	caddyfile := fmt.Sprintf(`http://%s {
+	iota 14 4 {
+		logfile -
+	}
+	proxy / %s
+}`, addr, iriURL)
+	inst, err := caddy.Start(caddy.CaddyfileInput{Contents: []byte(caddyfile), ServerTypeName: "http"})
+	if err != nil {
+		t.Fatalf("unable to start Caddy: %v", err)
+	}
+	return inst, "http://" + addr
+}
+
+// fixture returns the request body of the given fixture in testdata/bundles.
+func fixture(t *testing.T, name string) []byte {
+	body, err := ioutil.ReadFile(filepath.Join("testdata", "bundles", name+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func post(t *testing.T, url string, body []byte) (*http.Response, []byte) {
+	res, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, resBody
+}
+
+// checkPoWed verifies that the response holds the PoWed transactions of the request.
+func checkPoWed(t *testing.T, resBody []byte, txs int) {
+	res := &iota.AttachToTangleRes{}
+	if err := json.Unmarshal(resBody, res); err != nil {
+		t.Fatalf("invalid response %s: %v", resBody, err)
+	}
+	if len(res.Trytes) != txs {
+		t.Fatalf("expected %d transactions, got %d", txs, len(res.Trytes))
+	}
+	for _, trytes := range res.Trytes {
+		tx, err := transaction.AsTransactionObject(trytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !transaction.HasValidNonce(tx, 1) {
+			t.Errorf("expected transaction %s to have a valid nonce", tx.Hash)
+		}
+	}
+}
+
+func TestIntegration(t *testing.T) {
+	var iriCalls int32
+	iri := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&iriCalls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"appName":"IRI","appVersion":"1.8.1"}`))
+	}))
+	defer iri.Close()
+
+	inst, url := startCaddy(t, iri.URL)
+	defer inst.Stop()
+
+	t.Run("attachToTangle is done locally", func(t *testing.T) {
+		before := atomic.LoadInt32(&iriCalls)
+		res, body := post(t, url, fixture(t, "value_3tx"))
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", res.StatusCode, body)
+		}
+		checkPoWed(t, body, 3)
+		if res.Header.Get("X-IOTA-PoW-Impl") == "" {
+			t.Error("expected the PoW implementation header to be set")
+		}
+		if atomic.LoadInt32(&iriCalls) != before {
+			t.Error("expected attachToTangle not to be forwarded to IRI")
+		}
+	})
+
+	t.Run("other commands are forwarded", func(t *testing.T) {
+		before := atomic.LoadInt32(&iriCalls)
+		res, body := post(t, url, []byte(`{"command":"getNodeInfo"}`))
+		if res.StatusCode != http.StatusOK || !bytes.Contains(body, []byte(`"appName":"IRI"`)) {
+			t.Fatalf("expected IRI's response, got %d: %s", res.StatusCode, body)
+		}
+		if atomic.LoadInt32(&iriCalls) != before+1 {
+			t.Error("expected the command to be forwarded to IRI")
+		}
+	})
+
+	t.Run("MWM above the maximum is rejected", func(t *testing.T) {
+		req := map[string]interface{}{}
+		if err := json.Unmarshal(fixture(t, "data_1tx"), &req); err != nil {
+			t.Fatal(err)
+		}
+		req["minWeightMagnitude"] = 15
+		body, _ := json.Marshal(req)
+		if res, resBody := post(t, url, body); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d: %s", res.StatusCode, resBody)
+		}
+	})
+
+	t.Run("bundles exceeding the limit are rejected", func(t *testing.T) {
+		if res, body := post(t, url, fixture(t, "exceeding_limit")); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d: %s", res.StatusCode, body)
+		}
+	})
+
+	t.Run("corrupt trytes are rejected", func(t *testing.T) {
+		if res, body := post(t, url, fixture(t, "corrupt_trytes")); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400, got %d: %s", res.StatusCode, body)
+		}
+	})
+
+	t.Run("concurrent attachToTangle calls", func(t *testing.T) {
+		body := fixture(t, "data_1tx")
+		statuses := make([]int, 8)
+		bodies := make([][]byte, len(statuses))
+		var wg sync.WaitGroup
+		for i := range statuses {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				res, err := http.Post(url, "application/json", bytes.NewReader(body))
+				if err != nil {
+					return
+				}
+				defer res.Body.Close()
+				statuses[i] = res.StatusCode
+				bodies[i], _ = ioutil.ReadAll(res.Body)
+			}(i)
+		}
+		wg.Wait()
+		for i, status := range statuses {
+			if status != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %s", status, bodies[i])
+			}
+			checkPoWed(t, bodies[i], 1)
+		}
+	})
+
+	t.Run("version endpoint", func(t *testing.T) {
+		res, err := http.Get(url + "/iota/version")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			t.Errorf("expected 200, got %d", res.StatusCode)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package iota
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+const interruptAttachingToTangleCommand = "interruptAttachingToTangle"
+
+// interruptibleJob is an attachToTangle PoW waiting in the queue or running.
+type interruptibleJob struct {
+	// closed once the job is interrupted
+	interrupted chan struct{}
+	cancel      context.CancelFunc
+}
+
+// isInterrupted reports whether the job was interrupted by interruptAttachingToTangle.
+func (j *interruptibleJob) isInterrupted() bool {
+	select {
+	case <-j.interrupted:
+		return true
+	default:
+		return false
+	}
+}
+
+// powInterrupts keeps the PoW jobs per client IP so interruptAttachingToTangle calls only
+// interrupt the PoWs of the calling client.
+type powInterrupts struct {
+	mu   sync.Mutex
+	jobs map[string]map[*interruptibleJob]struct{}
+}
+
+func newPoWInterrupts() *powInterrupts {
+	return &powInterrupts{jobs: map[string]map[*interruptibleJob]struct{}{}}
+}
+
+// register adds a job of the IP and returns it with its context, derived from the given one
+// and canceled on interruption. Each register must be followed by an unregister.
+func (p *powInterrupts) register(ctx context.Context, ip string) (context.Context, *interruptibleJob) {
+	ctx, cancel := context.WithCancel(ctx)
+	job := &interruptibleJob{interrupted: make(chan struct{}), cancel: cancel}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.jobs[ip] == nil {
+		p.jobs[ip] = map[*interruptibleJob]struct{}{}
+	}
+	p.jobs[ip][job] = struct{}{}
+	return ctx, job
+}
+
+func (p *powInterrupts) unregister(ip string, job *interruptibleJob) {
+	job.cancel()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.jobs[ip], job)
+	if len(p.jobs[ip]) == 0 {
+		delete(p.jobs, ip)
+	}
+}
+
+// interrupt interrupts all jobs of the IP and returns how many there were.
+func (p *powInterrupts) interrupt(ip string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	jobs := p.jobs[ip]
+	for job := range jobs {
+		close(job.interrupted)
+		job.cancel()
+	}
+	delete(p.jobs, ip)
+	return len(jobs)
+}
+
+// powAborted logs and returns the error of a PoW job whose context was canceled, either as
+// the client interrupted it or disconnected.
+func (interc *Interceptor) powAborted(job *interruptibleJob, fields logFields, bundle trinary.Hash) (int, error) {
+	if job.isInterrupted() {
+		interc.logEntry(levelWarn, "pow_interrupted", fields, "client %s interrupted PoW for bundle %s\n", fields.RemoteAddr, interc.logHash(bundle))
+		interc.audit("pow_interrupted", http.StatusBadRequest, fields)
+		return http.StatusBadRequest, ErrPoWInterrupted
+	}
+	interc.logEntry(levelWarn, "pow_canceled", fields, "client %s disconnected, canceled PoW for bundle %s\n", fields.RemoteAddr, interc.logHash(bundle))
+	interc.audit("pow_canceled", statusClientClosedRequest, fields)
+	return statusClientClosedRequest, ErrPoWCanceled
+}
+
+// serveInterruptAttachingToTangle interrupts the queued and running PoWs of the client.
+// Queued jobs leave the queue right away, running ones stop before their next transaction.
+// Like IRI, it succeeds with an empty response if there's nothing to interrupt.
+func (interc *Interceptor) serveInterruptAttachingToTangle(w http.ResponseWriter, ip string) (int, error) {
+	if interrupted := interc.interrupts.interrupt(ip); interrupted > 0 {
+		interc.logEntry(levelInfo, "interrupt_attaching", logFields{RemoteAddr: ip}, "interrupting %d PoWs of %s\n", interrupted, ip)
+	}
+	interc.setCORSHeaders(w)
+	return writeJSON(w, struct{}{})
+}
@@ -0,0 +1,113 @@
+package iota
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+func interruptRequest(remoteAddr string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(`{"command":"interruptAttachingToTangle"}`))
+	req.RemoteAddr = remoteAddr
+	return req
+}
+
+type attachResult struct {
+	status int
+	err    error
+}
+
+func TestInterruptAttachingToTangle(t *testing.T) {
+	started := make(chan struct{}, 10)
+	gate := make(chan struct{})
+	blockingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		started <- struct{}{}
+		<-gate
+		return consts.NullNonceTrytes, nil
+	}
+	interc, next := newTestInterceptor(t, newConfig())
+	interc.powFn = blockingPoW
+
+	tx := txTrytes(t, "TEST", 0)
+	attach := func(remoteAddr string, trytes ...trinary.Trytes) chan attachResult {
+		done := make(chan attachResult, 1)
+		go func() {
+			status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, remoteAddr, 1, trytes...))
+			done <- attachResult{status, err}
+		}()
+		return done
+	}
+	running := attach("1.1.1.1:1234", dataBundleTrytes(t, "TEST", 3)...)
+	<-started
+	queued := attach("1.1.1.1:1234", tx)
+	other := attach("2.2.2.2:1234", tx)
+	for interc.scheduler.queued() != 2 {
+		time.Sleep(time.Millisecond)
+	}
+
+	w := httptest.NewRecorder()
+	if status, err := interc.ServeHTTP(w, interruptRequest("1.1.1.1:1234")); status != http.StatusOK || w.Body.String() != "{}" {
+		t.Fatalf("expected an empty success response, got %d: %v %s", status, err, w.Body.String())
+	}
+	select {
+	case res := <-queued:
+		if res.status != http.StatusBadRequest || res.err != ErrPoWInterrupted {
+			t.Errorf("expected the queued PoW to be interrupted, got %d: %v", res.status, res.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the queued PoW to leave the queue right away")
+	}
+	if queued := interc.scheduler.queued(); queued != 1 {
+		t.Errorf("expected only the other client's job to be queued, got %d", queued)
+	}
+
+	close(gate)
+	if res := <-running; res.status != http.StatusBadRequest || res.err != ErrPoWInterrupted {
+		t.Errorf("expected the running PoW to be interrupted, got %d: %v", res.status, res.err)
+	}
+	if res := <-other; res.status != http.StatusOK {
+		t.Errorf("expected the other client's PoW to be done, got %d: %v", res.status, res.err)
+	}
+	if next.calls != 0 {
+		t.Errorf("expected interruptAttachingToTangle not to be forwarded, got %d calls", next.calls)
+	}
+}
+
+func TestInterruptWithoutPoW(t *testing.T) {
+	interc, next := newTestInterceptor(t, newConfig())
+	w := httptest.NewRecorder()
+	if status, err := interc.ServeHTTP(w, interruptRequest("1.1.1.1:1234")); status != http.StatusOK || w.Body.String() != "{}" {
+		t.Fatalf("expected an empty success response, got %d: %v %s", status, err, w.Body.String())
+	}
+	if next.calls != 0 {
+		t.Errorf("expected interruptAttachingToTangle not to be forwarded, got %d calls", next.calls)
+	}
+}
+
+func TestSchedulerAcquireCanceled(t *testing.T) {
+	s := &powScheduler{}
+	s.acquire(context.Background(), basePoWPriority)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.acquire(ctx, basePoWPriority) }()
+	for s.queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Fatalf("expected the wait to be canceled, got %v", err)
+	}
+	if queued := s.queued(); queued != 0 {
+		t.Errorf("expected the job to leave the queue, got %d queued", queued)
+	}
+	s.release()
+	if active := s.active(); active != 0 {
+		t.Errorf("expected the slot to be free, got %d active", active)
+	}
+}
@@ -0,0 +1,51 @@
+package iota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+	"github.com/pkg/errors"
+)
+
+// callIRI sends the given command to IRI via the next handler and decodes the response into res.
+// The URL and headers are copied from the given client request so the command takes the same route.
+func callIRI(ctx context.Context, next httpserver.Handler, r *http.Request, cmd, res interface{}) error {
+	body, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, r.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	for k, values := range r.Header {
+		req.Header[k] = append([]string(nil), values...)
+	}
+	req.Header.Del("Content-Length")
+	req.ContentLength = int64(len(body))
+
+	buffered := &bufferedResponse{header: http.Header{}}
+	status, err := next.ServeHTTP(buffered, req)
+	if err != nil {
+		return err
+	}
+	if buffered.code == 0 {
+		buffered.code = status
+	}
+	if buffered.code != http.StatusOK {
+		return errors.Errorf("IRI returned status %d", buffered.code)
+	}
+	resBody, err := ioutil.ReadAll(&buffered.body)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resBody, res); err != nil {
+		return errors.Wrap(err, "invalid IRI response")
+	}
+	return nil
+}
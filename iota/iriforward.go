@@ -0,0 +1,76 @@
+package iota
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// the pool defaults of http.DefaultTransport
+const (
+	defaultBackendMaxIdleConns    = 100
+	defaultBackendIdleConnTimeout = 90 * time.Second
+)
+
+// iriForwarder replaces the next handler and sends the requests directly to IRI,
+// presenting the configured client certificate if IRI requires mutual TLS.
+type iriForwarder struct {
+	proxy *httputil.ReverseProxy
+}
+
+// newBackendTransport returns the transport for the requests to IRI with the configured pool limits.
+func newBackendTransport(cfg *Config, tlsConfig *tls.Config) *http.Transport {
+	maxIdle := defaultBackendMaxIdleConns
+	if cfg.BackendMaxIdleConns > 0 {
+		maxIdle = cfg.BackendMaxIdleConns
+	}
+	idleTimeout := defaultBackendIdleConnTimeout
+	if cfg.BackendIdleConnTimeout > 0 {
+		idleTimeout = cfg.BackendIdleConnTimeout
+	}
+	return &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+		MaxIdleConns:    maxIdle,
+		// all connections go to the same host, so the per host limit would otherwise cap the pool at 2
+		MaxIdleConnsPerHost: maxIdle,
+		MaxConnsPerHost:     cfg.BackendMaxConnsPerHost,
+		IdleConnTimeout:     idleTimeout,
+	}
+}
+
+func newIRIForwarder(upstream string, transport *http.Transport, logEntry logEntryFunc) (*iriForwarder, error) {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid IRI upstream")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("IRI upstream must be an http or https URL, got %s", upstream)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	proxy.Transport = transport
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logEntry(levelError, "iri_forward_failed", logFields{}, "unable to forward request to IRI: %v\n", err)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	return &iriForwarder{proxy: proxy}, nil
+}
+
+func (f *iriForwarder) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
+	f.proxy.ServeHTTP(w, r)
+	// the response is already written
+	return 0, nil
+}
+
+// loadClientTLSConfig returns a TLS config presenting the given key pair.
+func loadClientTLSConfig(certFile, keyFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load IRI client certificate")
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}}, nil
+}
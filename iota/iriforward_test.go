@@ -0,0 +1,127 @@
+package iota
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientCert writes a self-signed client certificate and its key to dir.
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string, cert *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "iotacaddy"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert, err = x509.ParseCertificate(der); err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile, cert
+}
+
+func TestIRIClientCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iotamtls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile, keyFile, cert := writeClientCert(t, dir)
+
+	iri := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"appName":"IRI"}`))
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	iri.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	iri.StartTLS()
+	defer iri.Close()
+	serverCAs := x509.NewCertPool()
+	serverCAs.AddCert(iri.Certificate())
+
+	forward := func(tlsConfig *tls.Config) (int, string) {
+		tlsConfig.RootCAs = serverCAs
+		interc, _ := newTestInterceptor(t, newConfig())
+		forwarder, err := newIRIForwarder(iri.URL, newBackendTransport(newConfig(), tlsConfig), interc.logEntry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		interc.Next = forwarder
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"command":"getNodeInfo"}`))
+		if _, err := interc.ServeHTTP(w, r); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, w.Body.String()
+	}
+
+	tlsConfig, err := loadClientTLSConfig(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("unable to load client certificate: %v", err)
+	}
+	if status, body := forward(tlsConfig); status != http.StatusOK || body != `{"appName":"IRI"}` {
+		t.Errorf("expected the request to be forwarded over mutual TLS, got %d: %s", status, body)
+	}
+	if status, _ := forward(&tls.Config{}); status != http.StatusBadGateway {
+		t.Errorf("expected the handshake to fail without a client certificate, got %d", status)
+	}
+
+	if _, err := loadClientTLSConfig(filepath.Join(dir, "missing.pem"), keyFile); err == nil {
+		t.Error("expected an error for a missing certificate file")
+	}
+}
+
+func TestBackendTransport(t *testing.T) {
+	cfg := newConfig()
+	cfg.IRIUpstream = "http://127.0.0.1:14265"
+	interc, _ := newTestInterceptor(t, cfg)
+	if tr := interc.transport; tr.MaxIdleConns != 100 || tr.MaxIdleConnsPerHost != 100 || tr.MaxConnsPerHost != 0 || tr.IdleConnTimeout != 90*time.Second {
+		t.Errorf("expected the defaults of http.DefaultTransport, got %d idle, %d per host, %v timeout", tr.MaxIdleConns, tr.MaxConnsPerHost, tr.IdleConnTimeout)
+	}
+
+	cfg.BackendMaxIdleConns = 20
+	cfg.BackendMaxConnsPerHost = 10
+	cfg.BackendIdleConnTimeout = 30 * time.Second
+	interc, _ = newTestInterceptor(t, cfg)
+	tr := interc.transport
+	if tr.MaxIdleConns != 20 || tr.MaxIdleConnsPerHost != 20 || tr.MaxConnsPerHost != 10 || tr.IdleConnTimeout != 30*time.Second {
+		t.Errorf("expected the configured limits, got %d idle, %d per host, %v timeout", tr.MaxIdleConns, tr.MaxConnsPerHost, tr.IdleConnTimeout)
+	}
+	if interc.iri.proxy.Transport != tr {
+		t.Error("expected the forwarder to use the transport")
+	}
+}
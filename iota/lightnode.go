@@ -0,0 +1,13 @@
+package iota
+
+import (
+	"net/http"
+)
+
+// lightNode replaces IRI in light node mode, where the interceptor only does PoW and
+// the clients broadcast the transactions themselves.
+type lightNode struct{}
+
+func (lightNode) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
+	return http.StatusNotImplemented, ErrLightNodeMode
+}
@@ -0,0 +1,29 @@
+package iota
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLightNodeMode(t *testing.T) {
+	cfg := newConfig()
+	cfg.LightNodeMode = true
+	interc, next := newTestInterceptor(t, cfg)
+	interc.setNext(next)
+
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader([]byte(`{"command":"getNodeInfo"}`)))
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), req); status != http.StatusNotImplemented || err != ErrLightNodeMode {
+		t.Errorf("expected getNodeInfo to receive a 501, got %d: %v", status, err)
+	}
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil)); status != http.StatusNotImplemented {
+		t.Errorf("expected GET to receive a 501, got %d: %v", status, err)
+	}
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusOK || err != nil {
+		t.Errorf("expected attachToTangle to be served, got %d: %v", status, err)
+	}
+	if next.calls != 0 {
+		t.Errorf("expected nothing to be forwarded, got %d calls", next.calls)
+	}
+}
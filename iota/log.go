@@ -0,0 +1,127 @@
+package iota
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultLogFile = "iota.log"
+
+// log file values which disable file logging
+const (
+	logFileNone   = "-"
+	logFileStdout = "stdout"
+)
+
+// formats of the entries logged while serving requests
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// levels of log entries
+const (
+	levelInfo  = "info"
+	levelWarn  = "warn"
+	levelError = "error"
+)
+
+// logFields holds the request context of a log entry, empty fields are omitted in JSON.
+type logFields struct {
+	RemoteAddr    string  `json:"remote_addr,omitempty"`
+	BundleHash    string  `json:"bundle_hash,omitempty"`
+	TxCount       int     `json:"tx_count,omitempty"`
+	PoWMs         int64   `json:"pow_ms,omitempty"`
+	IsValueBundle bool    `json:"is_value_bundle,omitempty"`
+	InputMi       float64 `json:"input_mi,omitempty"`
+	// receives a copy of the entry once the bundle type is known, nil if none is configured
+	bundleLog *log.Logger
+}
+
+// logEntryFunc is the signature of Interceptor.logEntry, for components logging through it.
+type logEntryFunc func(level, event string, fields logFields, format string, args ...interface{})
+
+type jsonLogEntry struct {
+	Timestamp string `json:"timestamp"`
+	Level     string `json:"level"`
+	Event     string `json:"event"`
+	Message   string `json:"message"`
+	logFields
+}
+
+// logEntry logs the formatted message as a text line or, in the json log format, as a JSON
+// object holding the timestamp, level, event, message and the given fields.
+func (interc *Interceptor) logEntry(level, event string, fields logFields, format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	if interc.Config.LogFormat == logFormatJSON {
+		entry, err := json.Marshal(&jsonLogEntry{
+			Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+			Level:     level,
+			Event:     event,
+			Message:   strings.TrimSuffix(line, "\n"),
+			logFields: fields,
+		})
+		if err == nil {
+			line = string(entry)
+		}
+	}
+	interc.logger.Print(line)
+	if fields.bundleLog != nil {
+		fields.bundleLog.Print(line)
+	}
+}
+
+// bundleLog returns the logger of value or data bundles, nil if none is configured for the type.
+func (interc *Interceptor) bundleLog(isValueBundle bool) *log.Logger {
+	if isValueBundle {
+		return interc.valueLog
+	}
+	return interc.dataLog
+}
+
+func newLogger(out io.Writer) *log.Logger {
+	return log.New(out, "[iota interceptor] ", log.Ldate|log.Ltime)
+}
+
+// openBundleLog returns a logger writing only to the given file, without the text prefix in
+// the json log format, and a func closing the file.
+func openBundleLog(path string, format string) (*log.Logger, func() error, error) {
+	logfile, err := os.OpenFile(path, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, nil, err
+	}
+	l := newLogger(logfile)
+	if format == logFormatJSON {
+		l.SetPrefix("")
+		l.SetFlags(0)
+	}
+	return l, logfile.Close, nil
+}
+
+// openLog returns a logger writing to stdout and the given file, rotated daily and keeping
+// the archives of the given amount of days, all if 0, or only to stdout if the path disables
+// file logging, and a func closing the file. In the json log format the text prefix is left
+// out so lines are plain JSON objects.
+func openLog(path string, keepDays int, format string) (*log.Logger, func() error, error) {
+	var out io.Writer = os.Stdout
+	closeLog := func() error { return nil }
+	if path != logFileNone && path != logFileStdout {
+		logfile, err := openRollingLogger(path, keepDays)
+		if err != nil {
+			return nil, nil, err
+		}
+		// we don't buffer writes to the log file because the write frequency is very low
+		out, closeLog = io.MultiWriter(os.Stdout, logfile), logfile.close
+	}
+	l := newLogger(out)
+	if format == logFormatJSON {
+		l.SetPrefix("")
+		l.SetFlags(0)
+	}
+	return l, closeLog, nil
+}
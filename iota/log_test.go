@@ -0,0 +1,186 @@
+package iota
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOpenLog(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iotalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "iota.log")
+
+	fileLogger, closeLog, err := openLog(path, 0, logFormatText)
+	if err != nil {
+		t.Fatalf("unable to open log: %v", err)
+	}
+	if fileLogger == logger {
+		t.Error("expected a logger of its own instead of the package logger")
+	}
+	fileLogger.Println("written to the file")
+	if err := closeLog(); err != nil {
+		t.Fatal(err)
+	}
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(contents), "[iota interceptor] ") || !strings.Contains(string(contents), "written to the file") {
+		t.Errorf("expected the log line in the file, got %q", contents)
+	}
+
+	if _, _, err := openLog(filepath.Join(dir, "missing", "iota.log"), 0, logFormatText); err == nil {
+		t.Error("expected an error for a log file in a missing directory")
+	}
+
+	for _, disabled := range []string{logFileNone, logFileStdout} {
+		l, _, err := openLog(disabled, 0, logFormatJSON)
+		if err != nil {
+			t.Errorf("%s: expected no error, got %v", disabled, err)
+			continue
+		}
+		if l.Prefix() != "" || l.Flags() != 0 {
+			t.Errorf("%s: expected no text prefix in the json log format", disabled)
+		}
+		if _, err := os.Stat(disabled); !os.IsNotExist(err) {
+			t.Errorf("%s: expected no log file to be created", disabled)
+		}
+	}
+}
+
+func TestLogEntry(t *testing.T) {
+	var buf bytes.Buffer
+	origLogger := logger
+	logger = log.New(&buf, "", 0)
+	defer func() { logger = origLogger }()
+
+	interc, _ := newTestInterceptor(t, newConfig())
+	fields := logFields{RemoteAddr: "1.1.1.1", BundleHash: "BUNDLE", TxCount: 2}
+	interc.logEntry(levelInfo, "pow_done", fields, "took %dms to do PoW\n", 5)
+	if buf.String() != "took 5ms to do PoW\n" {
+		t.Errorf("expected the text line, got %q", buf.String())
+	}
+
+	buf.Reset()
+	interc.Config.LogFormat = logFormatJSON
+	interc.logEntry(levelInfo, "pow_done", fields, "took %dms to do PoW\n", 5)
+	entry := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON object, got %q: %v", buf.String(), err)
+	}
+	expected := map[string]interface{}{
+		"level":       levelInfo,
+		"event":       "pow_done",
+		"message":     "took 5ms to do PoW",
+		"remote_addr": "1.1.1.1",
+		"bundle_hash": "BUNDLE",
+		"tx_count":    float64(2),
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, entry[key])
+		}
+	}
+	if _, has := entry["timestamp"]; !has {
+		t.Error("expected the entry to have a timestamp")
+	}
+	if _, has := entry["pow_ms"]; has {
+		t.Error("expected empty fields to be omitted")
+	}
+}
+
+func TestJSONLogFormat(t *testing.T) {
+	var buf bytes.Buffer
+	origLogger := logger
+	logger = log.New(&buf, "", 0)
+	defer func() { logger = origLogger }()
+
+	cfg := newConfig()
+	cfg.LogFormat = logFormatJSON
+	interc, _ := newTestInterceptor(t, cfg)
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 2000000), testTx("TEST", -2000000))
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", status, err)
+	}
+
+	var done *jsonLogEntry
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		entry := &jsonLogEntry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			t.Fatalf("expected every line to be JSON, got %q: %v", scanner.Text(), err)
+		}
+		if entry.Event == "pow_done" {
+			done = entry
+		}
+	}
+	if done == nil {
+		t.Fatal("expected a pow_done entry")
+	}
+	if done.RemoteAddr != "1.1.1.1" || done.BundleHash == "" || done.TxCount != 2 || !done.IsValueBundle || done.InputMi != 2 {
+		t.Errorf("expected the request context in the entry, got %+v", done)
+	}
+}
+
+func TestBundleLogs(t *testing.T) {
+	var buf bytes.Buffer
+	origLogger := logger
+	logger = log.New(&buf, "", 0)
+	defer func() { logger = origLogger }()
+
+	dir, err := ioutil.TempDir("", "iotabundlelog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	valueLog, closeValueLog, err := openBundleLog(filepath.Join(dir, "value.log"), logFormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataLog, closeDataLog, err := openBundleLog(filepath.Join(dir, "data.log"), logFormatText)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	interc, _ := newTestInterceptor(t, newConfig())
+	interc.valueLog, interc.dataLog = valueLog, dataLog
+	valueBundle := bundleTrytes(t, "kerl", testTx("VALUE", 10), testTx("VALUE", -10))
+	interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, valueBundle...))
+	interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "DATA", 0)))
+	if err := closeValueLog(); err != nil {
+		t.Fatal(err)
+	}
+	if err := closeDataLog(); err != nil {
+		t.Fatal(err)
+	}
+
+	valueContents, err := ioutil.ReadFile(filepath.Join(dir, "value.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataContents, err := ioutil.ReadFile(filepath.Join(dir, "data.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(valueContents), "bundle is using") || strings.Contains(string(valueContents), "1 txs") {
+		t.Errorf("expected only the value bundle in the value log, got:\n%s", valueContents)
+	}
+	if !strings.Contains(string(dataContents), "bundle with 1 txs") || strings.Contains(string(dataContents), "2 txs") {
+		t.Errorf("expected only the data bundle in the data log, got:\n%s", dataContents)
+	}
+	if !strings.Contains(buf.String(), "2 txs") || !strings.Contains(buf.String(), "1 txs") {
+		t.Errorf("expected the combined log to receive both bundles, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,84 @@
+package iota
+
+import (
+	"bytes"
+	"os"
+	"text/template"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/converter"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+// transaction field the metadata can be embedded into, the obsolete tag isn't supported
+// as it's part of the bundle essence and changing it would invalidate the bundle hash
+const metadataFieldTag = "tag"
+
+// metadataVars are the variables available in the metadata template.
+type metadataVars struct {
+	// the hostname of the server
+	ServerID string
+	// unix time of the PoW in seconds
+	Timestamp int64
+	Version   string
+}
+
+// metadataEmbedder writes the expanded metadata template into the tag of the transactions
+// before the PoW is done for them.
+type metadataEmbedder struct {
+	tmpl     *template.Template
+	serverID string
+	offset   int
+}
+
+func newMetadataEmbedder(field string, tmpl string) (*metadataEmbedder, error) {
+	t, err := template.New("metadata").Parse(tmpl)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid metadata template")
+	}
+	serverID, err := os.Hostname()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to determine the server ID")
+	}
+	m := &metadataEmbedder{tmpl: t, serverID: serverID}
+	switch field {
+	case metadataFieldTag:
+		m.offset = consts.TagTrinaryOffset / 3
+	default:
+		return nil, errors.Errorf("unknown metadata field '%s'", field)
+	}
+	return m, nil
+}
+
+// encode expands the template and encodes the result as ASCII trytes, truncated or padded
+// with 9s to the 27 trytes of the tag fields.
+func (m *metadataEmbedder) encode(now time.Time) (trinary.Trytes, error) {
+	var buf bytes.Buffer
+	if err := m.tmpl.Execute(&buf, &metadataVars{ServerID: m.serverID, Timestamp: now.Unix(), Version: Version}); err != nil {
+		return "", errors.Wrap(err, "unable to expand the metadata template")
+	}
+	trytes, err := converter.ASCIIToTrytes(buf.String())
+	if err != nil {
+		return "", errors.Wrap(err, "metadata isn't ASCII")
+	}
+	if len(trytes) > consts.TagTrinarySize/3 {
+		return trytes[:consts.TagTrinarySize/3], nil
+	}
+	return trinary.Pad(trytes, consts.TagTrinarySize/3), nil
+}
+
+// embed returns the given transaction trytes with the metadata written into the configured field.
+// Trytes of the wrong length have been rejected by the parser before.
+func (m *metadataEmbedder) embed(txTrytes []trinary.Trytes, now time.Time) ([]trinary.Trytes, error) {
+	metadata, err := m.encode(now)
+	if err != nil {
+		return nil, err
+	}
+	embedded := make([]trinary.Trytes, len(txTrytes))
+	for i, trytes := range txTrytes {
+		embedded[i] = trytes[:m.offset] + metadata + trytes[m.offset+len(metadata):]
+	}
+	return embedded, nil
+}
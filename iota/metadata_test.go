@@ -0,0 +1,78 @@
+package iota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/converter"
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+func TestEmbedMetadata(t *testing.T) {
+	cfg := newConfig()
+	cfg.EmbedMetadataField = metadataFieldTag
+	cfg.MetadataTemplate = "T{{.Timestamp}}"
+	interc, _ := newTestInterceptor(t, cfg)
+
+	before := time.Now().Unix()
+	w := httptest.NewRecorder()
+	// a signed value bundle, so the output shows whether the bundle is still valid
+	bundle := bundleTrytes(t, defaultBundleHashAlgorithm, testTx("ORIGINAL", 100), testTx("ORIGINAL", -100))
+	if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	res := &AttachToTangleRes{}
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	powed, err := transaction.AsTransactionObjects(res.Trytes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tx := range powed {
+		// the 11 characters of T<unix seconds> take 22 of the 27 trytes
+		metadata, err := converter.TrytesToASCII(tx.Tag[:22])
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts, err := strconv.ParseInt(strings.TrimPrefix(metadata, "T"), 10, 64)
+		if err != nil || !strings.HasPrefix(metadata, "T") || ts < before || ts > time.Now().Unix() {
+			t.Errorf("expected the current timestamp in the tag, got %s (%s)", metadata, tx.Tag)
+		}
+		if tx.Tag[22:] != "99999" {
+			t.Errorf("expected the tag to be padded with 9s, got %s", tx.Tag)
+		}
+		if !strings.HasPrefix(tx.ObsoleteTag, "ORIGINAL") {
+			t.Errorf("expected the obsolete tag to be untouched, got %s", tx.ObsoleteTag)
+		}
+	}
+	if err := validateBundleHash(powed, bundleHashAlgorithms[defaultBundleHashAlgorithm]); err != nil {
+		t.Errorf("expected the attached bundle hash to stay valid, got %v", err)
+	}
+	if err := validateBundleSignatures(powed); err != nil {
+		t.Errorf("expected the attached signatures to stay valid, got %v", err)
+	}
+}
+
+func TestMetadataTruncation(t *testing.T) {
+	m, err := newMetadataEmbedder(metadataFieldTag, "a very long metadata template {{.Version}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := m.encode(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata) != 27 {
+		t.Errorf("expected the metadata to be truncated to 27 trytes, got %d", len(metadata))
+	}
+
+	if _, err := newMetadataEmbedder(metadataFieldTag, "{{.Unclosed"); err == nil {
+		t.Error("expected an invalid template to be rejected")
+	}
+}
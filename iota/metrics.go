@@ -0,0 +1,56 @@
+package iota
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const metricsNamespace = "iotacaddy"
+
+// PoW metrics, shared by all sites like the PoW scheduler
+var (
+	powRequestsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "pow_requests_total",
+		Help:      "Amount of attachToTangle requests the PoW was done for.",
+	})
+	powFailuresTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "pow_failures_total",
+		Help:      "Amount of failed PoWs.",
+	})
+	powDurationMs = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "pow_duration_ms",
+		Help:      "Duration of successful attachToTangle requests in milliseconds.",
+		Buckets:   prometheus.ExponentialBuckets(10, 2, 12),
+	})
+	activePoWWorkers = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "active_pow_workers",
+		Help:      "Amount of PoWs currently running.",
+	})
+	powDegradedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "pow_degraded_total",
+		Help:      "Amount of PoWs doing less than the configured minimum hashes per second.",
+	})
+	workerPanicsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "worker_panics_total",
+		Help:      "Amount of recovered PoW panics.",
+	})
+)
+
+// the plugin's own registry so reloads and other plugins don't clash with the default one
+var metricsRegistry = prometheus.NewRegistry()
+
+func init() {
+	metricsRegistry.MustRegister(powRequestsTotal, powFailuresTotal, powDurationMs, activePoWWorkers, powDegradedTotal, workerPanicsTotal)
+}
+
+func newMetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
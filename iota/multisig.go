@@ -0,0 +1,46 @@
+package iota
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// multiSigMessage returns the message the authorizations of the request sign, the JSON encoding
+// of its command, trunkTransaction, branchTransaction, minWeightMagnitude and trytes fields
+// in this order without whitespace.
+func multiSigMessage(req *AttachToTangleReq) []byte {
+	unsigned := *req
+	unsigned.Authorizations = nil
+	// can't fail for a struct of strings and ints
+	msg, _ := json.Marshal(&unsigned)
+	return msg
+}
+
+// checkAuthorizations returns ErrInsufficientAuthorizations unless the request holds hex encoded
+// HMAC-SHA256 signatures of at least the required amount of different configured keys.
+func (interc *Interceptor) checkAuthorizations(req *AttachToTangleReq) error {
+	msg := multiSigMessage(req)
+	signed := make(map[int]bool, len(interc.Config.MultiSigKeys))
+	for _, auth := range req.Authorizations {
+		sig, err := hex.DecodeString(auth)
+		if err != nil {
+			continue
+		}
+		for i, key := range interc.Config.MultiSigKeys {
+			if signed[i] {
+				continue
+			}
+			if hmac.Equal(sig, hmacSHA256([]byte(key), msg)) {
+				signed[i] = true
+				break
+			}
+		}
+	}
+	if len(signed) < interc.Config.MultiSigRequired {
+		return errors.Wrapf(ErrInsufficientAuthorizations, "%d of %d required are valid", len(signed), interc.Config.MultiSigRequired)
+	}
+	return nil
+}
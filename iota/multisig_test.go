@@ -0,0 +1,77 @@
+package iota
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/pkg/errors"
+)
+
+func TestMultiSig(t *testing.T) {
+	cfg := newConfig()
+	cfg.MultiSigRequired = 2
+	cfg.MultiSigKeys = []string{"alice", "bob", "carol"}
+	interc, _ := newTestInterceptor(t, cfg)
+
+	req := &AttachToTangleReq{
+		Command:      attachToTangleCommand,
+		TrunkTxHash:  consts.NullHashTrytes,
+		BranchTxHash: consts.NullHashTrytes,
+		MWM:          1,
+		Trytes:       bundleTrytes(t, "kerl", testTx("TEST", 0)),
+	}
+	sign := func(key string) string {
+		mac := hmac.New(sha256.New, []byte(key))
+		mac.Write(multiSigMessage(req))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	for _, test := range []struct {
+		name           string
+		authorizations []string
+		expected       int
+	}{
+		{"no authorizations", nil, http.StatusUnauthorized},
+		{"one authorization", []string{sign("alice")}, http.StatusUnauthorized},
+		{"the same key twice", []string{sign("alice"), sign("alice")}, http.StatusUnauthorized},
+		{"an unknown key", []string{sign("alice"), sign("mallory")}, http.StatusUnauthorized},
+		{"an invalid signature", []string{sign("alice"), "not hex"}, http.StatusUnauthorized},
+		{"two keys", []string{sign("carol"), sign("alice")}, http.StatusOK},
+		{"all keys", []string{sign("alice"), sign("bob"), sign("carol")}, http.StatusOK},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			signed := *req
+			signed.Authorizations = test.authorizations
+			body, err := json.Marshal(&signed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+			r.RemoteAddr = "1.1.1.1:1234"
+			status, err := interc.ServeHTTP(httptest.NewRecorder(), r)
+			if status != test.expected {
+				t.Fatalf("expected %d, got %d: %v", test.expected, status, err)
+			}
+			if test.expected == http.StatusUnauthorized && errors.Cause(err) != ErrInsufficientAuthorizations {
+				t.Errorf("expected insufficient authorizations, got %v", err)
+			}
+		})
+	}
+
+	// the signatures cover the request
+	tampered := *req
+	tampered.Authorizations = []string{sign("alice"), sign("bob")}
+	tampered.MWM = 2
+	body, _ := json.Marshal(&tampered)
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), r); status != http.StatusUnauthorized {
+		t.Errorf("expected a changed request to be rejected, got %d: %v", status, err)
+	}
+}
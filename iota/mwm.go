@@ -0,0 +1,43 @@
+package iota
+
+import (
+	"github.com/pkg/errors"
+)
+
+const (
+	mwmValidationExact = "exact"
+	mwmValidationMin   = "min"
+	mwmValidationMax   = "max"
+)
+
+// mwmThreshold returns the MWM the validation mode compares against, the max MWM if none is set.
+func (cfg *Config) mwmThreshold() int {
+	if cfg.MWMThreshold > 0 {
+		return cfg.MWMThreshold
+	}
+	return cfg.MaxMWM
+}
+
+// validateMWM returns ErrInvalidMWM if the given MWM doesn't satisfy the configured validation mode.
+// Regardless of the mode, the MWM may never exceed the max MWM nor fall below the min MWM if set.
+func validateMWM(mwm int, cfg *Config) error {
+	if cfg.MinMWM > 0 && (mwm < cfg.MinMWM || mwm > cfg.MaxMWM) {
+		return errors.Wrapf(ErrInvalidMWM, "use mwm between %d-%d", cfg.MinMWM, cfg.MaxMWM)
+	}
+	threshold := cfg.mwmThreshold()
+	switch cfg.MWMValidationMode {
+	case mwmValidationExact:
+		if mwm != threshold {
+			return errors.Wrapf(ErrInvalidMWM, "use mwm %d", threshold)
+		}
+	case mwmValidationMin:
+		if mwm < threshold || mwm > cfg.MaxMWM {
+			return errors.Wrapf(ErrInvalidMWM, "use mwm between %d-%d", threshold, cfg.MaxMWM)
+		}
+	default:
+		if mwm < 0 || mwm > threshold {
+			return errors.Wrapf(ErrInvalidMWM, "use mwm between 1-%d", threshold)
+		}
+	}
+	return nil
+}
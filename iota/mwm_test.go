@@ -0,0 +1,88 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestMWMValidationMode(t *testing.T) {
+	const threshold = 5
+	tests := []struct {
+		mode             string
+		below, at, above bool
+		description      string
+	}{
+		{mwmValidationExact, false, true, false, "only the threshold"},
+		{mwmValidationMin, false, true, true, "the threshold up to the max MWM"},
+		{mwmValidationMax, true, true, false, "up to the threshold"},
+	}
+	for _, test := range tests {
+		cfg := newConfig()
+		cfg.MaxMWM = 9
+		cfg.MWMValidationMode = test.mode
+		cfg.MWMThreshold = threshold
+		interc, _ := newTestInterceptor(t, cfg)
+
+		for mwm, accepted := range map[int]bool{threshold - 1: test.below, threshold: test.at, threshold + 1: test.above} {
+			status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", mwm, txTrytes(t, "TEST", 0)))
+			if accepted && status != http.StatusOK {
+				t.Errorf("%s: expected %s to be accepted, got %d for MWM %d: %v", test.mode, test.description, status, mwm, err)
+			}
+			if !accepted && (status != http.StatusBadRequest || errors.Cause(err) != ErrInvalidMWM) {
+				t.Errorf("%s: expected MWM %d to be rejected, got %d: %v", test.mode, mwm, status, err)
+			}
+		}
+
+		// the max MWM is never exceeded
+		if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", cfg.MaxMWM+1, txTrytes(t, "TEST", 0))); status != http.StatusBadRequest {
+			t.Errorf("%s: expected MWM above the max to be rejected, got %d", test.mode, status)
+		}
+	}
+}
+
+func TestMinMWM(t *testing.T) {
+	cfg := newConfig()
+	cfg.MinMWM = 3
+	cfg.MaxMWM = 9
+	interc, _ := newTestInterceptor(t, cfg)
+	for mwm, accepted := range map[int]bool{1: false, 2: false, 3: true, 9: true, 10: false} {
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", mwm, txTrytes(t, "TEST", 0)))
+		if accepted && status != http.StatusOK {
+			t.Errorf("expected MWM %d to be accepted, got %d: %v", mwm, status, err)
+		}
+		if !accepted {
+			if status != http.StatusBadRequest || errors.Cause(err) != ErrInvalidMWM {
+				t.Errorf("expected MWM %d to be rejected, got %d: %v", mwm, status, err)
+			} else if !strings.Contains(err.Error(), "3-9") {
+				t.Errorf("expected the error to quote the range, got %v", err)
+			}
+		}
+	}
+}
+
+func TestMinMWMValueBundle(t *testing.T) {
+	cfg := newConfig()
+	cfg.MaxMWM = 9
+	cfg.MinMWMValueBundle = 5
+	interc, _ := newTestInterceptor(t, cfg)
+
+	value := bundleTrytes(t, defaultBundleHashAlgorithm, testTx("TEST", 100), testTx("TEST", -100))
+	for mwm, accepted := range map[int]bool{4: false, 5: true, 9: true} {
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", mwm, value...))
+		if accepted && status != http.StatusOK {
+			t.Errorf("expected MWM %d to be accepted for a value bundle, got %d: %v", mwm, status, err)
+		}
+		if !accepted && (status != http.StatusBadRequest || errors.Cause(err) != ErrInvalidMWM) {
+			t.Errorf("expected MWM %d to be rejected for a value bundle, got %d: %v", mwm, status, err)
+		}
+	}
+
+	// data bundles only need the regular MWMs
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 4, txTrytes(t, "TEST", 0))); status != http.StatusOK {
+		t.Errorf("expected MWM 4 to be accepted for a data bundle, got %d: %v", status, err)
+	}
+}
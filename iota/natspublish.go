@@ -0,0 +1,101 @@
+package iota
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const defaultNATSBufferSize = 100
+
+// natsPublisher publishes PoW results to a NATS subject. Messages which can't be published
+// because the connection is down are buffered and flushed once the connection is back.
+type natsPublisher struct {
+	mu       sync.Mutex
+	url      string
+	subject  string
+	conn     *nats.Conn
+	bufSize  int
+	buffered [][]byte
+	logger   *log.Logger
+}
+
+func newNATSPublisher(url string, subject string, bufSize int, logger *log.Logger) *natsPublisher {
+	p := &natsPublisher{url: url, subject: subject, bufSize: bufSize, logger: logger}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.connect(); err != nil {
+		p.logger.Printf("unable to connect to NATS server %s, will retry on next publish: %v\n", url, err)
+	}
+	return p
+}
+
+// connect must be called with the lock held.
+func (p *natsPublisher) connect() error {
+	conn, err := nats.Connect(p.url,
+		nats.Timeout(2*time.Second),
+		nats.MaxReconnects(-1),
+		// disable the client's own reconnect buffer so failed publishes end up in ours
+		nats.ReconnectBufSize(-1),
+		nats.ReconnectHandler(func(*nats.Conn) {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			p.flush()
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	p.conn = conn
+	return nil
+}
+
+// flush publishes the buffered messages in order and must be called with the lock held.
+func (p *natsPublisher) flush() error {
+	for len(p.buffered) > 0 {
+		if err := p.conn.Publish(p.subject, p.buffered[0]); err != nil {
+			return err
+		}
+		p.buffered = p.buffered[1:]
+	}
+	return nil
+}
+
+func (p *natsPublisher) buffer(msg []byte) {
+	if len(p.buffered) == p.bufSize {
+		p.logger.Printf("NATS buffer is full, dropping oldest message\n")
+		p.buffered = p.buffered[1:]
+	}
+	p.buffered = append(p.buffered, msg)
+}
+
+// publish sends the message to the configured subject or buffers it if it can't be delivered.
+func (p *natsPublisher) publish(msg []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn == nil {
+		if err := p.connect(); err != nil {
+			p.buffer(msg)
+			return err
+		}
+	}
+	if err := p.flush(); err != nil {
+		p.buffer(msg)
+		return err
+	}
+	if err := p.conn.Publish(p.subject, msg); err != nil {
+		p.buffer(msg)
+		return err
+	}
+	return nil
+}
+
+func (p *natsPublisher) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		p.conn.Close()
+	}
+}
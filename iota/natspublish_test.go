@@ -0,0 +1,104 @@
+package iota
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	natstest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+)
+
+const testNATSPort = 14222
+
+func runNATSServer() *server.Server {
+	opts := natstest.DefaultTestOptions
+	opts.Port = testNATSPort
+	return natstest.RunServer(&opts)
+}
+
+func TestNATSPublish(t *testing.T) {
+	srv := runNATSServer()
+	defer srv.Shutdown()
+	url := fmt.Sprintf("nats://127.0.0.1:%d", testNATSPort)
+
+	sub, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	msgs := make(chan *nats.Msg, 1)
+	if _, err := sub.ChanSubscribe("iota.pow", msgs); err != nil {
+		t.Fatal(err)
+	}
+	sub.Flush()
+
+	cfg := newConfig()
+	cfg.NATSURL, cfg.NATSSubject, cfg.NATSBufferSize = url, "iota.pow", 10
+	interc, _ := newTestInterceptor(t, cfg)
+	defer interc.natsPub.close()
+	w := httptest.NewRecorder()
+	if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+
+	select {
+	case msg := <-msgs:
+		published := &AttachToTangleRes{}
+		if err := json.Unmarshal(msg.Data, published); err != nil {
+			t.Fatalf("invalid JSON published: %v", err)
+		}
+		if string(msg.Data) != w.Body.String() {
+			t.Errorf("expected published message to equal the response, got %s", msg.Data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+func TestNATSPublishBuffering(t *testing.T) {
+	url := fmt.Sprintf("nats://127.0.0.1:%d", testNATSPort)
+	// no server is running yet
+	p := newNATSPublisher(url, "iota.pow", 2, logger)
+	defer p.close()
+	for i := 1; i <= 3; i++ {
+		if err := p.publish([]byte(fmt.Sprintf(`{"msg":%d}`, i))); err == nil {
+			t.Fatal("expected publish to fail without a server")
+		}
+	}
+	if len(p.buffered) != 2 {
+		t.Fatalf("expected buffer to be capped at 2 messages, got %d", len(p.buffered))
+	}
+
+	srv := runNATSServer()
+	defer srv.Shutdown()
+	sub, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	msgs := make(chan *nats.Msg, 3)
+	if _, err := sub.ChanSubscribe("iota.pow", msgs); err != nil {
+		t.Fatal(err)
+	}
+	sub.Flush()
+
+	if err := p.publish([]byte(`{"msg":4}`)); err != nil {
+		t.Fatalf("expected publish to succeed, got: %v", err)
+	}
+	// the oldest message got dropped, the remaining ones are flushed in order
+	for _, expected := range []string{`{"msg":2}`, `{"msg":3}`, `{"msg":4}`} {
+		select {
+		case msg := <-msgs:
+			if string(msg.Data) != expected {
+				t.Errorf("expected %s, got %s", expected, msg.Data)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("didn't receive %s", expected)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package iota
+
+import (
+	"strings"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+// the network magic byte is encoded in the two trytes at this offset, which is
+// the start of the obsolete tag in the transaction layout
+const networkMagicOffset = 2295
+
+// networkMagic decodes the network magic byte of the given transaction trytes.
+// The byte is encoded like in ASCII to trytes conversions: b%27 followed by b/27,
+// so the decoded value may exceed a byte for transactions of other networks.
+func networkMagic(txTrytes trinary.Trytes) int {
+	low := strings.IndexByte(consts.TryteAlphabet, txTrytes[networkMagicOffset])
+	high := strings.IndexByte(consts.TryteAlphabet, txTrytes[networkMagicOffset+1])
+	return low + high*27
+}
+
+// validateNetworkMagic returns ErrWrongNetwork if one of the transactions doesn't carry the given magic byte.
+func validateNetworkMagic(txTrytes []trinary.Trytes, magic int) error {
+	for _, trytes := range txTrytes {
+		if got := networkMagic(trytes); got != magic {
+			return errors.Wrapf(ErrWrongNetwork, "expected network magic byte 0x%02x, got 0x%02x", magic, got)
+		}
+	}
+	return nil
+}
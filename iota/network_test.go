@@ -0,0 +1,57 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+// networkTx returns a transaction carrying the given network magic byte.
+func networkTx(magic byte) transaction.Transaction {
+	tx := testTx("", 0)
+	tag := string([]byte{consts.TryteAlphabet[magic%27], consts.TryteAlphabet[magic/27]})
+	tx.ObsoleteTag = trinary.Pad(tag, 27)
+	return tx
+}
+
+// networkBundleTrytes builds the trytes of a bundle whose transactions carry the given network magic bytes.
+func networkBundleTrytes(t *testing.T, magics ...byte) []trinary.Trytes {
+	txs := make([]transaction.Transaction, len(magics))
+	for i, magic := range magics {
+		txs[i] = networkTx(magic)
+	}
+	return bundleTrytes(t, defaultBundleHashAlgorithm, txs...)
+}
+
+func TestValidateNetworkMagic(t *testing.T) {
+	cfg := newConfig()
+	cfg.ValidateNetworkMagic = true
+	cfg.NetworkMagicByte = 0x42
+
+	tests := []struct {
+		name   string
+		trytes []trinary.Trytes
+		status int
+	}{
+		{"matching network", networkBundleTrytes(t, 0x42), http.StatusOK},
+		{"wrong network", networkBundleTrytes(t, 0x41), http.StatusBadRequest},
+		{"one wrong transaction", networkBundleTrytes(t, 0x42, 0x00), http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interc, _ := newTestInterceptor(t, cfg)
+			status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, test.trytes...))
+			if status != test.status {
+				t.Fatalf("expected status %d, got %d: %v", test.status, status, err)
+			}
+			if status == http.StatusBadRequest && errors.Cause(err) != ErrWrongNetwork {
+				t.Errorf("expected ErrWrongNetwork, got %v", err)
+			}
+		})
+	}
+}
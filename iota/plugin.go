@@ -2,8 +2,13 @@ package iota
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/converter"
 	"github.com/iotaledger/iota.go/pow"
 	"github.com/iotaledger/iota.go/transaction"
 	"github.com/iotaledger/iota.go/trinary"
@@ -11,14 +16,20 @@ import (
 	"github.com/mholt/caddy"
 	"github.com/mholt/caddy/caddyhttp/httpserver"
 	"github.com/pkg/errors"
-	"io"
+	"github.com/xeipuuv/gojsonschema"
+	"go.opencensus.io/trace"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/sync/singleflight"
+	"hash/crc32"
 	"io/ioutil"
 	"log"
-	"math"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,75 +37,327 @@ var ErrMissingBody = errors.New("missing body")
 var ErrBuildingTx = errors.New("couldn't build transaction from trytes")
 var ErrBuildingRes = errors.New("couldn't build response")
 var ErrTxBundleLimitExceeded = errors.New("the number of transactions in the bundle exceed the attachToTangle limit")
+var ErrBundleTooSmall = errors.New("the bundle has less transactions than required")
 var ErrExecutingProofOfWork = errors.New("failed to do Proof of Work")
-var ErrInvalidMWM = errors.New("MWM is higher than max allowed MWM or less than 0")
+var ErrInvalidMWM = errors.New("MWM is not within the allowed range")
+var ErrRateLimited = errors.New("too many attachToTangle requests")
+var ErrRequestTooSoon = errors.New("attachToTangle requests are sent too rapidly")
+var ErrGlobalRateLimited = errors.New("the overall attachToTangle capacity is exhausted")
+var ErrTooManyConnections = errors.New("too many simultaneous connections from the same IP")
+var ErrWrongNetwork = errors.New("the transaction doesn't belong to the configured network")
+var ErrStrictMode = errors.New("rejected in strict mode")
+var ErrQueueMemoryFull = errors.New("the attachToTangle queue holds too many pending bytes")
+var ErrAddressTypeForbidden = errors.New("the address type is not allowed")
+var ErrAddressLocked = errors.New("an input address is spent by a bundle whose PoW is pending")
+var ErrSchemaValidation = errors.New("the attachToTangle request doesn't match the bundle schema")
+var ErrUnknownAddressScheme = errors.New("the address is neither a Kerl nor an Ed25519 address")
+var ErrBundlePinMismatch = errors.New("the bundle hash doesn't match the one pinned to the output address")
+var ErrUnknownJSONField = errors.New("unknown field in request body")
+var ErrBranchNotConfirmed = errors.New("the branch transaction is not confirmed")
+var ErrAlreadyConfirmed = errors.New("the bundle is already confirmed")
+var ErrInvalidTrytes = errors.New("the trytes contain a character outside the alphabet")
+var ErrPoWQueueFull = errors.New("all PoW workers are busy")
+var ErrInvalidBundle = errors.New("the bundle is invalid")
+var ErrInsufficientBalance = errors.New("an input address holds less than the bundle spends from it")
+var ErrValueLimitExceeded = errors.New("the bundle moves more than the allowed value")
+var ErrTagDenied = errors.New("the bundle's tag isn't allowed")
+var ErrOutputValueTooLarge = errors.New("an output transaction sends more than the allowed value to its address")
+var ErrInternal = errors.New("internal error while handling the request")
+var ErrServerShuttingDown = errors.New("the server is shutting down")
+var ErrInsufficientAuthorizations = errors.New("the request lacks the required authorizations")
+var ErrAccessDenied = errors.New("the client IP isn't allowed to use this node")
+var ErrLightNodeMode = errors.New("only attachToTangle is supported in light node mode")
+var ErrPoWTimeout = errors.New("the proof of work took too long")
+var ErrPoWCanceled = errors.New("the client disconnected during the proof of work")
+var ErrPoWInterrupted = errors.New("the proof of work was interrupted by interruptAttachingToTangle")
+var ErrStaleTip = errors.New("the trunk or branch transaction is too old")
+var ErrResultNotFound = errors.New("no result is cached for the job")
 
-var logger *log.Logger
+// logger logs to stdout until setup opened the log of an iota block, each interceptor
+// then logs through the logger of its block.
+var logger = newLogger(os.Stdout)
 
 func init() {
 	caddy.RegisterPlugin("iota", caddy.Plugin{
 		ServerType: "http",
 		Action:     setup,
 	})
-	logfile, err := os.OpenFile("iota.log", os.O_APPEND|os.O_RDWR|os.O_CREATE, 0666)
-	if err != nil {
-		fmt.Println("unable to open/create iota interceptor log file")
-		panic(err)
-	}
-	// we don't buffer writes to the log file because the write frequency is very log
-	multiWriter := io.MultiWriter(os.Stdout, logfile)
-	logger = log.New(multiWriter, "[iota interceptor] ", log.Ldate|log.Ltime)
 }
 
-const (
-	defaultMaxMWM         = 14
-	defaultMaxTxsInBundle = 20
-)
+// Interceptor executes attachToTangle calls locally instead of delegating them to IRI.
+type Interceptor struct {
+	Next   httpserver.Handler
+	Config *Config
 
-var powFn pow.ProofOfWorkFunc
-var maxTxInBundle = 50
-var maxMWM = 14
-
-func setup(c *caddy.Controller) error {
-	name, powFunc := pow.GetFastestProofOfWorkImpl()
-	powFn = powFunc
-	var err error
-	var i int
-	for c.Next() {
-		for ; c.NextArg(); i++ {
-			switch i {
-			case 0:
-				maxMWM, err = strconv.Atoi(c.Val())
-				if err != nil {
-					maxMWM = defaultMaxMWM
-					logger.Printf("setting max allowed MWM to %d\n", maxMWM)
-					continue
-				}
-			case 1:
-				maxTxInBundle, err = strconv.Atoi(c.Val())
-				if err != nil {
-					maxTxInBundle = defaultMaxTxsInBundle
-					logger.Printf("setting max txs per bundle to %d\n", maxTxInBundle)
-					continue
-				}
+	powImplName string
+	powFn       pow.ProofOfWorkFunc
+	// implementations clients may choose via the X-IOTA-PoW-Preference header
+	powPreferences map[string]PoWImpl
+	ipLimiter      *ipRateLimiter
+	ipInterval     *intervalLimiter
+	ipConns        *connLimiter
+	ipPoW          *ipPoWLimiter
+	alphabet       *trytesAlphabet
+	tagLimiter     *tagRateLimiter
+	// the configured denied and allowed tags, any tag is allowed if there are none
+	deniedTags  map[trinary.Trytes]bool
+	allowedTags map[trinary.Trytes]bool
+	// shared by all clients
+	globalLimiter *tokenBucket
+	tipAge        *tipAgeChecker
+	confirmations *confirmationChecker
+	balances      *balanceChecker
+	// caches getTransactionsToApprove responses if enabled
+	tipCache *tipCache
+	// caches PoW results if enabled
+	powCache *powCache
+	// does the PoW instead of the local implementation if set
+	remotePoW *remotePoW
+	// delivers the results of the configured tag prefixes elsewhere than the response if set
+	resultRouter *tagResultRouter
+	// receives the PoW audit events if set
+	auditor *cloudWatchAuditor
+	// hands the pow_concurrency slots to the queued PoW jobs, closed on shutdown
+	scheduler *powScheduler
+	// the queued and running PoW jobs interruptAttachingToTangle calls interrupt
+	interrupts *powInterrupts
+	// served on statsPath if enabled
+	tagStats *tagStats
+	// the last PoW durations and how many were recorded if stats are enabled
+	powStats     *durationRing
+	powsRecorded uint64
+	// writes the metadata into the transactions if enabled
+	metadata *metadataEmbedder
+	// serves the Prometheus metrics if enabled
+	metrics http.Handler
+	// forwards to IRI instead of the next handler if set
+	iri           *iriForwarder
+	transport     *http.Transport
+	bodyCache     *bodyCache
+	resultBackup  *resultBackup
+	natsPub       *natsPublisher
+	static        *staticFiles
+	bundlePins    *bundlePins
+	rebroadcaster *rebroadcaster
+	autoBroadcast *autoBroadcaster
+	bundleSchema  *gojsonschema.Schema
+	reservations  *reservationMap
+	prefetch      *prefetcher
+	// signs intercepted responses if set
+	signingKey ed25519.PrivateKey
+	// collapses concurrent identical read-only requests
+	dedupGroup    singleflight.Group
+	dedupCommands map[string]bool
+	// amount of attachToTangle requests waiting for or doing PoW
+	queueDepth int32
+	// summed body size of the requests waiting for or doing PoW
+	pendingBytes int64
+	// average PoW duration in milliseconds
+	powDuration ewma
+	// amount of PoWs slower than the configured minimum hashes per second
+	powDegraded uint64
+	// unix nano time of the announced shutdown, 0 if none is announced
+	shutdownAt int64
+	// receives all entries, opened per iota block by setup
+	logger *log.Logger
+	// receive the entries of value respectively data bundles if set
+	valueLog *log.Logger
+	dataLog  *log.Logger
+	// guards powStopped so no PoW starts after stopPoW began waiting for powJobs
+	powMu      sync.Mutex
+	powStopped bool
+	powJobs    sync.WaitGroup
+	// served requests and those failing with an error status since started
+	totalRequests uint64
+	totalErrors   uint64
+	started       time.Time
+}
+
+func newInterceptor(cfg *Config, powImplName string, powFn pow.ProofOfWorkFunc, logger *log.Logger) (*Interceptor, error) {
+	interc := &Interceptor{
+		Config:      cfg,
+		logger:      logger,
+		powImplName: powImplName,
+		powFn:       powFn,
+		tagLimiter:  newTagRateLimiter(cfg.TagRateLimits),
+		scheduler:   newPoWScheduler(cfg.PoWConcurrency),
+		powDuration: ewma{alpha: powDurationAlpha},
+		interrupts:  newPoWInterrupts(),
+		started:     time.Now(),
+	}
+	if len(cfg.PoWFallbackChain) > 0 {
+		impls := make([]PoWImpl, len(cfg.PoWFallbackChain))
+		for i, implName := range cfg.PoWFallbackChain {
+			var err error
+			if impls[i], err = lookupPoWImpl(implName); err != nil {
+				return nil, err
 			}
 		}
-		if i != 2 {
-			return c.ArgErr()
+		powFn = interc.fallbackPoWFunc(impls...)
+		interc.powFn = powFn
+	}
+	if len(cfg.DedupCommands) > 0 {
+		interc.dedupCommands = make(map[string]bool, len(cfg.DedupCommands))
+		for _, cmd := range cfg.DedupCommands {
+			interc.dedupCommands[cmd] = true
+		}
+	}
+	if len(cfg.DeniedTags) > 0 {
+		interc.deniedTags = make(map[trinary.Trytes]bool, len(cfg.DeniedTags))
+		for _, tag := range cfg.DeniedTags {
+			interc.deniedTags[tag] = true
 		}
 	}
-	logger.Printf("iota API call interception configured with max bundle txs limit of %d and max MWM of %d\n", maxTxInBundle, maxMWM)
-	logger.Printf("using PoW implementation: %s\n", name)
-	cfg := httpserver.GetConfig(c)
-	mid := func(next httpserver.Handler) httpserver.Handler {
-		return Interceptor{Next: next}
+	if len(cfg.AllowedTags) > 0 {
+		interc.allowedTags = make(map[trinary.Trytes]bool, len(cfg.AllowedTags))
+		for _, tag := range cfg.AllowedTags {
+			interc.allowedTags[tag] = true
+		}
 	}
-	cfg.AddMiddleware(mid)
-	return nil
-}
-
-type Interceptor struct {
-	Next httpserver.Handler
+	if cfg.HonorPoWPreference {
+		interc.powPreferences = newPoWPreferences()
+	}
+	if cfg.RateLimit > 0 {
+		interc.ipLimiter = newIPRateLimiter(cfg.RateLimit, cfg.RateLimiterMaxIPs)
+	}
+	if cfg.MinRequestInterval > 0 {
+		interc.ipInterval = newIntervalLimiter(cfg.MinRequestInterval)
+	}
+	if cfg.MaxConnectionsPerIP > 0 {
+		interc.ipConns = newConnLimiter(cfg.MaxConnectionsPerIP)
+	}
+	if cfg.StrictTrytesValidation {
+		var err error
+		if interc.alphabet, err = newTrytesAlphabet(cfg.TrytesAlphabet); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.MaxConcurrentPoWPerIP > 0 {
+		interc.ipPoW = newIPPoWLimiter(cfg.MaxConcurrentPoWPerIP)
+	}
+	if cfg.GlobalRateLimit > 0 {
+		interc.globalLimiter = newTokenBucket(cfg.GlobalRateLimit)
+	}
+	if cfg.MaxTipAge > 0 {
+		interc.tipAge = newTipAgeChecker(cfg.MaxTipAge, cfg.TipAgeCacheTTL)
+	}
+	if cfg.RequireConfirmedBranch {
+		interc.confirmations = newConfirmationChecker(cfg.ConfirmationCacheTTL)
+	}
+	if cfg.TipCacheTTL > 0 {
+		interc.tipCache = newTipCache(cfg.TipCacheTTL)
+	}
+	if cfg.MaxTagStatsEntries > 0 {
+		interc.tagStats = newTagStats(cfg.MaxTagStatsEntries)
+	}
+	if cfg.StatsEvery > 0 {
+		interc.powStats = newDurationRing(cfg.StatsEvery)
+	}
+	if cfg.PoWCacheSize > 0 {
+		interc.powCache = newPoWCache(cfg.PoWCacheSize, cfg.PoWCacheTTL)
+	}
+	if cfg.RemotePoWURL != "" {
+		var err error
+		if interc.remotePoW, err = newRemotePoW(cfg.RemotePoWURL, cfg.RemotePoWTimeout); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ReserveInputAddresses {
+		interc.reservations = newReservationMap()
+	}
+	if cfg.BundleSchemaFile != "" {
+		var err error
+		if interc.bundleSchema, err = loadBundleSchema(cfg.BundleSchemaFile); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.AutoBroadcastURL != "" {
+		var err error
+		if interc.autoBroadcast, err = newAutoBroadcaster(cfg.AutoBroadcastURL); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.EmbedMetadataField != "" {
+		var err error
+		if interc.metadata, err = newMetadataEmbedder(cfg.EmbedMetadataField, cfg.MetadataTemplate); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.MetricsPath != "" {
+		interc.metrics = newMetricsHandler()
+	}
+	if cfg.IRIUpstream != "" {
+		var tlsConfig *tls.Config
+		var err error
+		if cfg.IRIClientCertFile != "" {
+			if tlsConfig, err = loadClientTLSConfig(cfg.IRIClientCertFile, cfg.IRIClientKeyFile); err != nil {
+				return nil, err
+			}
+		}
+		interc.transport = newBackendTransport(cfg, tlsConfig)
+		if interc.iri, err = newIRIForwarder(cfg.IRIUpstream, interc.transport, interc.logEntry); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.CheckBalances {
+		interc.balances = newBalanceChecker(cfg.BalanceCheckTimeout, cfg.BalanceCacheTTL)
+	}
+	if cfg.BodyCachePath != "" {
+		var err error
+		if interc.bodyCache, err = newBodyCache(cfg.BodyCachePath, cfg.BodyCacheKeep); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.ResultBackupDir != "" {
+		var err error
+		if interc.resultBackup, err = newResultBackup(cfg.ResultBackupDir, cfg.ResultBackupTTL, cfg.ResultBackupCompress); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.SignResponses {
+		var err error
+		if interc.signingKey, err = loadSigningKey(cfg.SigningKeyFile); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.RebroadcastInterval > 0 {
+		interc.rebroadcaster = newRebroadcaster(cfg.RebroadcastInterval, cfg.RebroadcastMaxAttempts, interc.logHash, logger)
+	}
+	if cfg.PrefetchSchedule != "" {
+		schedule, err := parseCronSchedule(cfg.PrefetchSchedule)
+		if err != nil {
+			return nil, err
+		}
+		if interc.prefetch, err = newPrefetcher(schedule, cfg.PrefetchTemplate, cfg.PrefetchTTL, powFn, interc.safeDoPoW, interc.scheduler, logger); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.StaticDir != "" {
+		var err error
+		if interc.static, err = newStaticFiles(cfg.StaticDir); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.TOFUPinMode {
+		var err error
+		if interc.bundlePins, err = newBundlePins(cfg.TOFUPinDB); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.NATSURL != "" {
+		interc.natsPub = newNATSPublisher(cfg.NATSURL, cfg.NATSSubject, cfg.NATSBufferSize, logger)
+	}
+	if len(cfg.TagResultHandlers) > 0 {
+		interc.resultRouter = newTagResultRouter(cfg.TagResultHandlers)
+	}
+	if cfg.CloudWatchLogGroup != "" {
+		client, err := newSDKCloudWatchClient(cfg.AWSRegion)
+		if err != nil {
+			return nil, err
+		}
+		interc.auditor = newCloudWatchAuditor(client, cfg.CloudWatchLogGroup, cfg.CloudWatchLogStream, cfg.CloudWatchFlushInterval, logger)
+	}
+	return interc, nil
 }
 
 type AttachToTangleReq struct {
@@ -103,27 +366,119 @@ type AttachToTangleReq struct {
 	BranchTxHash trinary.Trytes   `json:"branchTransaction"`
 	MWM          int              `json:"minWeightMagnitude"`
 	Trytes       []trinary.Trytes `json:"trytes"`
+	// hex encoded HMAC-SHA256 signatures of the request if multi_sig_required is set
+	Authorizations []string `json:"authorizations,omitempty"`
 }
 
 type AttachToTangleRes struct {
+	// set to attachToTangle if include_command_in_response is enabled
+	Command  string           `json:"command,omitempty"`
 	Trytes   []trinary.Trytes `json:"trytes"`
 	Duration int64            `json:"duration"`
+	// CRC32 (IEEE) of the concatenated trytes for clients to detect corrupted responses
+	Checksum uint32 `json:"checksum"`
+	// indices of the request's trytes which were skipped by the partial bundle recovery
+	SkippedIndices []int `json:"skippedIndices,omitempty"`
+}
+
+// trytesChecksum returns the CRC32 (IEEE) of the concatenated trytes.
+func trytesChecksum(trytes []trinary.Trytes) uint32 {
+	crc := crc32.NewIEEE()
+	for _, t := range trytes {
+		crc.Write([]byte(t))
+	}
+	return crc.Sum32()
 }
 
 const (
 	contentType     = "Content-Type"
 	contentTypeJSON = "application/json"
+
+	headerPoWImpl       = "X-IOTA-PoW-Impl"
+	headerMaxMWM        = "X-IOTA-Max-MWM"
+	headerMaxBundleSize = "X-IOTA-Max-Bundle-Size"
+	headerQueueDepth    = "X-IOTA-Queue-Depth"
+	headerAllowOrigin   = "Access-Control-Allow-Origin"
+	headerAllowMethods  = "Access-Control-Allow-Methods"
+	headerAllowHeaders  = "Access-Control-Allow-Headers"
+	// unix time at which a rate limited client may retry
+	headerRateLimitReset = "X-RateLimit-Reset"
 )
 
 const attachToTangleCommand = "attachToTangle"
 
-var mu = sync.Mutex{}
+// methods and request headers browser clients may use for IRI API calls
+const (
+	corsAllowedMethods = "GET, HEAD, POST, OPTIONS"
+	corsAllowedHeaders = "Content-Type, X-IOTA-API-Version, " + headerPoWPreference + ", " + headerPoWTimeout
+)
+
+// weight of the latest PoW duration in the moving average
+const powDurationAlpha = 0.2
+
+// how far a transaction's timestamp may be ahead of the local clock without a warning
+const maxTimestampSkew = 10 * time.Minute
+
+func (interc *Interceptor) ServeHTTP(w http.ResponseWriter, r *http.Request) (status int, err error) {
+	atomic.AddUint64(&interc.totalRequests, 1)
+	defer func() {
+		if status >= 400 {
+			atomic.AddUint64(&interc.totalErrors, 1)
+		}
+	}()
+	if interc.Config.RecoverPanics {
+		defer interc.recoverPanic(r, &status, &err)
+	}
+	if len(interc.Config.AccessRules) > 0 {
+		if ip := interc.clientIP(r); !accessAllowed(interc.Config.AccessRules, ip) {
+			interc.logEntry(levelWarn, "access_denied", logFields{RemoteAddr: ip}, "denying %s request to %s from %s\n", r.Method, r.URL.Path, ip)
+			return http.StatusForbidden, ErrAccessDenied
+		}
+	}
+	interc.setShutdownHeader(w)
+
+	// answer CORS preflights of browser clients, IRI doesn't know the allowed origin
+	if r.Method == http.MethodOptions {
+		interc.setCORSHeaders(w)
+		w.Header().Set(headerAllowMethods, corsAllowedMethods)
+		w.Header().Set(headerAllowHeaders, corsAllowedHeaders)
+		w.WriteHeader(http.StatusNoContent)
+		return http.StatusNoContent, nil
+	}
+
+	if handled, status, err := interc.serveEndpoint(w, r); handled {
+		return status, err
+	}
+
+	if interc.static != nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) && interc.static.serve(w, r) {
+		return 0, nil
+	}
+
+	// after the static files so HEAD requests for them aren't answered with the capabilities
+	if r.Method == http.MethodHead && interc.Config.HeadCapabilities {
+		interc.setResponseHeaders(w)
+		w.Header().Set(headerMaxMWM, strconv.Itoa(interc.Config.MaxMWM))
+		w.Header().Set(headerMaxBundleSize, strconv.Itoa(interc.Config.MaxTxInBundle))
+		w.Header().Set(headerQueueDepth, strconv.Itoa(int(atomic.LoadInt32(&interc.queueDepth))))
+		w.WriteHeader(http.StatusOK)
+		return http.StatusOK, nil
+	}
 
-func (interc Interceptor) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
 	if r.Method != http.MethodPost {
 		return interc.Next.ServeHTTP(w, r)
 	}
 
+	ip := interc.clientIP(r)
+	// the request context of log entries, filled in as the request is processed
+	fields := logFields{RemoteAddr: ip}
+	if interc.ipConns != nil {
+		if !interc.ipConns.acquire(ip) {
+			interc.logEntry(levelWarn, "too_many_connections", fields, "rejecting request from %s exceeding the simultaneous connections per IP\n", ip)
+			return interc.rateLimited(w, interc.queueDrainTime(), ErrTooManyConnections)
+		}
+		defer interc.ipConns.release(ip)
+	}
+
 	if r.Body == nil {
 		return http.StatusBadRequest, ErrMissingBody
 	}
@@ -142,82 +497,828 @@ func (interc Interceptor) ServeHTTP(w http.ResponseWriter, r *http.Request) (int
 	// re add body
 	r.Body = ioutil.NopCloser(bytes.NewReader(contents))
 
-	// only intercept attachToTangle command
+	// only intercept attachToTangle, interruptAttachingToTangle and, if enabled, storeTransactions and getTransactionsToApprove commands
 	if command.Command != attachToTangleCommand {
+		if command.Command == interruptAttachingToTangleCommand {
+			return interc.serveInterruptAttachingToTangle(w, ip)
+		}
+		if command.Command == storeTransactionsCommand && interc.Config.InterceptStore {
+			return interc.serveStoreTransactions(w, r, command.Trytes, ip)
+		}
+		if command.Command == getTransactionsToApproveCommand && interc.tipCache != nil {
+			return interc.serveTransactionsToApprove(w, r, contents)
+		}
+		if interc.dedupCommands[command.Command] {
+			return interc.forwardDeduplicated(w, r, command.Command, contents)
+		}
 		return interc.Next.ServeHTTP(w, r)
 	}
 
-	if command.MWM > maxMWM || command.MWM < 0 {
-		return http.StatusBadRequest, errors.Wrapf(ErrInvalidMWM, "use mwm between 1-%d", maxMWM)
+	// nil unless tracing is enabled, spans ignore attributes then
+	var span *trace.Span
+	if interc.Config.OpenCensusTrace {
+		var ctx context.Context
+		ctx, span = trace.StartSpan(r.Context(), attachSpanName, trace.WithSampler(trace.AlwaysSample()))
+		r = r.WithContext(ctx)
+		span.AddAttributes(trace.StringAttribute(attrClientIP, ip), trace.Int64Attribute(attrMWM, int64(command.MWM)))
+		defer func() {
+			span.AddAttributes(trace.Int64Attribute(attrHTTPStatus, int64(status)))
+			if err != nil {
+				span.SetStatus(trace.Status{Code: trace.StatusCodeUnknown, Message: err.Error()})
+			}
+			span.End()
+		}()
+	}
+
+	if interc.Config.StrictJSON {
+		if err := decodeStrict(contents, &AttachToTangleReq{}); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	if interc.Config.MultiSigRequired > 0 {
+		if err := interc.checkAuthorizations(command); err != nil {
+			interc.logEntry(levelWarn, "unauthorized", fields, "rejecting attachToTangle request from %s: %v\n", ip, err)
+			return http.StatusUnauthorized, err
+		}
 	}
 
-	// only allow one PoW at a time
-	mu.Lock()
-	defer mu.Unlock()
+	if interc.bundleSchema != nil {
+		if err := interc.validateSchema(contents); err != nil {
+			interc.logEntry(levelWarn, "schema_violation", fields, "rejecting attachToTangle request from %s: %v\n", ip, err)
+			return http.StatusBadRequest, err
+		}
+	}
+
+	if interc.bodyCache != nil {
+		if err := interc.bodyCache.store(r, contents); err != nil {
+			if err := interc.warningToError("unable to cache request body: %v", err); err != nil {
+				return http.StatusInternalServerError, err
+			}
+		}
+	}
+
+	if err := validateMWM(command.MWM, interc.Config); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if interc.ipLimiter != nil && !interc.ipLimiter.allow(ip) {
+		interc.logEntry(levelWarn, "rate_limited", fields, "rate limiting attachToTangle request from %s\n", ip)
+		return interc.rateLimited(w, interc.ipLimiter.wait(ip), ErrRateLimited)
+	}
+
+	if interc.ipInterval != nil {
+		if ok, wait := interc.ipInterval.allow(ip); !ok {
+			interc.logEntry(levelWarn, "min_request_interval", fields, "rejecting attachToTangle request from %s sent before the minimum interval\n", ip)
+			w.Header().Set("Retry-After", retryAfterSeconds(wait))
+			return interc.rateLimited(w, wait, ErrRequestTooSoon)
+		}
+	}
+
+	if interc.globalLimiter != nil && !interc.globalLimiter.take() {
+		interc.logEntry(levelWarn, "global_rate_limited", fields, "global rate limit reached, rejecting attachToTangle request from %s\n", ip)
+		interc.setBackpressureHeaders(w, http.StatusServiceUnavailable, interc.globalLimiter.wait())
+		return http.StatusServiceUnavailable, ErrGlobalRateLimited
+	}
+
+	if interc.tipAge != nil && len(command.Trytes) > 0 {
+		if err := interc.tipAge.check(interc.Next, r, command.TrunkTxHash, command.BranchTxHash); err != nil {
+			if errors.Cause(err) == ErrStaleTip {
+				interc.logEntry(levelWarn, "stale_tip", fields, "rejecting attachToTangle request from %s: %v\n", ip, err)
+				return http.StatusBadRequest, err
+			}
+			return http.StatusBadGateway, errors.Wrap(err, "couldn't look up the age of trunk and branch")
+		}
+	}
+
+	if interc.confirmations != nil && len(command.Trytes) > 0 {
+		if err := interc.confirmations.check(interc.Next, r, command.BranchTxHash); err != nil {
+			if errors.Cause(err) == ErrBranchNotConfirmed {
+				interc.logEntry(levelWarn, "branch_not_confirmed", fields, "rejecting attachToTangle request from %s: %v\n", ip, err)
+				return http.StatusBadRequest, err
+			}
+			return http.StatusBadGateway, errors.Wrap(err, "couldn't look up the inclusion state of the branch")
+		}
+	}
+
+	if interc.Config.MaxPendingQueueBytes > 0 {
+		size := int64(len(contents))
+		if atomic.AddInt64(&interc.pendingBytes, size) > interc.Config.MaxPendingQueueBytes {
+			atomic.AddInt64(&interc.pendingBytes, -size)
+			interc.logEntry(levelWarn, "queue_full", fields, "queue memory limit reached, rejecting attachToTangle request from %s\n", ip)
+			interc.setBackpressureHeaders(w, http.StatusServiceUnavailable, 0)
+			return http.StatusServiceUnavailable, ErrQueueMemoryFull
+		}
+		defer atomic.AddInt64(&interc.pendingBytes, -size)
+	}
+
+	atomic.AddInt32(&interc.queueDepth, 1)
+	defer atomic.AddInt32(&interc.queueDepth, -1)
 
 	trunkTxHash := command.TrunkTxHash
 	branchTxHash := command.BranchTxHash
 	txTrytes := command.Trytes
 
 	if len(txTrytes) == 0 {
+		if interc.Config.InjectCoordinatorTips {
+			if err := injectTips(r, contents, interc.Config.CoordinatorTrunk, interc.Config.CoordinatorBranch); err != nil {
+				return http.StatusBadRequest, errors.Wrap(err, "couldn't inject coordinator tips")
+			}
+		}
 		return interc.Next.ServeHTTP(w, r)
 	}
 
-	logger.Printf("new attachToTangle request from %s\n", r.RemoteAddr)
-	if len(txTrytes) > maxTxInBundle {
-		logger.Printf("canceling request as it exceeds the txs per bundle limit (%d>%d)\n", len(txTrytes), maxTxInBundle)
-		return http.StatusBadRequest, errors.Wrapf(ErrTxBundleLimitExceeded, "max allowed is %d", maxTxInBundle)
+	interc.logEntry(levelInfo, "attach_request", fields, "new attachToTangle request from %s\n", ip)
+	if len(txTrytes) > interc.Config.MaxTxInBundle {
+		interc.logEntry(levelWarn, "too_many_txs", fields, "canceling request as it exceeds the txs per bundle limit (%d>%d)\n", len(txTrytes), interc.Config.MaxTxInBundle)
+		return http.StatusBadRequest, errors.Wrapf(ErrTxBundleLimitExceeded, "max allowed is %d", interc.Config.MaxTxInBundle)
 	}
-	start := time.Now().UnixNano()
+	if len(txTrytes) < interc.Config.MinTxInBundle {
+		interc.logEntry(levelWarn, "too_few_txs", fields, "canceling request as it has less txs than required (%d<%d)\n", len(txTrytes), interc.Config.MinTxInBundle)
+		return http.StatusBadRequest, errors.Wrapf(ErrBundleTooSmall, "min required is %d", interc.Config.MinTxInBundle)
+	}
+	// the key is computed before the trytes are modified, the cache is only looked up
+	// once the bundle passed all checks
+	var cacheKey [sha256.Size]byte
+	if interc.powCache != nil {
+		cacheKey = powCacheKey(command)
+	}
+	if interc.Config.NormalizeTags {
+		if err := interc.normalizeTags(txTrytes); err != nil {
+			interc.logEntry(levelWarn, "tag_not_normalized", fields, "rejecting request: %v\n", err)
+			return http.StatusBadRequest, err
+		}
+	}
+	if interc.alphabet != nil {
+		if err := interc.checkAlphabet(trunkTxHash, branchTxHash, txTrytes); err != nil {
+			interc.logEntry(levelWarn, "invalid_trytes", fields, "rejecting request: %v\n", err)
+			return http.StatusBadRequest, err
+		}
+	}
+	now := time.Now()
+	start := now.UnixNano()
 
 	var isValueBundle bool
-	var inputValue int64
+	var inputValue, maxOutputValue int64
+	var skipped []int
 	transactions := make([]transaction.Transaction, len(txTrytes))
-	txsCount := len(transactions)
 	for i := len(txTrytes) - 1; i >= 0; i-- {
 		tx, err := transaction.AsTransactionObject(txTrytes[i])
 		if err != nil {
-			return http.StatusBadRequest, ErrBuildingTx
+			if !interc.Config.PartialBundleRecovery {
+				return http.StatusBadRequest, ErrBuildingTx
+			}
+			interc.logEntry(levelWarn, "invalid_tx_skipped", fields, "skipping invalid transaction at index %d: %v\n", i, err)
+			skipped = append([]int{i}, skipped...)
+			continue
+		}
+		if skew := time.Unix(int64(tx.Timestamp), 0).Sub(now); skew > maxTimestampSkew {
+			if err := interc.warningToError("transaction at index %d has a timestamp %v in the future", i, skew.Truncate(time.Second)); err != nil {
+				return http.StatusBadRequest, err
+			}
 		}
 		if tx.Value != 0 {
 			isValueBundle = true
-			val := units.ConvertUnits(math.Abs(float64(tx.Value)), units.I, units.Mi)
 			if tx.Value < 0 {
 				inputValue += tx.Value
-				logger.Printf("%s - [input] %.6f Mi\n", tx.Address, -val)
+				interc.logEntry(levelInfo, "tx_input", fields, "%s - [input] %s (%s)\n", tx.Address, interc.logValue(tx.Value), detectAddressScheme(tx.Address))
 			} else {
-				logger.Printf("%s - [output] %.6f Mi\n", tx.Address, -val)
+				if tx.Value > maxOutputValue {
+					maxOutputValue = tx.Value
+				}
+				interc.logEntry(levelInfo, "tx_output", fields, "%s - [output] %s (%s)\n", tx.Address, interc.logValue(tx.Value), detectAddressScheme(tx.Address))
 			}
+		} else if tx.SignatureMessageFragment != consts.NullSignatureMessageFragmentTrytes {
+			interc.logEntry(levelInfo, "tx_message", fields, "%s - [message] %s\n", tx.Address, interc.logMessage(tx.SignatureMessageFragment))
 		}
 		transactions[i] = *tx
 	}
 
-	logger.Printf("bundle: %s\n", transactions[0].Bundle)
+	if len(skipped) == len(txTrytes) {
+		return http.StatusBadRequest, ErrBuildingTx
+	}
+	if len(skipped) > 0 {
+		txTrytes, transactions = withoutIndices(txTrytes, transactions, skipped)
+	}
+	txsCount := len(transactions)
+
+	span.AddAttributes(
+		trace.Int64Attribute(attrBundleTxs, int64(txsCount)),
+		trace.StringAttribute(attrBundleHash, transactions[0].Bundle),
+		trace.BoolAttribute(attrValueBundle, isValueBundle),
+	)
+	fields.BundleHash = transactions[0].Bundle
+	fields.TxCount = txsCount
+	fields.IsValueBundle = isValueBundle
+	fields.InputMi = units.ConvertUnits(float64(-inputValue), units.I, units.Mi)
+	fields.bundleLog = interc.bundleLog(isValueBundle)
+	interc.logEntry(levelInfo, "bundle", fields, "bundle: %s, trunk: %s, branch: %s\n", interc.logHash(transactions[0].Bundle), interc.logHash(trunkTxHash), interc.logHash(branchTxHash))
+
+	if tag := transactions[0].Tag; interc.deniedTags[tag] || (interc.allowedTags != nil && !interc.allowedTags[tag]) {
+		interc.logEntry(levelWarn, "tag_denied", fields, "rejecting bundle with tag %s\n", tag)
+		return http.StatusForbidden, errors.Wrapf(ErrTagDenied, "tag %s", tag)
+	}
 
 	if isValueBundle {
-		logger.Printf("bundle is using %.6f Mi as input\n", units.ConvertUnits(float64(inputValue), units.I, units.Mi))
+		if err := validateAddressSchemes(transactions); err != nil {
+			interc.logEntry(levelWarn, "unknown_address_scheme", fields, "rejecting value bundle: %v\n", err)
+			return http.StatusUnprocessableEntity, err
+		}
+		if command.MWM < interc.Config.MinMWMValueBundle {
+			interc.logEntry(levelWarn, "value_bundle_mwm_too_low", fields, "rejecting value bundle with MWM %d below %d\n", command.MWM, interc.Config.MinMWMValueBundle)
+			return http.StatusBadRequest, errors.Wrapf(ErrInvalidMWM, "value bundles need mwm between %d-%d", interc.Config.MinMWMValueBundle, interc.Config.MaxMWM)
+		}
 	}
 
-	logger.Printf("doing PoW for bundle with %d txs...\n", txsCount)
-	s := time.Now().UnixNano()
-	powedBundle, err := pow.DoPoW(trunkTxHash, branchTxHash, txTrytes, uint64(command.MWM), powFn)
-	if err != nil {
-		return http.StatusBadRequest, ErrExecutingProofOfWork
+	if interc.Config.MaxValue > 0 && -inputValue > interc.Config.MaxValue {
+		interc.logEntry(levelWarn, "value_limit_exceeded", fields, "rejecting bundle moving %s as it exceeds the max value of %s\n", interc.logValue(-inputValue), interc.logValue(interc.Config.MaxValue))
+		return http.StatusForbidden, errors.Wrapf(ErrValueLimitExceeded, "max allowed is %di", interc.Config.MaxValue)
+	}
+	if interc.Config.MaxOutputValue > 0 && maxOutputValue > interc.Config.MaxOutputValue {
+		interc.logEntry(levelWarn, "output_value_too_large", fields, "rejecting bundle sending %s to a single output as it exceeds the max of %s\n", interc.logValue(maxOutputValue), interc.logValue(interc.Config.MaxOutputValue))
+		return http.StatusBadRequest, errors.Wrapf(ErrOutputValueTooLarge, "max allowed per output is %di", interc.Config.MaxOutputValue)
 	}
 
-	logger.Printf("took %dms to do PoW for bundle with %d txs\n", (time.Now().UnixNano()-s)/1000000, txsCount)
+	if status, err := interc.validateBundle(transactions, txTrytes); err != nil {
+		interc.logEntry(levelWarn, "invalid_bundle", fields, "rejecting bundle: %v\n", err)
+		return status, err
+	}
 
-	res := &AttachToTangleRes{Trytes: powedBundle, Duration: (time.Now().UnixNano() - start) / 1000000}
+	if interc.balances != nil && isValueBundle {
+		if err := interc.balances.check(interc.Next, r, transactions); err != nil {
+			if errors.Cause(err) == ErrInsufficientBalance {
+				interc.logEntry(levelWarn, "insufficient_balance", fields, "rejecting bundle: %v\n", err)
+				return http.StatusBadRequest, err
+			}
+			return http.StatusBadGateway, errors.Wrap(err, "couldn't look up the balances of the input addresses")
+		}
+	}
+
+	if interc.Config.MinConfirmationsBeforeReattach > 0 {
+		if err := checkAlreadyConfirmed(interc.Next, r, transactions, interc.Config.MinConfirmationsBeforeReattach); err != nil {
+			if errors.Cause(err) == ErrAlreadyConfirmed {
+				interc.logEntry(levelWarn, "already_confirmed", fields, "rejecting re-attachment of bundle %s: %v\n", interc.logHash(transactions[0].Bundle), err)
+				return http.StatusConflict, err
+			}
+			return http.StatusBadGateway, errors.Wrap(err, "couldn't look up the inclusion states of the transactions")
+		}
+	}
 
-	resBytes, err := json.Marshal(res)
+	if !interc.tagLimiter.allow(string(transactions[0].Tag)) {
+		// the rejected bundle shouldn't count against the IP's own limit
+		if interc.ipLimiter != nil {
+			interc.ipLimiter.refund(ip)
+		}
+		interc.logEntry(levelWarn, "tag_rate_limited", fields, "rate limiting bundle with tag %s\n", transactions[0].Tag)
+		return interc.rateLimited(w, interc.tagLimiter.wait(string(transactions[0].Tag)), ErrRateLimited)
+	}
+
+	if isValueBundle {
+		interc.logEntry(levelInfo, "value_bundle", fields, "bundle is using %s as input\n", interc.logValue(inputValue))
+	}
+
+	if interc.reservations != nil && isValueBundle {
+		// released once the PoW completed or failed
+		if addr, ok := interc.reservations.reserve(transactions); !ok {
+			interc.logEntry(levelWarn, "address_locked", fields, "rejecting bundle %s spending %s whose funds are locked by a pending PoW\n", interc.logHash(transactions[0].Bundle), addr)
+			return http.StatusConflict, errors.Wrapf(ErrAddressLocked, "address %s", addr)
+		}
+		defer interc.reservations.release(transactions)
+	}
+
+	// dry runs always do the PoW
+	if interc.powCache != nil && !interc.Config.DryRun {
+		if res, powImplName := interc.powCache.get(cacheKey); res != nil {
+			interc.logEntry(levelInfo, "pow_cache_hit", fields, "answering attachToTangle request from %s with a cached PoW result\n", ip)
+			resBytes, err := interc.marshalAttachRes(res)
+			if err != nil {
+				return http.StatusInternalServerError, ErrBuildingRes
+			}
+			return interc.writeAttachRes(w, resBytes, powImplName)
+		}
+	}
+
+	var powedBundle []trinary.Trytes
+	if interc.prefetch != nil && !interc.Config.DryRun {
+		powedBundle = interc.prefetch.lookup(trunkTxHash, branchTxHash, command.MWM, txTrytes)
+	}
+	powImpl := PoWImpl{Name: interc.powImplName, Fn: interc.powFn}
+	if powedBundle != nil {
+		interc.logEntry(levelInfo, "pow_prefetched", fields, "using prefetched PoW for bundle with %d txs\n", txsCount)
+		span.AddAttributes(trace.BoolAttribute(attrPoWPrefetch, true))
+	} else {
+		if !interc.beginPoW() {
+			interc.logEntry(levelWarn, "shutting_down", fields, "rejecting attachToTangle request from %s as the server is shutting down\n", ip)
+			return http.StatusServiceUnavailable, ErrServerShuttingDown
+		}
+		defer interc.endPoW()
+		if interc.Config.CorrectAttachmentTimestamp {
+			var err error
+			if txTrytes, err = interc.correctAttachmentTimestamps(transactions, time.Now(), fields); err != nil {
+				return http.StatusBadRequest, errors.Wrap(ErrBuildingTx, err.Error())
+			}
+		}
+		if interc.metadata != nil {
+			var err error
+			if txTrytes, err = interc.metadata.embed(txTrytes, time.Now()); err != nil {
+				return http.StatusInternalServerError, errors.Wrap(ErrBuildingTx, err.Error())
+			}
+		}
+		if interc.remotePoW != nil {
+			s := time.Now()
+			remoteReq := &AttachToTangleReq{Command: attachToTangleCommand, TrunkTxHash: trunkTxHash, BranchTxHash: branchTxHash, MWM: command.MWM, Trytes: txTrytes}
+			if remoteBundle, err := interc.remotePoW.do(r.Context(), remoteReq); err != nil {
+				interc.logEntry(levelWarn, "remote_pow_failed", fields, "remote PoW for bundle %s failed, doing it locally: %v\n", interc.logHash(transactions[0].Bundle), err)
+			} else {
+				powedBundle = remoteBundle
+				powImpl.Name = remotePoWImplName
+				fields.PoWMs = time.Since(s).Nanoseconds() / 1000000
+				interc.recordPoWDuration(fields.PoWMs)
+				interc.logEntry(levelInfo, "pow_done", fields, "took %dms to do remote PoW for bundle with %d txs\n", fields.PoWMs, txsCount)
+			}
+		}
+		if powedBundle == nil {
+			priority := basePoWPriority
+			if isValueBundle {
+				priority *= interc.Config.ValueBundlePriorityBoost
+			}
+			jobCtx, job := interc.interrupts.register(interc.jobContext(r), ip)
+			defer interc.interrupts.unregister(ip, job)
+			// the per IP slot is acquired first so waiting clients don't block global slots
+			releaseIP := func() {}
+			if interc.ipPoW != nil {
+				if err := interc.ipPoW.acquire(jobCtx, ip); err != nil {
+					return interc.powAborted(job, fields, transactions[0].Bundle)
+				}
+				releaseIP = func() { interc.ipPoW.release(ip) }
+			}
+			if interc.Config.RejectWhenWorkersBusy {
+				if !interc.scheduler.tryAcquire() {
+					releaseIP()
+					interc.logEntry(levelWarn, "workers_busy", fields, "rejecting attachToTangle request from %s as all PoW workers are busy\n", ip)
+					interc.setBackpressureHeaders(w, http.StatusTooManyRequests, time.Duration(interc.powDuration.get()*float64(time.Millisecond)))
+					return http.StatusTooManyRequests, ErrPoWQueueFull
+				}
+			} else if err := interc.scheduler.acquire(jobCtx, priority); err != nil {
+				releaseIP()
+				if err == errSchedulerClosed {
+					interc.logEntry(levelWarn, "shutting_down", fields, "rejecting queued attachToTangle request from %s as the server is shutting down\n", ip)
+					return http.StatusServiceUnavailable, ErrServerShuttingDown
+				}
+				return interc.powAborted(job, fields, transactions[0].Bundle)
+			}
+			activePoWWorkers.Inc()
+			// handed to doCancelablePoW, the slots stay taken until the PoW returned
+			releaseSlots := func() {
+				activePoWWorkers.Dec()
+				interc.scheduler.release()
+				releaseIP()
+			}
+
+			powImpl = interc.powImplFor(r)
+			interc.logEntry(levelInfo, "pow_start", fields, "doing PoW for bundle with %d txs using %s...\n", txsCount, powImpl.Name)
+			powFn := powImpl.Fn
+			measurement := &powMeasurement{}
+			if interc.Config.PoWMinHashesPerSec > 0 {
+				powFn = measurePoW(powFn, measurement)
+			}
+			s := time.Now().UnixNano()
+			var err error
+			powRequestsTotal.Inc()
+			timeout, clientTimeout := interc.powTimeout(r)
+			ctx, cancel := powContext(jobCtx, timeout)
+			powedBundle, err = interc.doCancelablePoW(ctx, trunkTxHash, branchTxHash, txTrytes, uint64(command.MWM), powFn, releaseSlots)
+			cancel()
+			if err == ErrPoWCanceled {
+				return interc.powAborted(job, fields, transactions[0].Bundle)
+			}
+			if err == ErrPoWTimeout {
+				interc.logEntry(levelError, "pow_timeout", fields, "PoW for bundle %s with %d txs took longer than %v\n", interc.logHash(transactions[0].Bundle), txsCount, timeout)
+				powFailuresTotal.Inc()
+				timeoutStatus := http.StatusServiceUnavailable
+				if clientTimeout {
+					// the client's deadline passed, not the node's
+					timeoutStatus = http.StatusGatewayTimeout
+				}
+				interc.audit("pow_timeout", timeoutStatus, fields)
+				return timeoutStatus, err
+			}
+			if err != nil {
+				interc.logEntry(levelError, "pow_failed", fields, "PoW for bundle with %d txs failed: %v\n", txsCount, err)
+				powFailuresTotal.Inc()
+				interc.audit("pow_failed", ClassifyPoWError(err), fields)
+				return ClassifyPoWError(err), errors.Wrapf(ErrExecutingProofOfWork, "%v", err)
+			}
+
+			powMs := (time.Now().UnixNano() - s) / 1000000
+			interc.powDuration.add(float64(powMs))
+			interc.recordPoWDuration(powMs)
+			fields.PoWMs = powMs
+			interc.logEntry(levelInfo, "pow_done", fields, "took %dms to do PoW for bundle with %d txs\n", powMs, txsCount)
+			span.AddAttributes(trace.Int64Attribute(attrPoWDuration, powMs))
+			if interc.Config.PoWMinHashesPerSec > 0 {
+				interc.checkPoWRate(measurement, txsCount)
+			}
+		}
+	}
+	span.AddAttributes(trace.StringAttribute(attrPoWImpl, powImpl.Name))
+
+	if interc.Config.DryRun {
+		interc.audit("pow_dry_run", http.StatusOK, fields)
+		return writeJSON(w, &dryRunRes{DryRun: true, PoWMs: fields.PoWMs, TxCount: len(powedBundle)})
+	}
+
+	if interc.Config.AlertWebhook != "" && isValueBundle {
+		interc.alertHighValue(transactions[0].Bundle, inputValue, ip)
+	}
+
+	res := &AttachToTangleRes{
+		Trytes:         powedBundle,
+		Duration:       (time.Now().UnixNano() - start) / 1000000,
+		Checksum:       trytesChecksum(powedBundle),
+		SkippedIndices: skipped,
+	}
+	if interc.Config.IncludeCommandInResponse {
+		res.Command = attachToTangleCommand
+	}
+	powDurationMs.Observe(float64(res.Duration))
+
+	if interc.resultBackup != nil {
+		if err := interc.resultBackup.store(transactions[0].Bundle, res); err != nil {
+			interc.logEntry(levelError, "result_backup_failed", fields, "unable to back up PoW result of bundle %s: %v\n", interc.logHash(transactions[0].Bundle), err)
+		}
+	}
+
+	resBytes, err := interc.marshalAttachRes(res)
 	if err != nil {
 		return http.StatusInternalServerError, ErrBuildingRes
 	}
 
-	w.Header().Set(contentType, contentTypeJSON)
-	w.Header().Set("access-control-allow-origin", "*")
+	if interc.powCache != nil {
+		interc.powCache.put(cacheKey, res, powImpl.Name)
+	}
+
+	if interc.tipCache != nil {
+		interc.tipCache.invalidate(trunkTxHash, branchTxHash)
+	}
+
+	if interc.rebroadcaster != nil {
+		if err := interc.rebroadcaster.schedule(interc.Next, r, powedBundle); err != nil {
+			interc.logEntry(levelError, "rebroadcast_failed", fields, "unable to schedule rebroadcast: %v\n", err)
+		}
+	}
+
+	if interc.autoBroadcast != nil {
+		go func() {
+			if err := interc.autoBroadcast.broadcast(powedBundle); err != nil {
+				interc.logEntry(levelError, "auto_broadcast_failed", fields, "unable to broadcast bundle %s: %v\n", interc.logHash(transactions[0].Bundle), err)
+			}
+		}()
+	}
+
+	if interc.natsPub != nil {
+		go func() {
+			if err := interc.natsPub.publish(resBytes); err != nil {
+				interc.logEntry(levelError, "nats_publish_failed", fields, "unable to publish PoW result to NATS: %v\n", err)
+			}
+		}()
+	}
+
+	if interc.tagStats != nil {
+		interc.tagStats.record(transactions[0].Tag, fields.PoWMs, fields.InputMi)
+	}
+	interc.audit("pow_done", http.StatusOK, fields)
+	if interc.resultRouter != nil {
+		if sink := interc.resultRouter.sink(string(transactions[0].Tag)); sink != nil {
+			return interc.deliverResult(w, sink, resBytes, powImpl.Name, fields)
+		}
+	}
+	return interc.writeAttachRes(w, resBytes, powImpl.Name)
+}
+
+type dryRunRes struct {
+	DryRun  bool  `json:"dry_run"`
+	PoWMs   int64 `json:"pow_ms"`
+	TxCount int   `json:"tx_count"`
+}
+
+// marshalAttachRes encodes the attachToTangle response in the configured output format.
+func (interc *Interceptor) marshalAttachRes(res *AttachToTangleRes) ([]byte, error) {
+	var resObj interface{} = res
+	if interc.Config.OutputFormat == outputFormatChrysalis {
+		var err error
+		if resObj, err = toChrysalisRes(res); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(resObj)
+}
+
+// writeAttachRes writes the encoded attachToTangle response computed by the given PoW implementation.
+func (interc *Interceptor) writeAttachRes(w http.ResponseWriter, resBytes []byte, powImplName string) (int, error) {
+	interc.setResponseHeaders(w)
+	w.Header().Set(headerPoWImpl, powImplName)
+	interc.signResponse(w, resBytes)
 	if _, err := w.Write(resBytes); err != nil {
 		return http.StatusInternalServerError, ErrBuildingRes
 	}
 	return http.StatusOK, nil
 }
+
+// setNext sets the handler requests are forwarded to, which is IRI if forwarded to directly
+// and none in light node mode.
+func (interc *Interceptor) setNext(next httpserver.Handler) {
+	switch {
+	case interc.Config.LightNodeMode:
+		interc.Next = lightNode{}
+	case interc.iri != nil:
+		interc.Next = interc.iri
+	default:
+		interc.Next = next
+	}
+}
+
+// setResponseHeaders sets the headers of an intercepted attachToTangle response.
+func (interc *Interceptor) setResponseHeaders(w http.ResponseWriter) {
+	w.Header().Set(contentType, contentTypeJSON)
+	interc.setCORSHeaders(w)
+	w.Header().Set(headerPoWImpl, interc.powImplName)
+}
+
+// setCORSHeaders allows the configured origin to read the response.
+func (interc *Interceptor) setCORSHeaders(w http.ResponseWriter) {
+	w.Header().Set(headerAllowOrigin, interc.Config.CORSOrigin)
+	if interc.Config.CORSOrigin != "*" {
+		// the response differs per origin for caches
+		w.Header().Add("Vary", "Origin")
+	}
+}
+
+// queueDrainTime estimates how long it takes until the queued requests are done.
+func (interc *Interceptor) queueDrainTime() time.Duration {
+	depth := float64(atomic.LoadInt32(&interc.queueDepth))
+	return time.Duration(depth * interc.powDuration.get() * float64(time.Millisecond))
+}
+
+// rateLimited returns the configured rate limit status code and sets the matching
+// backpressure headers for a limit which resets after the given duration.
+func (interc *Interceptor) rateLimited(w http.ResponseWriter, reset time.Duration, err error) (int, error) {
+	status := interc.Config.RateLimitStatusCode
+	interc.setBackpressureHeaders(w, status, reset)
+	return status, err
+}
+
+// setBackpressureHeaders tells the client when to retry: 429 responses get the unix time
+// the limit resets as X-RateLimit-Reset, 503 responses a Retry-After of at least the
+// given duration and the time the queue needs to drain, capped at the configured maximum.
+func (interc *Interceptor) setBackpressureHeaders(w http.ResponseWriter, status int, reset time.Duration) {
+	switch status {
+	case http.StatusTooManyRequests:
+		resetAt := time.Now().Add(reset)
+		secs := resetAt.Unix()
+		if resetAt.Nanosecond() > 0 {
+			secs++
+		}
+		w.Header().Set(headerRateLimitReset, strconv.FormatInt(secs, 10))
+	case http.StatusServiceUnavailable:
+		if drain := interc.queueDrainTime(); drain > reset {
+			reset = drain
+		}
+		w.Header().Set("Retry-After", clampedRetryAfter(reset, interc.Config.MaxRetryAfter))
+	}
+}
+
+// validateBundle runs the configured validations on the parsed transactions of a bundle
+// and their trytes in the same order.
+func (interc *Interceptor) validateBundle(transactions []transaction.Transaction, txTrytes []trinary.Trytes) (int, error) {
+	if err := validateAddressTypes(transactions, interc.Config.AllowedAddressTypes); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	if interc.Config.ValidateNetworkMagic {
+		if err := validateNetworkMagic(txTrytes, interc.Config.NetworkMagicByte); err != nil {
+			return http.StatusBadRequest, err
+		}
+	}
+
+	if interc.Config.ValidateBundleHash {
+		if err := validateBundleHash(transactions, bundleHashAlgorithms[interc.Config.BundleHashAlgorithm]); err != nil {
+			return http.StatusUnprocessableEntity, err
+		}
+	}
+	if err := validateBundleSignatures(transactions); err != nil {
+		return http.StatusUnprocessableEntity, err
+	}
+
+	if interc.bundlePins != nil {
+		if err := interc.bundlePins.check(transactions); err != nil {
+			if errors.Cause(err) == ErrBundlePinMismatch {
+				return http.StatusConflict, err
+			}
+			return http.StatusInternalServerError, err
+		}
+	}
+	return 0, nil
+}
+
+// checkAlphabet checks the tips and transaction trytes against the configured alphabet.
+func (interc *Interceptor) checkAlphabet(trunk, branch trinary.Hash, txTrytes []trinary.Trytes) error {
+	if err := interc.alphabet.check(trunk); err != nil {
+		return errors.Wrap(err, "trunk transaction")
+	}
+	if err := interc.alphabet.check(branch); err != nil {
+		return errors.Wrap(err, "branch transaction")
+	}
+	for i, trytes := range txTrytes {
+		if err := interc.alphabet.check(trytes); err != nil {
+			return errors.Wrapf(err, "transaction at index %d", i)
+		}
+	}
+	return nil
+}
+
+// warningToError logs the given warning and returns nil, or, in strict mode,
+// returns it as an error instead so the request gets rejected.
+func (interc *Interceptor) warningToError(format string, args ...interface{}) error {
+	if interc.Config.StrictMode {
+		return errors.Wrapf(ErrStrictMode, format, args...)
+	}
+	interc.logEntry(levelWarn, "warning", logFields{}, "warning: "+format+"\n", args...)
+	return nil
+}
+
+const redacted = "[REDACTED]"
+
+// logMessage decodes the ASCII message of the given signature message fragment for log output
+// or redacts it if configured.
+func (interc *Interceptor) logMessage(fragment trinary.Trytes) string {
+	if interc.Config.RedactMessageFragments {
+		return redacted
+	}
+	fragment = strings.TrimRight(fragment, "9")
+	if len(fragment)%2 != 0 {
+		fragment += "9"
+	}
+	msg, err := converter.TrytesToASCII(fragment)
+	if err != nil {
+		return fragment
+	}
+	return strconv.Quote(msg)
+}
+
+const (
+	logHashFormatFull  = "full"
+	logHashFormatShort = "short"
+)
+
+// amount of trytes logged of hashes in the short format
+const shortHashLength = 8
+
+// formatHash returns the given hash for log output in the given format.
+func formatHash(h string, mode string) string {
+	if mode != logHashFormatShort || len(h) <= shortHashLength {
+		return h
+	}
+	return h[:shortHashLength] + "..."
+}
+
+// logHash formats the given hash for log output, shortening it to the configured length
+// in the full format.
+func (interc *Interceptor) logHash(hash trinary.Hash) string {
+	if interc.Config.LogHashFormat == logHashFormatFull && len(hash) > interc.Config.LogHashLength {
+		return hash[:interc.Config.LogHashLength] + "..."
+	}
+	return formatHash(hash, interc.Config.LogHashFormat)
+}
+
+// logValue formats the given amount of iotas for log output in the configured unit.
+func (interc *Interceptor) logValue(iotas int64) string {
+	unit := interc.Config.LogValueUnit
+	if unit == "i" {
+		return fmt.Sprintf("%d i", iotas)
+	}
+	return fmt.Sprintf("%.6f %s", units.ConvertUnits(float64(iotas), units.I, valueUnits[unit]), unit)
+}
+
+// decodeStrict decodes the given JSON into obj and returns ErrUnknownJSONField
+// naming the field if the JSON contains fields obj doesn't have.
+func decodeStrict(contents []byte, obj interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(contents))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(obj)
+	if err == nil {
+		return nil
+	}
+	if field := strings.TrimPrefix(err.Error(), "json: unknown field "); field != err.Error() {
+		return errors.Wrapf(ErrUnknownJSONField, "field %s", field)
+	}
+	return err
+}
+
+// injectTips replaces the trunk and branch transaction of the given attachToTangle
+// request body and sets it as the new body of the request.
+func injectTips(r *http.Request, contents []byte, trunk, branch trinary.Hash) error {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(contents, &fields); err != nil {
+		return err
+	}
+	fields["trunkTransaction"], _ = json.Marshal(trunk)
+	fields["branchTransaction"], _ = json.Marshal(branch)
+	rewritten, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(rewritten))
+	r.ContentLength = int64(len(rewritten))
+	r.Header.Set("Content-Length", strconv.Itoa(len(rewritten)))
+	return nil
+}
+
+// withoutIndices removes the entries at the given ascending indices from the trytes and transactions.
+func withoutIndices(txTrytes []trinary.Trytes, txs []transaction.Transaction, indices []int) ([]trinary.Trytes, []transaction.Transaction) {
+	keptTrytes := make([]trinary.Trytes, 0, len(txTrytes)-len(indices))
+	keptTxs := make([]transaction.Transaction, 0, len(txs)-len(indices))
+	for i := range txTrytes {
+		if len(indices) > 0 && indices[0] == i {
+			indices = indices[1:]
+			continue
+		}
+		keptTrytes = append(keptTrytes, txTrytes[i])
+		keptTxs = append(keptTxs, txs[i])
+	}
+	return keptTrytes, keptTxs
+}
+
+// clientIP returns the IP part of the request's remote address or, behind the configured
+// trusted proxies or amount of proxies, the client IP they forwarded.
+func (interc *Interceptor) clientIP(r *http.Request) string {
+	if len(interc.Config.TrustedProxies) > 0 {
+		return realIP(r, interc.Config.TrustedProxies)
+	}
+	if depth := interc.Config.ProxyDepth; depth > 0 {
+		if forwarded := forwardedFor(r); len(forwarded) > 0 {
+			if depth > len(forwarded) {
+				depth = len(forwarded)
+			}
+			return forwarded[len(forwarded)-depth]
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// realIP returns the client IP of the request. The X-Forwarded-For and X-Real-IP headers
+// are only used if the remote address is in one of the trusted networks. Of X-Forwarded-For
+// the rightmost entry not added by a trusted proxy is taken, so clients can't spoof their IP
+// by sending the header themselves.
+func realIP(r *http.Request, trustedNets []*net.IPNet) string {
+	peer, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		peer = r.RemoteAddr
+	}
+	if !trustedIP(peer, trustedNets) {
+		return peer
+	}
+	if forwarded := forwardedFor(r); len(forwarded) > 0 {
+		for i := len(forwarded) - 1; i > 0; i-- {
+			if !trustedIP(forwarded[i], trustedNets) {
+				return forwarded[i]
+			}
+		}
+		return forwarded[0]
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return peer
+}
+
+// trustedIP reports whether the IP is in one of the trusted networks.
+func trustedIP(ip string, trustedNets []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trustedNets {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor returns the IPs of all X-Forwarded-For headers of the request in order.
+func forwardedFor(r *http.Request) []string {
+	var ips []string
+	for _, header := range r.Header[http.CanonicalHeaderKey("X-Forwarded-For")] {
+		for _, ip := range strings.Split(header, ",") {
+			if ip = strings.TrimSpace(ip); ip != "" {
+				ips = append(ips, ip)
+			}
+		}
+	}
+	return ips
+}
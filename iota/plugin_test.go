@@ -0,0 +1,850 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"hash/crc32"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/converter"
+	"github.com/iotaledger/iota.go/pow"
+	"github.com/iotaledger/iota.go/signing"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+// nullPoW skips the actual work so tests don't depend on the hardware.
+func nullPoW(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+	return consts.NullNonceTrytes, nil
+}
+
+// countingNext is a Next handler recording how often it was invoked and the last forwarded body.
+type countingNext struct {
+	calls int
+	body  []byte
+}
+
+func (n *countingNext) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
+	n.calls++
+	if r.Body != nil {
+		n.body, _ = ioutil.ReadAll(r.Body)
+	}
+	return http.StatusOK, nil
+}
+
+func newTestInterceptor(t *testing.T, cfg *Config) (*Interceptor, *countingNext) {
+	next := &countingNext{}
+	interc, err := newInterceptor(cfg, "Null", nullPoW, logger)
+	if err != nil {
+		t.Fatalf("unable to create interceptor: %v", err)
+	}
+	interc.Next = next
+	return interc, next
+}
+
+// testTx returns a transaction with the given tag and value and all other fields empty.
+func testTx(tag trinary.Trytes, value int64) transaction.Transaction {
+	return transaction.Transaction{
+		SignatureMessageFragment: consts.NullSignatureMessageFragmentTrytes,
+		Address:                  consts.NullHashTrytes,
+		Value:                    value,
+		ObsoleteTag:              trinary.Pad(tag, 27),
+		Bundle:                   consts.NullHashTrytes,
+		TrunkTransaction:         consts.NullHashTrytes,
+		BranchTransaction:        consts.NullHashTrytes,
+		Tag:                      trinary.Pad(tag, 27),
+		Nonce:                    consts.NullNonceTrytes,
+	}
+}
+
+// txTrytes builds the trytes of a valid single transaction bundle with the given tag and value.
+func txTrytes(t *testing.T, tag trinary.Trytes, value int64) trinary.Trytes {
+	return bundleTrytes(t, defaultBundleHashAlgorithm, testTx(tag, value))[0]
+}
+
+// dataBundleTrytes builds the trytes of a valid data bundle of n transactions with the given tag.
+func dataBundleTrytes(t *testing.T, tag trinary.Trytes, n int) []trinary.Trytes {
+	txs := make([]transaction.Transaction, n)
+	for i := range txs {
+		txs[i] = testTx(tag, 0)
+	}
+	return bundleTrytes(t, defaultBundleHashAlgorithm, txs...)
+}
+
+// the seed the inputs of test bundles are signed with
+const testSeed = "TESTSEED9TESTSEED9TESTSEED9TESTSEED9TESTSEED9TESTSEED9TESTSEED9TESTSEED9TESTSEED9"
+
+// testInput is the security level 1 key and address of the seed's address at an index.
+type testInput struct {
+	key     trinary.Trits
+	address trinary.Hash
+}
+
+var testInputs = struct {
+	sync.Mutex
+	inputs map[uint64]testInput
+}{inputs: map[uint64]testInput{}}
+
+// testInputAt derives the key and address of the test seed at the given index,
+// they are cached as deriving them is slow.
+func testInputAt(t *testing.T, index uint64) testInput {
+	testInputs.Lock()
+	defer testInputs.Unlock()
+	if input, ok := testInputs.inputs[index]; ok {
+		return input
+	}
+	subseed, err := signing.Subseed(testSeed, index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := signing.Key(subseed, consts.SecurityLevelLow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Digests hashes the key in place
+	digests, err := signing.Digests(append(trinary.Trits(nil), key...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	addressTrits, err := signing.Address(digests)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := testInput{key: key, address: trinary.MustTritsToTrytes(addressTrits)}
+	testInputs.inputs[index] = input
+	return input
+}
+
+// bundleTrytes sets the indices and the bundle hash computed with the given algorithm on the
+// transactions and returns their trytes from the highest to the lowest index, as clients send them.
+// Inputs get the addresses of the test seed in their order and are signed, so the bundle is valid.
+func bundleTrytes(t *testing.T, algorithm string, txs ...transaction.Transaction) []trinary.Trytes {
+	var inputs []testInput
+	for i := range txs {
+		txs[i].CurrentIndex = uint64(i)
+		txs[i].LastIndex = uint64(len(txs) - 1)
+		if txs[i].Value < 0 {
+			input := testInputAt(t, uint64(len(inputs)))
+			txs[i].Address = input.address
+			inputs = append(inputs, input)
+		}
+	}
+	hash, err := computeBundleHash(txs, bundleHashAlgorithms[algorithm])
+	if err != nil {
+		t.Fatalf("unable to compute bundle hash: %v", err)
+	}
+	normalized := signing.NormalizedBundleHash(hash)
+	trytes := make([]trinary.Trytes, len(txs))
+	for i := range txs {
+		txs[i].Bundle = hash
+		if txs[i].Value < 0 {
+			fragment, err := signing.SignatureFragment(normalized[:consts.KeySegmentsPerFragment], inputs[0].key)
+			if err != nil {
+				t.Fatalf("unable to sign input: %v", err)
+			}
+			txs[i].SignatureMessageFragment = trinary.MustTritsToTrytes(fragment)
+			inputs = inputs[1:]
+		}
+		trytes[len(txs)-1-i] = transaction.MustTransactionToTrytes(&txs[i])
+	}
+	return trytes
+}
+
+func attachRequest(t *testing.T, remoteAddr string, mwm int, trytes ...trinary.Trytes) *http.Request {
+	body, err := json.Marshal(&AttachToTangleReq{
+		Command:      attachToTangleCommand,
+		TrunkTxHash:  consts.NullHashTrytes,
+		BranchTxHash: consts.NullHashTrytes,
+		MWM:          mwm,
+		Trytes:       trytes,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.RemoteAddr = remoteAddr
+	return r
+}
+
+func TestServeHTTP(t *testing.T) {
+	cfg := newConfig()
+	cfg.MaxTxInBundle = 2
+	tx := txTrytes(t, "TEST", 0)
+
+	tests := []struct {
+		name      string
+		req       func() *http.Request
+		status    int
+		err       error
+		forwarded bool
+	}{
+		{
+			name:      "non POST requests are forwarded",
+			req:       func() *http.Request { return httptest.NewRequest(http.MethodGet, "/", nil) },
+			status:    http.StatusOK,
+			forwarded: true,
+		},
+		{
+			name: "missing body",
+			req: func() *http.Request {
+				r := httptest.NewRequest(http.MethodPost, "/", nil)
+				r.Body = nil
+				return r
+			},
+			status: http.StatusBadRequest,
+			err:    ErrMissingBody,
+		},
+		{
+			name: "non JSON bodies are forwarded",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/", strings.NewReader("not json"))
+			},
+			status:    http.StatusOK,
+			forwarded: true,
+		},
+		{
+			name: "other commands are forwarded",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"command":"getNodeInfo"}`))
+			},
+			status:    http.StatusOK,
+			forwarded: true,
+		},
+		{
+			name:   "MWM above max",
+			req:    func() *http.Request { return attachRequest(t, "1.1.1.1:1234", cfg.MaxMWM+1, tx) },
+			status: http.StatusBadRequest,
+			err:    ErrInvalidMWM,
+		},
+		{
+			name:   "bundle exceeding the txs limit",
+			req:    func() *http.Request { return attachRequest(t, "1.1.1.1:1234", 1, dataBundleTrytes(t, "TEST", 3)...) },
+			status: http.StatusBadRequest,
+			err:    ErrTxBundleLimitExceeded,
+		},
+		{
+			name:   "valid data bundle",
+			req:    func() *http.Request { return attachRequest(t, "1.1.1.1:1234", 1, tx) },
+			status: http.StatusOK,
+		},
+	}
+
+	_, powFn := pow.GetFastestProofOfWorkImpl()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			interc, next := newTestInterceptor(t, cfg)
+			interc.powFn = powFn
+			w := httptest.NewRecorder()
+			status, err := interc.ServeHTTP(w, test.req())
+			if status != test.status {
+				t.Errorf("expected status %d, got %d", test.status, status)
+			}
+			if errors.Cause(err) != test.err {
+				t.Errorf("expected error %v, got %v", test.err, err)
+			}
+			if forwarded := next.calls == 1; forwarded != test.forwarded {
+				t.Errorf("expected forwarded to be %v", test.forwarded)
+			}
+			if test.forwarded || test.status != http.StatusOK {
+				return
+			}
+			res := &AttachToTangleRes{}
+			if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+				t.Fatalf("invalid response body: %v", err)
+			}
+			if len(res.Trytes) != 1 {
+				t.Fatalf("expected 1 transaction, got %d", len(res.Trytes))
+			}
+			powed, err := transaction.AsTransactionObject(res.Trytes[0])
+			if err != nil {
+				t.Fatalf("invalid transaction trytes returned: %v", err)
+			}
+			if !transaction.HasValidNonce(powed, 1) {
+				t.Error("expected the returned transaction to fulfill the MWM")
+			}
+			if powed.Tag != trinary.Pad("TEST", 27) {
+				t.Errorf("expected the tag to be retained, got %s", powed.Tag)
+			}
+		})
+	}
+}
+
+func TestMinTxPerBundle(t *testing.T) {
+	cfg := newConfig()
+	cfg.MinTxInBundle = 2
+	interc, _ := newTestInterceptor(t, cfg)
+
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+	if status != http.StatusBadRequest || errors.Cause(err) != ErrBundleTooSmall {
+		t.Errorf("expected a single tx bundle to be rejected, got %d: %v", status, err)
+	}
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 0), testTx("TEST", 0))
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Errorf("expected a 2 txs bundle to pass, got %d: %v", status, err)
+	}
+}
+
+func TestMaxValue(t *testing.T) {
+	cfg := newConfig()
+	cfg.MaxValue = 1000000
+	interc, _ := newTestInterceptor(t, cfg)
+	for _, tt := range []struct {
+		value    int64
+		expected int
+	}{
+		{2000000, http.StatusForbidden},
+		{1000000, http.StatusOK},
+		{0, http.StatusOK},
+	} {
+		bundle := bundleTrytes(t, "kerl", testTx("TEST", tt.value), testTx("TEST", -tt.value))
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+		if status != tt.expected {
+			t.Errorf("%di: expected %d, got %d: %v", tt.value, tt.expected, status, err)
+		}
+		if tt.expected == http.StatusForbidden && errors.Cause(err) != ErrValueLimitExceeded {
+			t.Errorf("%di: expected the value limit to be exceeded, got %v", tt.value, err)
+		}
+	}
+}
+
+func TestMaxOutputValue(t *testing.T) {
+	cfg := newConfig()
+	cfg.MaxOutputValue = 1000000
+	interc, _ := newTestInterceptor(t, cfg)
+	for _, tt := range []struct {
+		value    int64
+		expected int
+	}{
+		{1000000, http.StatusOK},
+		{1000001, http.StatusBadRequest},
+		{0, http.StatusOK},
+	} {
+		bundle := bundleTrytes(t, "kerl", testTx("TEST", tt.value), testTx("TEST", -tt.value))
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+		if status != tt.expected {
+			t.Errorf("%di: expected %d, got %d: %v", tt.value, tt.expected, status, err)
+		}
+		if tt.expected == http.StatusBadRequest && errors.Cause(err) != ErrOutputValueTooLarge {
+			t.Errorf("%di: expected the output value to be too large, got %v", tt.value, err)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	cfg := newConfig()
+	cfg.DryRun = true
+	cfg.PoWCacheSize = 10
+	cfg.AdminPath = "/_iotacaddy/status"
+	interc, next := newTestInterceptor(t, cfg)
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 0), testTx("TEST", 0))
+
+	// the second request isn't answered from the PoW cache
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %v", status, err)
+		}
+		res := map[string]interface{}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+		}
+		if _, has := res["trytes"]; has || res["dry_run"] != true || res["tx_count"] != float64(2) {
+			t.Errorf("expected only the dry run timing, got %s", w.Body.String())
+		}
+		if _, has := res["pow_ms"]; !has {
+			t.Errorf("expected the PoW duration, got %s", w.Body.String())
+		}
+	}
+	if next.calls != 0 {
+		t.Errorf("expected nothing to be forwarded, got %d calls", next.calls)
+	}
+
+	admin := &adminStatus{interc: interc, next: interc}
+	w := httptest.NewRecorder()
+	admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, cfg.AdminPath, nil))
+	status := &adminStatusRes{}
+	if err := json.Unmarshal(w.Body.Bytes(), status); err != nil || !status.DryRun {
+		t.Errorf("expected dry_run in the admin status, got %s", w.Body.String())
+	}
+}
+
+func TestTagFiltering(t *testing.T) {
+	spam := trinary.Pad("SPAM", 27)
+	app := trinary.Pad("APP", 27)
+	for _, tt := range []struct {
+		denied, allowed []trinary.Trytes
+		tag             trinary.Trytes
+		expected        int
+	}{
+		{[]trinary.Trytes{spam}, nil, "SPAM", http.StatusForbidden},
+		{[]trinary.Trytes{spam}, nil, "OTHER", http.StatusOK},
+		{nil, []trinary.Trytes{app}, "APP", http.StatusOK},
+		{nil, []trinary.Trytes{app}, "OTHER", http.StatusForbidden},
+		{[]trinary.Trytes{app}, []trinary.Trytes{app}, "APP", http.StatusForbidden},
+	} {
+		cfg := newConfig()
+		cfg.DeniedTags, cfg.AllowedTags = tt.denied, tt.allowed
+		interc, _ := newTestInterceptor(t, cfg)
+		bundle := bundleTrytes(t, "kerl", testTx(tt.tag, 0))
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+		if status != tt.expected {
+			t.Errorf("%s with denied %v and allowed %v: expected %d, got %d: %v", tt.tag, tt.denied, tt.allowed, tt.expected, status, err)
+		}
+		if tt.expected == http.StatusForbidden && errors.Cause(err) != ErrTagDenied {
+			t.Errorf("%s: expected the tag to be denied, got %v", tt.tag, err)
+		}
+	}
+}
+
+func TestIncludeCommandInResponse(t *testing.T) {
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 0))
+	for _, include := range []bool{false, true} {
+		cfg := newConfig()
+		cfg.IncludeCommandInResponse = include
+		interc, _ := newTestInterceptor(t, cfg)
+		w := httptest.NewRecorder()
+		if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %v", status, err)
+		}
+		res := map[string]interface{}{}
+		if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+			t.Fatalf("invalid response body: %v", err)
+		}
+		command, has := res["command"]
+		switch {
+		case include && command != attachToTangleCommand:
+			t.Errorf("expected the command field to be %s, got %v", attachToTangleCommand, command)
+		case !include && has:
+			t.Errorf("expected no command field, got %v", command)
+		}
+	}
+}
+
+func TestResponseChecksum(t *testing.T) {
+	interc, _ := newTestInterceptor(t, newConfig())
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 0), testTx("TEST", 0))
+	w := httptest.NewRecorder()
+	if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", status, err)
+	}
+	res := &AttachToTangleRes{}
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), `"checksum":`) {
+		t.Error("expected the checksum field to be present")
+	}
+	if expected := crc32.ChecksumIEEE([]byte(strings.Join(res.Trytes, ""))); res.Checksum != expected {
+		t.Errorf("expected checksum %d, got %d", expected, res.Checksum)
+	}
+
+	modified := append([]trinary.Trytes(nil), res.Trytes...)
+	modified[0] = "A" + modified[0][1:]
+	if modified[0] == res.Trytes[0] {
+		modified[0] = "B" + modified[0][1:]
+	}
+	if trytesChecksum(modified) == res.Checksum {
+		t.Error("expected modified trytes to have a different checksum")
+	}
+}
+
+func TestPartialBundleRecovery(t *testing.T) {
+	// a 3 tx bundle whose middle transaction got truncated
+	bundle := bundleTrytes(t, defaultBundleHashAlgorithm, testTx("THIRD", 0), testTx("SECOND", 0), testTx("FIRST", 0))
+	bundle[1] = bundle[1][:100]
+
+	interc, _ := newTestInterceptor(t, newConfig())
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusBadRequest || err != ErrBuildingTx {
+		t.Fatalf("expected corrupt bundle to be rejected without recovery, got %d: %v", status, err)
+	}
+
+	// the remaining transactions don't match the bundle hash anymore
+	cfg := newConfig()
+	cfg.PartialBundleRecovery = true
+	interc, _ = newTestInterceptor(t, cfg)
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusUnprocessableEntity || errors.Cause(err) != ErrInvalidBundle {
+		t.Fatalf("expected the recovered bundle to fail the bundle hash validation, got %d: %v", status, err)
+	}
+
+	cfg.ValidateBundleHash = false
+	interc, _ = newTestInterceptor(t, cfg)
+	w := httptest.NewRecorder()
+	status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+	if status != http.StatusOK || err != nil {
+		t.Fatalf("expected 200 with recovery, got %d: %v", status, err)
+	}
+	res := &AttachToTangleRes{}
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatalf("invalid response body: %v", err)
+	}
+	if len(res.SkippedIndices) != 1 || res.SkippedIndices[0] != 1 {
+		t.Errorf("expected skipped indices [1], got %v", res.SkippedIndices)
+	}
+	// DoPoW returns the trytes in reversed order
+	if len(res.Trytes) != 2 || !strings.Contains(string(res.Trytes[1]), "FIRST") || !strings.Contains(string(res.Trytes[0]), "THIRD") {
+		t.Errorf("expected the first and third transaction to be PoWed, got %d trytes", len(res.Trytes))
+	}
+
+	status, err = interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, "CORRUPT", "CORRUPT"))
+	if status != http.StatusBadRequest || err != ErrBuildingTx {
+		t.Errorf("expected bundle without any valid transaction to be rejected, got %d: %v", status, err)
+	}
+}
+
+func TestHeadCapabilities(t *testing.T) {
+	interc, next := newTestInterceptor(t, newConfig())
+	if _, err := interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodHead, "/", nil)); err != nil || next.calls != 1 {
+		t.Fatalf("expected HEAD to be forwarded when disabled, got %v", err)
+	}
+
+	cfg := newConfig()
+	cfg.HeadCapabilities = true
+	interc, next = newTestInterceptor(t, cfg)
+	w := httptest.NewRecorder()
+	status, err := interc.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/", nil))
+	if status != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	if next.calls != 0 {
+		t.Error("expected HEAD not to be forwarded")
+	}
+	expected := map[string]string{
+		contentType:         contentTypeJSON,
+		headerPoWImpl:       "Null",
+		headerMaxMWM:        "14",
+		headerMaxBundleSize: "20",
+		headerQueueDepth:    "0",
+	}
+	for header, value := range expected {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("expected header %s to be %s, got %s", header, value, got)
+		}
+	}
+	if w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("expected CORS header to be set")
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected empty body, got %q", w.Body.String())
+	}
+}
+
+func TestCORS(t *testing.T) {
+	cfg := newConfig()
+	cfg.CORSOrigin = "https://wallet.example.com"
+	interc, next := newTestInterceptor(t, cfg)
+
+	w := httptest.NewRecorder()
+	status, err := interc.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/", nil))
+	if status != http.StatusNoContent || err != nil {
+		t.Fatalf("expected 204, got %d: %v", status, err)
+	}
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected 204 to be written, got %d", w.Code)
+	}
+	if next.calls != 0 {
+		t.Error("expected the preflight not to be forwarded")
+	}
+	expected := map[string]string{
+		headerAllowOrigin:  "https://wallet.example.com",
+		headerAllowMethods: corsAllowedMethods,
+		headerAllowHeaders: corsAllowedHeaders,
+		"Vary":             "Origin",
+	}
+	for header, value := range expected {
+		if got := w.Header().Get(header); got != value {
+			t.Errorf("expected header %s to be %s, got %s", header, value, got)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "", 0))); status != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	if got := w.Header().Get(headerAllowOrigin); got != "https://wallet.example.com" {
+		t.Errorf("expected the configured origin, got %s", got)
+	}
+}
+
+func TestInjectCoordinatorTips(t *testing.T) {
+	trunk := strings.Repeat("A", 81)
+	branch := strings.Repeat("B", 81)
+	cfg := newConfig()
+	cfg.InjectCoordinatorTips = true
+	cfg.CoordinatorTrunk, cfg.CoordinatorBranch = trunk, branch
+	interc, next := newTestInterceptor(t, cfg)
+
+	// attachToTangle calls without trytes are forwarded to IRI
+	r := attachRequest(t, "1.1.1.1:1234", 1)
+	if _, err := interc.ServeHTTP(httptest.NewRecorder(), r); err != nil {
+		t.Fatal(err)
+	}
+	if next.calls != 1 {
+		t.Fatalf("expected request to be forwarded")
+	}
+	forwarded := &AttachToTangleReq{}
+	if err := json.Unmarshal(next.body, forwarded); err != nil {
+		t.Fatalf("invalid forwarded body: %v", err)
+	}
+	if forwarded.TrunkTxHash != trunk || forwarded.BranchTxHash != branch {
+		t.Errorf("expected coordinator tips to be injected, got %s/%s", forwarded.TrunkTxHash, forwarded.BranchTxHash)
+	}
+	if forwarded.Command != attachToTangleCommand || forwarded.MWM != 1 {
+		t.Errorf("expected other fields to be retained, got %+v", forwarded)
+	}
+}
+
+func TestLogHashTruncation(t *testing.T) {
+	var buf bytes.Buffer
+	origLogger := logger
+	logger = log.New(&buf, "", 0)
+	defer func() { logger = origLogger }()
+
+	cfg := newConfig()
+	cfg.LogHashLength = 16
+	interc, _ := newTestInterceptor(t, cfg)
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 0))
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", status, err)
+	}
+
+	tx, err := transaction.AsTransactionObject(bundle[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	null := consts.NullHashTrytes[:16] + "..."
+	expected := "bundle: " + tx.Bundle[:16] + "..., trunk: " + null + ", branch: " + null + "\n"
+	if !strings.Contains(buf.String(), expected) {
+		t.Errorf("expected log line %q, got:\n%s", expected, buf.String())
+	}
+}
+
+func TestLogHashFormat(t *testing.T) {
+	hash := consts.NullHashTrytes
+	if formatted := formatHash(hash, logHashFormatFull); len(formatted) != 81 || formatted != hash {
+		t.Errorf("expected the full hash, got %s", formatted)
+	}
+	if formatted := formatHash(hash, logHashFormatShort); formatted != hash[:8]+"..." {
+		t.Errorf("expected 8 trytes followed by ..., got %s", formatted)
+	}
+
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 0))
+	tx, err := transaction.AsTransactionObject(bundle[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{logHashFormatFull, logHashFormatShort} {
+		var buf bytes.Buffer
+		origLogger := logger
+		logger = log.New(&buf, "", 0)
+		cfg := newConfig()
+		cfg.LogHashFormat = format
+		cfg.RebroadcastInterval = time.Hour
+		interc, _ := newTestInterceptor(t, cfg)
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+		interc.rebroadcaster.close()
+		logger = origLogger
+		if status != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %v", status, err)
+		}
+
+		null := formatHash(consts.NullHashTrytes, format)
+		expected := "bundle: " + formatHash(tx.Bundle, format) + ", trunk: " + null + ", branch: " + null + "\n"
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("%s: expected log line %q, got:\n%s", format, expected, buf.String())
+		}
+		if logged := interc.rebroadcaster.logHash(tx.Bundle); logged != formatHash(tx.Bundle, format) {
+			t.Errorf("%s: expected the rebroadcaster to log hashes in the same format, got %s", format, logged)
+		}
+	}
+}
+
+func TestLogValueUnit(t *testing.T) {
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 1500000000), testTx("TEST", -1500000000))
+	for unit, expected := range map[string]string{
+		"i":  "[input] -1500000000 i",
+		"Ki": "[input] -1500000.000000 Ki",
+		"Mi": "[input] -1500.000000 Mi",
+		"Gi": "[input] -1.500000 Gi",
+		"Ti": "[input] -0.001500 Ti",
+	} {
+		var buf bytes.Buffer
+		origLogger := logger
+		logger = log.New(&buf, "", 0)
+		cfg := newConfig()
+		cfg.LogValueUnit = unit
+		interc, _ := newTestInterceptor(t, cfg)
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+		logger = origLogger
+		if status != http.StatusOK {
+			t.Fatalf("%s: expected status 200, got %d: %v", unit, status, err)
+		}
+		if !strings.Contains(buf.String(), expected) {
+			t.Errorf("%s: expected log line containing %q, got:\n%s", unit, expected, buf.String())
+		}
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	tx := testTx("TEST", 0)
+	tx.Timestamp = uint64(time.Now().Add(time.Hour).Unix())
+	skewed := bundleTrytes(t, "kerl", tx)
+
+	for _, strict := range []bool{false, true} {
+		var buf bytes.Buffer
+		origLogger := logger
+		logger = log.New(&buf, "", 0)
+
+		cfg := newConfig()
+		cfg.StrictMode = strict
+		interc, _ := newTestInterceptor(t, cfg)
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, skewed...))
+		logger = origLogger
+
+		if !strict {
+			if status != http.StatusOK {
+				t.Errorf("expected skewed timestamp to only warn, got %d: %v", status, err)
+			}
+			if !strings.Contains(buf.String(), "warning: transaction at index 0 has a timestamp") {
+				t.Errorf("expected a timestamp warning, got:\n%s", buf.String())
+			}
+			continue
+		}
+		if status != http.StatusBadRequest || errors.Cause(err) != ErrStrictMode {
+			t.Errorf("expected skewed timestamp to be rejected in strict mode, got %d: %v", status, err)
+		}
+	}
+}
+
+func TestRedactMessageFragments(t *testing.T) {
+	const message = "SECRET MESSAGE"
+	fragment, err := converter.ASCIIToTrytes(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := testTx("TEST", 0)
+	tx.SignatureMessageFragment = trinary.Pad(fragment, consts.SignatureMessageFragmentSizeInTrytes)
+	bundle := bundleTrytes(t, "kerl", tx)
+
+	for _, redact := range []bool{false, true} {
+		var buf bytes.Buffer
+		origLogger := logger
+		logger = log.New(&buf, "", 0)
+
+		cfg := newConfig()
+		cfg.RedactMessageFragments = redact
+		interc, _ := newTestInterceptor(t, cfg)
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+		logger = origLogger
+		if status != http.StatusOK {
+			t.Fatalf("expected status 200, got %d: %v", status, err)
+		}
+
+		logged := buf.String()
+		if redact && (!strings.Contains(logged, "[message] "+redacted) || strings.Contains(logged, message)) {
+			t.Errorf("expected the message to be redacted, got:\n%s", logged)
+		}
+		if !redact && !strings.Contains(logged, message) {
+			t.Errorf("expected the message to be logged, got:\n%s", logged)
+		}
+	}
+}
+
+func TestMaxPendingQueueBytes(t *testing.T) {
+	tx := txTrytes(t, "TEST", 0)
+	body, _ := ioutil.ReadAll(attachRequest(t, "1.1.1.1:1234", 1, tx).Body)
+
+	cfg := newConfig()
+	// room for two queued requests
+	cfg.MaxPendingQueueBytes = int64(2*len(body) + len(body)/2)
+	release := make(chan struct{})
+	blockingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		<-release
+		return consts.NullNonceTrytes, nil
+	}
+	interc, err := newInterceptor(cfg, "Blocking", blockingPoW, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interc.Next = &countingNext{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tx)); status != http.StatusOK {
+				t.Errorf("expected queued request to succeed, got %d: %v", status, err)
+			}
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&interc.queueDepth) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("requests didn't queue up")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tx))
+	if status != http.StatusServiceUnavailable || err != ErrQueueMemoryFull {
+		t.Errorf("expected 503 with a full queue, got %d: %v", status, err)
+	}
+	close(release)
+	wg.Wait()
+
+	if atomic.LoadInt64(&interc.pendingBytes) != 0 {
+		t.Errorf("expected no pending bytes after the queue drained, got %d", interc.pendingBytes)
+	}
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tx)); status != http.StatusOK {
+		t.Errorf("expected request to succeed after the queue drained, got %d: %v", status, err)
+	}
+}
+
+func TestStrictJSON(t *testing.T) {
+	req := map[string]interface{}{}
+	if err := json.Unmarshal(readBody(t, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))), &req); err != nil {
+		t.Fatal(err)
+	}
+	req["foo"] = "bar"
+	body, _ := json.Marshal(req)
+
+	for _, strict := range []bool{false, true} {
+		cfg := newConfig()
+		cfg.StrictJSON = strict
+		interc, next := newTestInterceptor(t, cfg)
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+		if !strict {
+			if status != http.StatusOK {
+				t.Errorf("expected unknown fields to be ignored, got %d: %v", status, err)
+			}
+			continue
+		}
+		if status != http.StatusBadRequest || errors.Cause(err) != ErrUnknownJSONField || !strings.Contains(err.Error(), `"foo"`) {
+			t.Errorf("expected unknown field foo to be rejected, got %d: %v", status, err)
+		}
+
+		// other commands have other fields and go to IRI untouched
+		interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"command":"getBalances","addresses":[],"threshold":100}`)))
+		if next.calls != 1 {
+			t.Error("expected other commands to be forwarded in strict mode")
+		}
+	}
+}
+
+func readBody(t *testing.T, r *http.Request) []byte {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
@@ -0,0 +1,62 @@
+package iota
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+type powCacheEntry struct {
+	res         *AttachToTangleRes
+	powImplName string
+	added       time.Time
+}
+
+// powCache keeps the results of the most recent PoWs for the configured TTL, so a client
+// sending the same attachToTangle request again, e.g. when retrying after a network error,
+// doesn't cause another PoW.
+type powCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries *simplelru.LRU
+}
+
+func newPoWCache(size int, ttl time.Duration) *powCache {
+	// only fails for non positive sizes
+	entries, _ := simplelru.NewLRU(size, nil)
+	return &powCache{ttl: ttl, entries: entries}
+}
+
+// powCacheKey returns the SHA-256 of the canonical JSON encoding of the request.
+func powCacheKey(req *AttachToTangleReq) [sha256.Size]byte {
+	// can't fail for a struct of strings and ints
+	canonical, _ := json.Marshal(req)
+	return sha256.Sum256(canonical)
+}
+
+// get returns the cached result and the name of the PoW implementation which computed it
+// or nil if there is none within the TTL.
+func (c *powCache) get(key [sha256.Size]byte) (*AttachToTangleRes, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries.Get(key)
+	if !ok {
+		return nil, ""
+	}
+	entry := v.(powCacheEntry)
+	if time.Since(entry.added) >= c.ttl {
+		c.entries.Remove(key)
+		return nil, ""
+	}
+	return entry.res, entry.powImplName
+}
+
+func (c *powCache) put(key [sha256.Size]byte, res *AttachToTangleRes, powImplName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.Add(key, powCacheEntry{res: res, powImplName: powImplName, added: time.Now()})
+}
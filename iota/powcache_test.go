@@ -0,0 +1,98 @@
+package iota
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+func TestPoWCache(t *testing.T) {
+	var calls int32
+	var fail atomic.Value
+	fail.Store(false)
+	countingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		atomic.AddInt32(&calls, 1)
+		if fail.Load().(bool) {
+			return "", errors.New("PoW failed")
+		}
+		return consts.NullNonceTrytes, nil
+	}
+	cfg := newConfig()
+	cfg.PoWCacheSize = 2
+	cfg.PoWCacheTTL = 100 * time.Millisecond
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.powFn = countingPoW
+
+	attach := func(tag trinary.Trytes) (int, string) {
+		w := httptest.NewRecorder()
+		status, _ := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, tag, 0)))
+		return status, w.Body.String()
+	}
+
+	_, first := attach("A")
+	if status, body := attach("A"); status != http.StatusOK || body != first {
+		t.Errorf("expected the cached response, got %d: %s", status, body)
+	}
+	if calls != 1 {
+		t.Errorf("expected the identical request to be answered from the cache, got %d PoWs", calls)
+	}
+	attach("B")
+	if calls != 2 {
+		t.Errorf("expected other requests not to be answered from the cache, got %d PoWs", calls)
+	}
+
+	// evicts A as the least recently used entry
+	attach("C")
+	attach("A")
+	if calls != 4 {
+		t.Errorf("expected the least recently used entry to be evicted, got %d PoWs", calls)
+	}
+
+	time.Sleep(cfg.PoWCacheTTL)
+	attach("A")
+	if calls != 5 {
+		t.Errorf("expected the cache to expire after the TTL, got %d PoWs", calls)
+	}
+
+	fail.Store(true)
+	for i := 0; i < 2; i++ {
+		if status, _ := attach("D"); status == http.StatusOK {
+			t.Fatal("expected the PoW to fail")
+		}
+	}
+	if calls != 7 {
+		t.Errorf("expected failed PoWs not to be cached, got %d PoWs", calls)
+	}
+}
+
+func TestPoWCacheAfterChecks(t *testing.T) {
+	cfg := newConfig()
+	cfg.PoWCacheSize = 2
+	cfg.PoWCacheTTL = time.Minute
+	cfg.TagRateLimits = map[string]int{"LIMITED": 1}
+	interc, _ := newTestInterceptor(t, cfg)
+
+	limited := txTrytes(t, "LIMITED", 0)
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, limited)); status != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d: %v", status, err)
+	}
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, limited)); status != http.StatusTooManyRequests || err != ErrRateLimited {
+		t.Errorf("expected the tag rate limit to apply to cached bundles, got %d: %v", status, err)
+	}
+
+	// a rule tightened after the result got cached applies as well
+	denied := txTrytes(t, "DENIED", 0)
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, denied)); status != http.StatusOK {
+		t.Fatalf("expected the first request to pass, got %d: %v", status, err)
+	}
+	interc.deniedTags = map[trinary.Trytes]bool{trinary.Pad("DENIED", consts.TagTrinarySize/3): true}
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, denied)); status == http.StatusOK {
+		t.Error("expected the denied tag to be rejected instead of answered from the cache")
+	}
+}
@@ -0,0 +1,34 @@
+package iota
+
+import (
+	"net/http"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/pow"
+	"github.com/pkg/errors"
+)
+
+// PoW errors caused by the transactions sent by the client
+var clientPoWErrors = []error{
+	pow.ErrInvalidTrytesForProofOfWork,
+	consts.ErrInvalidTrytes,
+	consts.ErrInvalidTransactionTrytes,
+	consts.ErrInvalidTransactionHash,
+	consts.ErrInvalidTrunkTransaction,
+	consts.ErrInvalidBranchTransaction,
+	consts.ErrInvalidTrit,
+	consts.ErrInvalidTritsLength,
+}
+
+// ClassifyPoWError returns the HTTP status code for an error returned by the Proof of Work:
+// 400 if the client sent transactions the PoW can't be done for and 500 for all other errors,
+// like unavailable or failing PoW implementations.
+func ClassifyPoWError(err error) int {
+	cause := errors.Cause(err)
+	for _, clientErr := range clientPoWErrors {
+		if cause == clientErr {
+			return http.StatusBadRequest
+		}
+	}
+	return http.StatusInternalServerError
+}
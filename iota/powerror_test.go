@@ -0,0 +1,49 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/pow"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func TestClassifyPoWError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{pow.ErrInvalidTrytesForProofOfWork, http.StatusBadRequest},
+		{consts.ErrInvalidTrytes, http.StatusBadRequest},
+		{consts.ErrInvalidTransactionTrytes, http.StatusBadRequest},
+		{consts.ErrInvalidTransactionHash, http.StatusBadRequest},
+		{consts.ErrInvalidTrunkTransaction, http.StatusBadRequest},
+		{consts.ErrInvalidBranchTransaction, http.StatusBadRequest},
+		{consts.ErrInvalidTrit, http.StatusBadRequest},
+		{consts.ErrInvalidTritsLength, http.StatusBadRequest},
+		{errors.Wrap(pow.ErrInvalidTrytesForProofOfWork, "all PoW implementations failed"), http.StatusBadRequest},
+		{pow.ErrUnknownProofOfWorkFunc, http.StatusInternalServerError},
+		{errors.New("cannot allocate memory"), http.StatusInternalServerError},
+	}
+	for _, test := range tests {
+		if status := ClassifyPoWError(test.err); status != test.status {
+			t.Errorf("expected %d for %v, got %d", test.status, test.err, status)
+		}
+	}
+
+	for _, test := range tests {
+		powErr := test.err
+		cfg := newConfig()
+		interc, _ := newTestInterceptor(t, cfg)
+		interc.powFn = func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+			return "", powErr
+		}
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+		if status != test.status || errors.Cause(err) != ErrExecutingProofOfWork {
+			t.Errorf("expected %d for a failing PoW with %v, got %d: %v", test.status, powErr, status, err)
+		}
+	}
+}
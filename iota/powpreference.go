@@ -0,0 +1,56 @@
+package iota
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/iotaledger/iota.go/pow"
+)
+
+const headerPoWPreference = "X-IOTA-PoW-Preference"
+
+const powPreferenceFastest = "fastest"
+
+// PoW implementations clients can ask for via the X-IOTA-PoW-Preference header.
+// All implementations hash with Curl-P81, curl selects the portable Go implementation.
+// There is no Kerl based PoW implementation, kerl preferences always fall back to the default.
+var powPreferenceImpls = map[string]string{
+	"curl": "SyncGo",
+	"kerl": "",
+}
+
+// newPoWPreferences returns the implementations available for the known preferences.
+func newPoWPreferences() map[string]PoWImpl {
+	prefs := map[string]PoWImpl{}
+	name, fn := pow.GetFastestProofOfWorkImpl()
+	prefs[powPreferenceFastest] = PoWImpl{Name: name, Fn: fn}
+	for pref, implName := range powPreferenceImpls {
+		if implName == "" {
+			continue
+		}
+		if impl, err := lookupPoWImpl(implName); err == nil {
+			prefs[pref] = impl
+		}
+	}
+	return prefs
+}
+
+// powImplFor returns the PoW implementation preferred by the client of the request
+// or the default one if the preference is unknown or unavailable.
+func (interc *Interceptor) powImplFor(r *http.Request) PoWImpl {
+	def := PoWImpl{Name: interc.powImplName, Fn: interc.powFn}
+	pref := strings.ToLower(r.Header.Get(headerPoWPreference))
+	if interc.powPreferences == nil || pref == "" {
+		return def
+	}
+	if _, known := powPreferenceImpls[pref]; !known && pref != powPreferenceFastest {
+		interc.logEntry(levelWarn, "unknown_pow_preference", logFields{RemoteAddr: interc.clientIP(r)}, "ignoring unknown PoW preference '%s', using %s\n", pref, def.Name)
+		return def
+	}
+	impl, ok := interc.powPreferences[pref]
+	if !ok {
+		interc.logEntry(levelWarn, "pow_preference_unavailable", logFields{RemoteAddr: interc.clientIP(r)}, "preferred PoW implementation '%s' is not available, falling back to %s\n", pref, def.Name)
+		return def
+	}
+	return impl
+}
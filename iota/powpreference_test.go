@@ -0,0 +1,72 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPoWPreference(t *testing.T) {
+	tx := txTrytes(t, "TEST", 0)
+	tests := []struct {
+		name       string
+		honor      bool
+		preference string
+		// whether the preferred implementation is available
+		available bool
+		impl      string
+	}{
+		{"preferences ignored when disabled", false, "curl", true, "Null"},
+		{"no preference", true, "", true, "Null"},
+		{"curl", true, "curl", true, "TestCurl"},
+		{"curl unavailable", true, "curl", false, "Null"},
+		{"header is case insensitive", true, "Curl", true, "TestCurl"},
+		{"fastest", true, "fastest", true, "TestFastest"},
+		{"fastest unavailable", true, "fastest", false, "Null"},
+		{"kerl", true, "kerl", true, "TestKerl"},
+		{"kerl unavailable", true, "kerl", false, "Null"},
+		{"unknown preference", true, "gpu", true, "Null"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := newConfig()
+			cfg.HonorPoWPreference = test.honor
+			interc, _ := newTestInterceptor(t, cfg)
+			if test.honor {
+				interc.powPreferences = map[string]PoWImpl{}
+				if test.available {
+					interc.powPreferences = map[string]PoWImpl{
+						"curl":    {Name: "TestCurl", Fn: nullPoW},
+						"kerl":    {Name: "TestKerl", Fn: nullPoW},
+						"fastest": {Name: "TestFastest", Fn: nullPoW},
+					}
+				}
+			}
+
+			r := attachRequest(t, "1.1.1.1:1234", 1, tx)
+			if test.preference != "" {
+				r.Header.Set(headerPoWPreference, test.preference)
+			}
+			w := httptest.NewRecorder()
+			if status, err := interc.ServeHTTP(w, r); status != http.StatusOK {
+				t.Fatalf("expected 200, got %d: %v", status, err)
+			}
+			if got := w.Header().Get(headerPoWImpl); got != test.impl {
+				t.Errorf("expected PoW implementation %s, got %s", test.impl, got)
+			}
+		})
+	}
+}
+
+func TestNewPoWPreferences(t *testing.T) {
+	prefs := newPoWPreferences()
+	if _, ok := prefs[powPreferenceFastest]; !ok {
+		t.Error("expected the fastest implementation to always be available")
+	}
+	if _, ok := prefs["curl"]; !ok {
+		t.Error("expected the Go curl implementation to be available")
+	}
+	if _, ok := prefs["kerl"]; ok {
+		t.Error("expected no kerl implementation to be available")
+	}
+}
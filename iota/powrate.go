@@ -0,0 +1,93 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/iotaledger/iota.go/pow"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+const powDegradedWebhookTimeout = 5 * time.Second
+
+// powMeasurement sums the estimated hashes and the time spent by the PoW of a bundle.
+type powMeasurement struct {
+	hashes   float64
+	duration time.Duration
+}
+
+func (m *powMeasurement) hashesPerSec() float64 {
+	if m.duration <= 0 {
+		return math.Inf(1)
+	}
+	return m.hashes / m.duration.Seconds()
+}
+
+// measurePoW returns a PoW func recording the estimated hashes and duration of each call in m.
+func measurePoW(fn pow.ProofOfWorkFunc, m *powMeasurement) pow.ProofOfWorkFunc {
+	return func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		s := time.Now()
+		nonce, err := fn(trytes, mwm, parallelism...)
+		m.duration += time.Since(s)
+		if err == nil {
+			m.hashes += estimatedHashes(nonce)
+		}
+		return nonce, err
+	}
+}
+
+// estimatedHashes estimates the hashes tried to find the given nonce from its value,
+// as the implementations search the nonce space by incrementing it.
+func estimatedHashes(nonce trinary.Trytes) float64 {
+	trits, err := trinary.TrytesToTrits(nonce)
+	if err != nil {
+		return 1
+	}
+	var value float64
+	for i := len(trits) - 1; i >= 0; i-- {
+		value = value*3 + float64(trits[i])
+	}
+	return math.Abs(value) + 1
+}
+
+type powDegradedAlert struct {
+	HashesPerSec    float64 `json:"hashes_per_sec"`
+	MinHashesPerSec float64 `json:"min_hashes_per_sec"`
+	Txs             int     `json:"txs"`
+	DurationMs      int64   `json:"duration_ms"`
+}
+
+// checkPoWRate warns and alerts the configured webhook if the measured PoW
+// performance is below the configured minimum.
+func (interc *Interceptor) checkPoWRate(m *powMeasurement, txs int) {
+	rate := m.hashesPerSec()
+	if rate >= interc.Config.PoWMinHashesPerSec {
+		return
+	}
+	total := atomic.AddUint64(&interc.powDegraded, 1)
+	powDegradedTotal.Inc()
+	interc.logEntry(levelWarn, "pow_degraded", logFields{TxCount: txs, PoWMs: int64(m.duration / time.Millisecond)}, "PoW performance degraded, did %.0f hashes/s for %d txs, expected at least %.0f (iotacaddy_pow_degraded_total %d)\n",
+		rate, txs, interc.Config.PoWMinHashesPerSec, total)
+	if interc.Config.PoWDegradedWebhook == "" {
+		return
+	}
+	alert, _ := json.Marshal(&powDegradedAlert{
+		HashesPerSec:    rate,
+		MinHashesPerSec: interc.Config.PoWMinHashesPerSec,
+		Txs:             txs,
+		DurationMs:      int64(m.duration / time.Millisecond),
+	})
+	go func() {
+		client := &http.Client{Timeout: powDegradedWebhookTimeout}
+		res, err := client.Post(interc.Config.PoWDegradedWebhook, contentTypeJSON, bytes.NewReader(alert))
+		if err != nil {
+			interc.logEntry(levelError, "pow_degraded_webhook_failed", logFields{}, "unable to call PoW degradation webhook: %v\n", err)
+			return
+		}
+		res.Body.Close()
+	}()
+}
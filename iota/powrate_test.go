@@ -0,0 +1,90 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+func TestEstimatedHashes(t *testing.T) {
+	for _, tt := range []struct {
+		nonce    trinary.Trytes
+		expected float64
+	}{
+		{"999", 1},
+		{"A99", 2},
+		{"9A9", 28},
+		{"Z99", 2},
+	} {
+		if hashes := estimatedHashes(tt.nonce); hashes != tt.expected {
+			t.Errorf("%s: expected %v hashes, got %v", tt.nonce, tt.expected, hashes)
+		}
+	}
+}
+
+func TestPoWMinHashesPerSec(t *testing.T) {
+	// slowPoW takes long to find a low nonce, as a throttled or overloaded machine would
+	slowPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		time.Sleep(50 * time.Millisecond)
+		return trinary.Pad("A", 27), nil
+	}
+
+	alerts := make(chan *powDegradedAlert, 1)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		alert := &powDegradedAlert{}
+		if err := json.NewDecoder(r.Body).Decode(alert); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		alerts <- alert
+	}))
+	defer webhook.Close()
+
+	var buf bytes.Buffer
+	origLogger := logger
+	logger = log.New(&buf, "", 0)
+	defer func() { logger = origLogger }()
+
+	cfg := newConfig()
+	cfg.PoWMinHashesPerSec = 1000
+	cfg.PoWDegradedWebhook = webhook.URL
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.powFn = slowPoW
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 0))
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", status, err)
+	}
+
+	if degraded := atomic.LoadUint64(&interc.powDegraded); degraded != 1 {
+		t.Errorf("expected the degraded counter to be 1, got %d", degraded)
+	}
+	select {
+	case alert := <-alerts:
+		if alert.HashesPerSec >= 1000 || alert.MinHashesPerSec != 1000 || alert.Txs != 1 {
+			t.Errorf("unexpected alert %+v", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the webhook to be called")
+	}
+	if !strings.Contains(buf.String(), "PoW performance degraded") {
+		t.Errorf("expected a warning, got:\n%s", buf.String())
+	}
+
+	// the null PoW finds its nonce instantly
+	cfg = newConfig()
+	cfg.PoWMinHashesPerSec = 1000
+	interc, _ = newTestInterceptor(t, cfg)
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", status, err)
+	}
+	if degraded := atomic.LoadUint64(&interc.powDegraded); degraded != 0 {
+		t.Errorf("expected fast PoWs not to be counted as degraded, got %d", degraded)
+	}
+}
@@ -0,0 +1,68 @@
+package iota
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// durationRing keeps the last PoW durations in milliseconds in a circular buffer.
+type durationRing struct {
+	mu     sync.Mutex
+	values []int64
+	next   int
+	full   bool
+}
+
+func newDurationRing(size int) *durationRing {
+	return &durationRing{values: make([]int64, size)}
+}
+
+// record adds the duration, overwriting the oldest one once the buffer is full.
+func (d *durationRing) record(ms int64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.values[d.next] = ms
+	d.next = (d.next + 1) % len(d.values)
+	if d.next == 0 {
+		d.full = true
+	}
+}
+
+// percentile returns the nearest-rank percentile of the recorded durations, p between 0 and 100,
+// so 0 returns the minimum and 100 the maximum. It returns 0 if nothing was recorded.
+func (d *durationRing) percentile(p float64) int64 {
+	d.mu.Lock()
+	n := d.next
+	if d.full {
+		n = len(d.values)
+	}
+	sorted := append([]int64(nil), d.values[:n]...)
+	d.mu.Unlock()
+	if n == 0 {
+		return 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := int(math.Ceil(p / 100 * float64(n)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > n {
+		rank = n
+	}
+	return sorted[rank-1]
+}
+
+// recordPoWDuration adds the duration to the PoW stats and logs them after every configured amount of PoWs.
+func (interc *Interceptor) recordPoWDuration(ms int64) {
+	if interc.powStats == nil {
+		return
+	}
+	interc.powStats.record(ms)
+	if atomic.AddUint64(&interc.powsRecorded, 1)%uint64(interc.Config.StatsEvery) == 0 {
+		s := interc.powStats
+		interc.logEntry(levelInfo, "pow_stats", logFields{}, "durations of the last %d PoWs: min %dms, p50 %dms, p95 %dms, p99 %dms, max %dms\n",
+			interc.Config.StatsEvery, s.percentile(0), s.percentile(50), s.percentile(95), s.percentile(99), s.percentile(100))
+	}
+}
@@ -0,0 +1,69 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDurationRing(t *testing.T) {
+	ring := newDurationRing(100)
+	if p := ring.percentile(50); p != 0 {
+		t.Errorf("expected 0 without durations, got %d", p)
+	}
+	for ms := int64(100); ms >= 1; ms-- {
+		ring.record(ms)
+	}
+	for p, expected := range map[float64]int64{0: 1, 50: 50, 95: 95, 99: 99, 100: 100} {
+		if got := ring.percentile(p); got != expected {
+			t.Errorf("expected p%v to be %d, got %d", p, expected, got)
+		}
+	}
+
+	// overwrites the oldest durations
+	for i := 0; i < 100; i++ {
+		ring.record(1000)
+	}
+	if min := ring.percentile(0); min != 1000 {
+		t.Errorf("expected only the last 100 durations to count, got a min of %d", min)
+	}
+}
+
+func TestStatsEvery(t *testing.T) {
+	var buf safeBuffer
+	origLogger := logger
+	logger = log.New(&buf, "", 0)
+	defer func() { logger = origLogger }()
+
+	cfg := newConfig()
+	cfg.StatsEvery = 3
+	cfg.AdminPath = "/status"
+	interc, _ := newTestInterceptor(t, cfg)
+	for i := 0; i < 5; i++ {
+		interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+		if logged := strings.Count(buf.String(), "durations of the last 3 PoWs"); logged != (i+1)/3 {
+			t.Fatalf("expected %d stats after %d PoWs, got %d", (i+1)/3, i+1, logged)
+		}
+	}
+	if !strings.Contains(buf.String(), "p99") {
+		t.Errorf("expected the percentiles to be logged, got:\n%s", buf.String())
+	}
+
+	interc.powStats.record(500)
+	w := httptest.NewRecorder()
+	admin := &adminStatus{interc: interc, next: interc}
+	if status, err := admin.ServeHTTP(w, httptest.NewRequest(http.MethodGet, cfg.AdminPath, nil)); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	var res map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if res["pow_p99_ms"] != float64(500) {
+		t.Errorf("expected the p99 in the status, got %s", bytes.TrimSpace(w.Body.Bytes()))
+	}
+}
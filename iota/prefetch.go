@@ -0,0 +1,206 @@
+package iota
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/iota.go/pow"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+const defaultPrefetchTTL = time.Minute
+
+// cronSchedule is a parsed five field cron expression (minute, hour, day of month, month, day of week).
+// Fields support '*', numbers, ranges 'a-b', steps '*/n' or 'a-b/n' and comma separated lists.
+type cronSchedule struct {
+	minute, hour, dom, month, dow map[int]bool
+	// the day fields were restricted, cron matches either of them if both are
+	domRestricted, dowRestricted bool
+}
+
+var cronFieldBounds = [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+func parseCronSchedule(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errors.Errorf("cron schedule '%s' must have 5 fields", expr)
+	}
+	var sets [5]map[int]bool
+	for i, field := range fields {
+		set, err := parseCronField(field, cronFieldBounds[i][0], cronFieldBounds[i][1])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid cron schedule '%s'", expr)
+		}
+		sets[i] = set
+	}
+	return &cronSchedule{
+		minute: sets[0], hour: sets[1], dom: sets[2], month: sets[3], dow: sets[4],
+		domRestricted: fields[2] != "*", dowRestricted: fields[4] != "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (map[int]bool, error) {
+	set := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i != -1 {
+			var err error
+			if step, err = strconv.Atoi(part[i+1:]); err != nil || step <= 0 {
+				return nil, errors.Errorf("invalid step in '%s'", part)
+			}
+			part = part[:i]
+		}
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return nil, errors.Errorf("invalid value '%s'", part)
+			}
+			to = from
+			if len(bounds) == 2 {
+				if to, err = strconv.Atoi(bounds[1]); err != nil {
+					return nil, errors.Errorf("invalid range '%s'", part)
+				}
+			}
+		}
+		if from < min || to > max || from > to {
+			return nil, errors.Errorf("'%s' is out of range %d-%d", part, min, max)
+		}
+		for v := from; v <= to; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+func (s *cronSchedule) matches(t time.Time) bool {
+	if !s.minute[t.Minute()] || !s.hour[t.Hour()] || !s.month[int(t.Month())] {
+		return false
+	}
+	domMatch, dowMatch := s.dom[t.Day()], s.dow[int(t.Weekday())]
+	if s.domRestricted && s.dowRestricted {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// next returns the first time after t matching the schedule or the zero time
+// if the schedule doesn't match within the next 5 years.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	for end := t.AddDate(5, 0, 0); t.Before(end); t = t.Add(time.Minute) {
+		if s.matches(t) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// prefetcher does the PoW of a template request on a schedule and keeps the result
+// for the configured TTL so a matching client request is answered without doing PoW.
+type prefetcher struct {
+	schedule *cronSchedule
+	template *AttachToTangleReq
+	ttl      time.Duration
+	powFn    pow.ProofOfWorkFunc
+	// shared with the interceptor so prefetches don't run beside the requested PoWs
+	scheduler *powScheduler
+	logger    *log.Logger
+	// does the PoW turning panics into errors, see Interceptor.safeDoPoW
+	safeDoPoW func(trunk, branch trinary.Hash, txTrytes []trinary.Trytes, mwm uint64, fn pow.ProofOfWorkFunc) ([]trinary.Trytes, error)
+
+	mu         sync.Mutex
+	powed      []trinary.Trytes
+	computedAt time.Time
+	timer      *time.Timer
+	closed     bool
+}
+
+func newPrefetcher(schedule *cronSchedule, templateFile string, ttl time.Duration, powFn pow.ProofOfWorkFunc, safeDoPoW func(trunk, branch trinary.Hash, txTrytes []trinary.Trytes, mwm uint64, fn pow.ProofOfWorkFunc) ([]trinary.Trytes, error), scheduler *powScheduler, logger *log.Logger) (*prefetcher, error) {
+	contents, err := ioutil.ReadFile(templateFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read prefetch template")
+	}
+	template := &AttachToTangleReq{}
+	if err := json.Unmarshal(contents, template); err != nil {
+		return nil, errors.Wrap(err, "invalid prefetch template")
+	}
+	if len(template.Trytes) == 0 {
+		return nil, errors.New("prefetch template has no trytes")
+	}
+	return &prefetcher{schedule: schedule, template: template, ttl: ttl, powFn: powFn, safeDoPoW: safeDoPoW, scheduler: scheduler, logger: logger}, nil
+}
+
+// start schedules the prefetches until close is called.
+func (p *prefetcher) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	next := p.schedule.next(time.Now())
+	if next.IsZero() {
+		p.logger.Println("prefetch schedule never matches, not prefetching PoW")
+		return
+	}
+	p.timer = time.AfterFunc(time.Until(next), func() {
+		if err := p.run(); err != nil {
+			p.logger.Printf("unable to prefetch PoW: %v\n", err)
+		}
+		p.start()
+	})
+}
+
+// run does the PoW of the template and caches the result.
+func (p *prefetcher) run() error {
+	if err := p.scheduler.acquire(context.Background(), basePoWPriority); err != nil {
+		return err
+	}
+	defer p.scheduler.release()
+	p.logger.Printf("prefetching PoW for template bundle with %d txs\n", len(p.template.Trytes))
+	powed, err := p.safeDoPoW(p.template.TrunkTxHash, p.template.BranchTxHash, p.template.Trytes, uint64(p.template.MWM), p.powFn)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.powed, p.computedAt = powed, time.Now()
+	p.mu.Unlock()
+	return nil
+}
+
+// lookup returns the prefetched PoW if the given request equals the template
+// and the result is not older than the TTL.
+func (p *prefetcher) lookup(trunk, branch trinary.Hash, mwm int, txTrytes []trinary.Trytes) []trinary.Trytes {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.powed == nil || time.Since(p.computedAt) > p.ttl {
+		return nil
+	}
+	if trunk != p.template.TrunkTxHash || branch != p.template.BranchTxHash || mwm != p.template.MWM || len(txTrytes) != len(p.template.Trytes) {
+		return nil
+	}
+	for i := range txTrytes {
+		if txTrytes[i] != p.template.Trytes[i] {
+			return nil
+		}
+	}
+	return p.powed
+}
+
+// close stops the scheduled prefetches.
+func (p *prefetcher) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+}
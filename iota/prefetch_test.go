@@ -0,0 +1,120 @@
+package iota
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+func TestCronSchedule(t *testing.T) {
+	from := time.Date(2019, time.June, 3, 10, 7, 30, 0, time.UTC) // a Monday
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2019, time.June, 3, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2019, time.June, 3, 10, 15, 0, 0, time.UTC)},
+		{"0 9-17/4 * * *", time.Date(2019, time.June, 3, 13, 0, 0, 0, time.UTC)},
+		{"30 2 * * 6,0", time.Date(2019, time.June, 8, 2, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		// either day field matches if both are restricted
+		{"0 0 15 * 2", time.Date(2019, time.June, 4, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		schedule, err := parseCronSchedule(test.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", test.expr, err)
+		}
+		if next := schedule.next(from); !next.Equal(test.next) {
+			t.Errorf("%s: expected next run at %v, got %v", test.expr, test.next, next)
+		}
+	}
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* 24 * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCronSchedule(expr); err == nil {
+			t.Errorf("expected '%s' to be invalid", expr)
+		}
+	}
+}
+
+func TestPrefetchPoW(t *testing.T) {
+	dir, err := ioutil.TempDir("", "prefetch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	template := readBody(t, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEMPLATE", 0)))
+	templateFile := filepath.Join(dir, "prefetch.json")
+	if err := ioutil.WriteFile(templateFile, template, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var powCalls int32
+	countingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		atomic.AddInt32(&powCalls, 1)
+		return nullPoW(trytes, mwm, parallelism...)
+	}
+	cfg := newConfig()
+	cfg.PrefetchSchedule = "* * * * *"
+	cfg.PrefetchTemplate = templateFile
+	cfg.PrefetchTTL = 200 * time.Millisecond
+	interc, err := newInterceptor(cfg, "Counting", countingPoW, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interc.Next = &countingNext{}
+
+	if err := interc.prefetch.run(); err != nil {
+		t.Fatal(err)
+	}
+	if calls := atomic.LoadInt32(&powCalls); calls != 1 {
+		t.Fatalf("expected the template to be PoWed once, got %d PoW calls", calls)
+	}
+
+	attach := func(body []byte) *AttachToTangleRes {
+		w := httptest.NewRecorder()
+		if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, templateTrytes(t, body)...)); status != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %v", status, err)
+		}
+		res := &AttachToTangleRes{}
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	res := attach(template)
+	if calls := atomic.LoadInt32(&powCalls); calls != 1 {
+		t.Errorf("expected the prefetched PoW to be used, got %d PoW calls", calls)
+	}
+	if len(res.Trytes) != 1 || res.Trytes[0] != interc.prefetch.powed[0] {
+		t.Errorf("expected the prefetched trytes, got %v", res.Trytes)
+	}
+
+	attach(readBody(t, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "OTHER", 0))))
+	if calls := atomic.LoadInt32(&powCalls); calls != 2 {
+		t.Errorf("expected PoW for a request not matching the template, got %d PoW calls", calls)
+	}
+
+	time.Sleep(cfg.PrefetchTTL)
+	attach(template)
+	if calls := atomic.LoadInt32(&powCalls); calls != 3 {
+		t.Errorf("expected PoW after the prefetched result expired, got %d PoW calls", calls)
+	}
+}
+
+func templateTrytes(t *testing.T, body []byte) []trinary.Trytes {
+	req := &AttachToTangleReq{}
+	if err := json.Unmarshal(body, req); err != nil {
+		t.Fatal(err)
+	}
+	return req.Trytes
+}
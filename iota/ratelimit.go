@@ -0,0 +1,284 @@
+package iota
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// tokenBucket allows bursts of up to capacity requests and refills continuously
+// at the configured requests per minute.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	// tokens added per second
+	rate float64
+	last time.Time
+}
+
+func newTokenBucket(rpm int) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(rpm),
+		tokens:   float64(rpm),
+		rate:     float64(rpm) / 60,
+		last:     time.Now(),
+	}
+}
+
+// take consumes a token and reports whether one was available.
+func (b *tokenBucket) take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refund gives back a token taken for a request which was rejected later on.
+func (b *tokenBucket) refund() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.tokens++; b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+}
+
+// wait returns how long it takes until the next token is available.
+func (b *tokenBucket) wait() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	tokens := b.tokens + time.Since(b.last).Seconds()*b.rate
+	if tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tokens) / b.rate * float64(time.Second))
+}
+
+// retryAfterSeconds formats the given duration as a Retry-After header value, rounding up.
+func retryAfterSeconds(d time.Duration) string {
+	secs := int64(math.Ceil(d.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatInt(secs, 10)
+}
+
+// ewma is an exponentially weighted moving average.
+type ewma struct {
+	mu    sync.Mutex
+	alpha float64
+	value float64
+	set   bool
+}
+
+func (e *ewma) add(v float64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.set {
+		e.value, e.set = v, true
+		return
+	}
+	e.value = e.alpha*v + (1-e.alpha)*e.value
+}
+
+func (e *ewma) get() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.value
+}
+
+// clampedRetryAfter formats the given duration as a Retry-After header value
+// within 1 and the given maximum seconds.
+func clampedRetryAfter(d time.Duration, max int) string {
+	if secs := int(math.Ceil(d.Seconds())); secs < max {
+		return retryAfterSeconds(d)
+	}
+	return strconv.Itoa(max)
+}
+
+// ipRateLimiter keeps a token bucket per client IP. If a maximum amount of IPs is set,
+// the buckets of the least recently seen IPs are evicted, resetting their limit.
+type ipRateLimiter struct {
+	mu      sync.Mutex
+	rpm     int
+	buckets *simplelru.LRU
+}
+
+func newIPRateLimiter(rpm int, maxIPs int) *ipRateLimiter {
+	if maxIPs <= 0 {
+		maxIPs = math.MaxInt32
+	}
+	// only fails for non positive sizes
+	buckets, _ := simplelru.NewLRU(maxIPs, nil)
+	return &ipRateLimiter{rpm: rpm, buckets: buckets}
+}
+
+func (l *ipRateLimiter) allow(ip string) bool {
+	l.mu.Lock()
+	var bucket *tokenBucket
+	if b, has := l.buckets.Get(ip); has {
+		bucket = b.(*tokenBucket)
+	} else {
+		bucket = newTokenBucket(l.rpm)
+		l.buckets.Add(ip, bucket)
+	}
+	l.mu.Unlock()
+	return bucket.take()
+}
+
+// wait returns how long the IP has to wait for its next request.
+func (l *ipRateLimiter) wait(ip string) time.Duration {
+	l.mu.Lock()
+	bucket, has := l.buckets.Peek(ip)
+	l.mu.Unlock()
+	if !has {
+		return 0
+	}
+	return bucket.(*tokenBucket).wait()
+}
+
+// refund gives back the token the IP's last allowed request took.
+func (l *ipRateLimiter) refund(ip string) {
+	l.mu.Lock()
+	bucket, has := l.buckets.Peek(ip)
+	l.mu.Unlock()
+	if has {
+		bucket.(*tokenBucket).refund()
+	}
+}
+
+// size returns the amount of tracked IPs.
+func (l *ipRateLimiter) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buckets.Len()
+}
+
+// connLimiter caps the amount of simultaneously served requests per IP.
+type connLimiter struct {
+	mu     sync.Mutex
+	max    int
+	active map[string]int
+}
+
+func newConnLimiter(max int) *connLimiter {
+	return &connLimiter{max: max, active: map[string]int{}}
+}
+
+// acquire reports whether the IP may open another connection.
+// Each successful acquire must be followed by a release.
+func (l *connLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[ip] >= l.max {
+		return false
+	}
+	l.active[ip]++
+	return true
+}
+
+func (l *connLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active[ip]--; l.active[ip] <= 0 {
+		delete(l.active, ip)
+	}
+}
+
+// tagRateLimiter keeps a token bucket per configured tag prefix.
+// A tag is accounted to the longest configured prefix it starts with.
+type tagRateLimiter struct {
+	buckets map[string]*tokenBucket
+}
+
+func newTagRateLimiter(limits map[string]int) *tagRateLimiter {
+	l := &tagRateLimiter{buckets: make(map[string]*tokenBucket, len(limits))}
+	for prefix, rpm := range limits {
+		l.buckets[prefix] = newTokenBucket(rpm)
+	}
+	return l
+}
+
+// allow reports whether a bundle with the given tag may be attached.
+// Tags not matching any prefix are not limited.
+func (l *tagRateLimiter) allow(tag string) bool {
+	bucket := l.bucket(tag)
+	return bucket == nil || bucket.take()
+}
+
+// wait returns how long bundles with the given tag have to wait for their next attachment.
+func (l *tagRateLimiter) wait(tag string) time.Duration {
+	if bucket := l.bucket(tag); bucket != nil {
+		return bucket.wait()
+	}
+	return 0
+}
+
+// bucket returns the bucket of the longest prefix the tag starts with or nil if none matches.
+func (l *tagRateLimiter) bucket(tag string) *tokenBucket {
+	var match string
+	for prefix := range l.buckets {
+		if strings.HasPrefix(tag, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return nil
+	}
+	return l.buckets[match]
+}
+
+// intervalLimiter enforces a minimum interval between consecutive requests of the same IP.
+// Entries older than the interval are swept periodically so the map doesn't grow unbounded.
+type intervalLimiter struct {
+	interval  time.Duration
+	last      sync.Map
+	lastSweep int64
+}
+
+func newIntervalLimiter(interval time.Duration) *intervalLimiter {
+	return &intervalLimiter{interval: interval, lastSweep: time.Now().UnixNano()}
+}
+
+// allow reports whether the IP's last request is at least the interval ago and
+// otherwise how long the client has to wait.
+func (l *intervalLimiter) allow(ip string) (bool, time.Duration) {
+	now := time.Now()
+	l.sweep(now)
+	prev, loaded := l.last.LoadOrStore(ip, now)
+	if !loaded {
+		return true, 0
+	}
+	if elapsed := now.Sub(prev.(time.Time)); elapsed < l.interval {
+		return false, l.interval - elapsed
+	}
+	l.last.Store(ip, now)
+	return true, 0
+}
+
+func (l *intervalLimiter) sweep(now time.Time) {
+	last := atomic.LoadInt64(&l.lastSweep)
+	if now.UnixNano()-last < int64(time.Minute) || !atomic.CompareAndSwapInt64(&l.lastSweep, last, now.UnixNano()) {
+		return
+	}
+	l.last.Range(func(ip, t interface{}) bool {
+		if now.Sub(t.(time.Time)) >= l.interval {
+			l.last.Delete(ip)
+		}
+		return true
+	})
+}
@@ -0,0 +1,462 @@
+package iota
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := newTokenBucket(2)
+	if !b.take() || !b.take() {
+		t.Fatal("expected the first two tokens to be available")
+	}
+	if b.take() {
+		t.Fatal("expected the bucket to be exhausted")
+	}
+}
+
+func TestTagRateLimits(t *testing.T) {
+	cfg := newConfig()
+	cfg.TagRateLimits = map[string]int{"TENANTA": 1, "TENANTB": 3}
+	interc, _ := newTestInterceptor(t, cfg)
+
+	tenantA := txTrytes(t, "TENANTAAPP", 0)
+	tenantB := txTrytes(t, "TENANTBAPP", 0)
+	// alternate between both tenants: A is exhausted after one request, B after three
+	expected := []struct {
+		trytes string
+		status int
+	}{
+		{tenantA, http.StatusOK},
+		{tenantB, http.StatusOK},
+		{tenantA, http.StatusTooManyRequests},
+		{tenantB, http.StatusOK},
+		{tenantA, http.StatusTooManyRequests},
+		{tenantB, http.StatusOK},
+		{tenantA, http.StatusTooManyRequests},
+		{tenantB, http.StatusTooManyRequests},
+	}
+	for i, exp := range expected {
+		status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, exp.trytes))
+		if status != exp.status {
+			t.Errorf("request %d: expected status %d, got %d", i, exp.status, status)
+		}
+	}
+
+	// tags without a configured prefix are not limited
+	for i := 0; i < 3; i++ {
+		status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "OTHER", 0)))
+		if status != http.StatusOK {
+			t.Errorf("expected unlimited tag to pass, got %d", status)
+		}
+	}
+}
+
+func TestIPAndTagRateLimits(t *testing.T) {
+	cfg := newConfig()
+	cfg.RateLimit = 1
+	cfg.TagRateLimits = map[string]int{"TENANTA": 5}
+	interc, _ := newTestInterceptor(t, cfg)
+
+	tenantA := txTrytes(t, "TENANTA", 0)
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tenantA)); status != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", status)
+	}
+	// the tag limiter still has tokens but the IP limiter is exhausted
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tenantA)); status != http.StatusTooManyRequests {
+		t.Fatalf("expected IP limit to apply, got %d", status)
+	}
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "2.2.2.2:1234", 1, tenantA)); status != http.StatusOK {
+		t.Fatalf("expected other IP to pass, got %d", status)
+	}
+}
+
+func TestTagRateLimitRefundsIPToken(t *testing.T) {
+	cfg := newConfig()
+	cfg.RateLimit = 1
+	cfg.TagRateLimits = map[string]int{"TENANTA": 1}
+	interc, _ := newTestInterceptor(t, cfg)
+
+	tenantA := txTrytes(t, "TENANTA", 0)
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tenantA)); status != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", status)
+	}
+	// the tag limiter is exhausted while the other IP still has its token
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "2.2.2.2:1234", 1, tenantA)); status != http.StatusTooManyRequests || err != ErrRateLimited {
+		t.Fatalf("expected tag limit to apply, got %d: %v", status, err)
+	}
+	// the tag limited request didn't use up the IP's token
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "2.2.2.2:1234", 1, txTrytes(t, "TENANTB", 0))); status != http.StatusOK {
+		t.Fatalf("expected the IP's token to be refunded, got %d: %v", status, err)
+	}
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "2.2.2.2:1234", 1, txTrytes(t, "TENANTB", 0))); status != http.StatusTooManyRequests {
+		t.Fatalf("expected IP limit to apply afterwards, got %d", status)
+	}
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	cfg := newConfig()
+	cfg.RateLimit = 10
+	cfg.GlobalRateLimit = 4
+	interc, _ := newTestInterceptor(t, cfg)
+
+	type result struct {
+		status     int
+		retryAfter string
+	}
+	results := make(chan result, 6)
+	var wg sync.WaitGroup
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		for i := 0; i < 2; i++ {
+			wg.Add(1)
+			go func(ip string) {
+				defer wg.Done()
+				w := httptest.NewRecorder()
+				status, _ := interc.ServeHTTP(w, attachRequest(t, ip+":1234", 1, txTrytes(t, "TEST", 0)))
+				results <- result{status, w.Header().Get("Retry-After")}
+			}(ip)
+		}
+	}
+	wg.Wait()
+	close(results)
+
+	var ok, unavailable int
+	for res := range results {
+		switch res.status {
+		case http.StatusOK:
+			ok++
+		case http.StatusServiceUnavailable:
+			unavailable++
+			if secs, err := strconv.Atoi(res.retryAfter); err != nil || secs < 1 || secs > 15 {
+				t.Errorf("expected plausible Retry-After header, got %q", res.retryAfter)
+			}
+		default:
+			t.Errorf("unexpected status %d", res.status)
+		}
+	}
+	// no IP reached its own limit of 10 but the global bucket only holds 4 tokens
+	if ok != 4 || unavailable != 2 {
+		t.Errorf("expected 4 accepted and 2 rejected requests, got %d and %d", ok, unavailable)
+	}
+}
+
+func TestMinRequestInterval(t *testing.T) {
+	cfg := newConfig()
+	cfg.MinRequestInterval = 200 * time.Millisecond
+	interc, _ := newTestInterceptor(t, cfg)
+	tx := txTrytes(t, "TEST", 0)
+
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tx)); status != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", status)
+	}
+	w := httptest.NewRecorder()
+	status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, tx))
+	if status != http.StatusTooManyRequests || err != ErrRequestTooSoon {
+		t.Fatalf("expected rapid second request to be rejected, got %d: %v", status, err)
+	}
+	if w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After of 1, got %q", w.Header().Get("Retry-After"))
+	}
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "2.2.2.2:1234", 1, tx)); status != http.StatusOK {
+		t.Errorf("expected other IP to pass, got %d", status)
+	}
+
+	time.Sleep(cfg.MinRequestInterval)
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tx)); status != http.StatusOK {
+		t.Errorf("expected request after the interval to pass, got %d", status)
+	}
+}
+
+func TestRateLimitStatusCode(t *testing.T) {
+	cfg := newConfig()
+	cfg.RateLimit = 1
+	cfg.RateLimitStatusCode = http.StatusServiceUnavailable
+	interc, _ := newTestInterceptor(t, cfg)
+	tx := txTrytes(t, "TEST", 0)
+
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tx)); status != http.StatusOK {
+		t.Fatalf("expected first request to pass, got %d", status)
+	}
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tx)); status != http.StatusServiceUnavailable || err != ErrRateLimited {
+		t.Errorf("expected throttled request to receive 503, got %d: %v", status, err)
+	}
+}
+
+func TestEWMA(t *testing.T) {
+	e := ewma{alpha: 0.5}
+	e.add(100)
+	if e.get() != 100 {
+		t.Fatalf("expected the first sample to be the average, got %f", e.get())
+	}
+	e.add(200)
+	if e.get() != 150 {
+		t.Errorf("expected 150, got %f", e.get())
+	}
+}
+
+func TestQueueFullRetryAfter(t *testing.T) {
+	tests := []struct {
+		name     string
+		depth    int32
+		powMs    []float64
+		min, max int
+	}{
+		{"no PoW done yet", 3, nil, 1, 1},
+		{"queue drains in 6s", 4, []float64{1500, 1500}, 6, 6},
+		{"varying PoW durations", 2, []float64{1000, 3000, 2000}, 3, 5},
+		{"capped at the maximum", 100, []float64{1500}, 30, 30},
+	}
+	for _, test := range tests {
+		cfg := newConfig()
+		// smaller than any request, so every request is rejected
+		cfg.MaxPendingQueueBytes = 1
+		cfg.MaxRetryAfter = 30
+		interc, _ := newTestInterceptor(t, cfg)
+		for _, ms := range test.powMs {
+			interc.powDuration.add(ms)
+		}
+		atomic.StoreInt32(&interc.queueDepth, test.depth)
+
+		w := httptest.NewRecorder()
+		if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusServiceUnavailable {
+			t.Fatalf("%s: expected 503, got %d: %v", test.name, status, err)
+		}
+		secs, err := strconv.Atoi(w.Header().Get("Retry-After"))
+		if err != nil || secs < test.min || secs > test.max {
+			t.Errorf("%s: expected Retry-After within [%d, %d], got %q", test.name, test.min, test.max, w.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestMaxConnectionsPerIP(t *testing.T) {
+	cfg := newConfig()
+	cfg.MaxConnectionsPerIP = 2
+	release := make(chan struct{})
+	blockingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		<-release
+		return consts.NullNonceTrytes, nil
+	}
+	interc, err := newInterceptor(cfg, "Blocking", blockingPoW, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interc.Next = &countingNext{}
+
+	const clients = 5
+	statuses := make(chan int, clients)
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+			statuses <- status
+		}()
+	}
+	// all but the allowed connections are rejected while the others wait for their PoW
+	for i := 0; i < clients-cfg.MaxConnectionsPerIP; i++ {
+		select {
+		case status := <-statuses:
+			if status != http.StatusTooManyRequests {
+				t.Fatalf("expected 429 for exceeding connections, got %d", status)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("expected exceeding connections to be rejected")
+		}
+	}
+	if !interc.ipConns.acquire("2.2.2.2") {
+		t.Error("expected other IPs not to be limited")
+	}
+	interc.ipConns.release("2.2.2.2")
+	close(release)
+	wg.Wait()
+	close(statuses)
+	for status := range statuses {
+		if status != http.StatusOK {
+			t.Errorf("expected the allowed connections to be processed, got %d", status)
+		}
+	}
+	if len(interc.ipConns.active) != 0 {
+		t.Errorf("expected all connections to be released, got %v", interc.ipConns.active)
+	}
+}
+
+func TestProxyDepth(t *testing.T) {
+	chains := map[int]string{
+		1: "10.0.0.1",
+		2: "10.0.0.1, 10.0.0.2",
+		3: "10.0.0.1, 10.0.0.2, 10.0.0.3",
+	}
+	for ips, chain := range chains {
+		for depth := 1; depth <= 3; depth++ {
+			// behind more proxies than forwarded IPs, the leftmost one is used
+			expected := strings.Split(chain, ", ")[0]
+			if depth <= ips {
+				expected = strings.Split(chain, ", ")[ips-depth]
+			}
+
+			var buf bytes.Buffer
+			origLogger := logger
+			logger = log.New(&buf, "", 0)
+			cfg := newConfig()
+			cfg.ProxyDepth = depth
+			cfg.RateLimit = 1
+			interc, _ := newTestInterceptor(t, cfg)
+			req := attachRequest(t, "192.168.0.1:1234", 1, txTrytes(t, "TEST", 0))
+			req.Header.Set("X-Forwarded-For", chain)
+			status, err := interc.ServeHTTP(httptest.NewRecorder(), req)
+			logger = origLogger
+			if status != http.StatusOK {
+				t.Fatalf("%d IPs, depth %d: expected 200, got %d: %v", ips, depth, status, err)
+			}
+			if !strings.Contains(buf.String(), "request from "+expected+"\n") {
+				t.Errorf("%d IPs, depth %d: expected %s to be logged, got:\n%s", ips, depth, expected, buf.String())
+			}
+			if interc.ipLimiter.allow(expected) {
+				t.Errorf("%d IPs, depth %d: expected the request to be accounted to %s", ips, depth, expected)
+			}
+		}
+	}
+
+	interc, _ := newTestInterceptor(t, newConfig())
+	req := attachRequest(t, "192.168.0.1:1234", 1)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+	if ip := interc.clientIP(req); ip != "192.168.0.1" {
+		t.Errorf("expected X-Forwarded-For to be ignored without a proxy depth, got %s", ip)
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	var trusted []*net.IPNet
+	for _, cidr := range []string{"10.0.0.0/8", "fd00::/8"} {
+		_, network, _ := net.ParseCIDR(cidr)
+		trusted = append(trusted, network)
+	}
+	for _, tt := range []struct {
+		name                 string
+		remoteAddr           string
+		forwardedFor, realIP string
+		expected             string
+	}{
+		{"untrusted peer", "1.1.1.1:1234", "2.2.2.2", "3.3.3.3", "1.1.1.1"},
+		{"trusted peer without headers", "10.0.0.1:1234", "", "", "10.0.0.1"},
+		{"forwarded for", "10.0.0.1:1234", "2.2.2.2", "3.3.3.3", "2.2.2.2"},
+		{"spoofed forwarded for", "10.0.0.1:1234", "6.6.6.6, 2.2.2.2", "", "2.2.2.2"},
+		{"chain of trusted proxies", "10.0.0.1:1234", "2.2.2.2, 10.0.0.3, 10.0.0.2", "", "2.2.2.2"},
+		{"only trusted proxies", "10.0.0.1:1234", "10.0.0.3, 10.0.0.2", "", "10.0.0.3"},
+		{"real IP", "10.0.0.1:1234", "", "3.3.3.3", "3.3.3.3"},
+		{"invalid real IP", "10.0.0.1:1234", "", "nonsense", "10.0.0.1"},
+		{"IPv6 proxy", "[fd00::1]:1234", "2001:db8::1", "", "2001:db8::1"},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if tt.forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		}
+		if tt.realIP != "" {
+			r.Header.Set("X-Real-IP", tt.realIP)
+		}
+		if ip := realIP(r, trusted); ip != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.expected, ip)
+		}
+	}
+
+	// rate limits and access rules apply to the forwarded IP
+	cfg := newConfig()
+	cfg.TrustedProxies = trusted[:1]
+	cfg.RateLimit = 1
+	rule, err := parseAccessRule(false, "6.6.6.6")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.AccessRules = []accessRule{rule}
+	interc, _ := newTestInterceptor(t, cfg)
+	for _, tt := range []struct {
+		forwardedFor string
+		expected     int
+	}{
+		{"2.2.2.2", http.StatusOK},
+		{"2.2.2.2", http.StatusTooManyRequests},
+		{"3.3.3.3", http.StatusOK},
+		{"6.6.6.6", http.StatusForbidden},
+	} {
+		req := attachRequest(t, "10.0.0.1:1234", 1, txTrytes(t, "TEST", 0))
+		req.Header.Set("X-Forwarded-For", tt.forwardedFor)
+		if status, err := interc.ServeHTTP(httptest.NewRecorder(), req); status != tt.expected {
+			t.Errorf("%s: expected %d, got %d: %v", tt.forwardedFor, tt.expected, status, err)
+		}
+	}
+}
+
+func TestBackpressureHeaders(t *testing.T) {
+	tx := txTrytes(t, "TEST", 0)
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusServiceUnavailable} {
+		cfg := newConfig()
+		cfg.RateLimit = 1
+		cfg.RateLimitStatusCode = status
+		cfg.MaxRetryAfter = 30
+		interc, _ := newTestInterceptor(t, cfg)
+		// queue drains in 90s, beyond the maximum Retry-After
+		interc.powDuration.add(30000)
+		atomic.StoreInt32(&interc.queueDepth, 3)
+
+		if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, tx)); status != http.StatusOK {
+			t.Fatalf("expected first request to pass, got %d", status)
+		}
+		w := httptest.NewRecorder()
+		before := time.Now()
+		if got, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, tx)); got != status {
+			t.Fatalf("expected %d, got %d: %v", status, got, err)
+		}
+
+		switch status {
+		case http.StatusTooManyRequests:
+			// a token of the 1 rpm bucket refills within a minute
+			reset, err := strconv.ParseInt(w.Header().Get(headerRateLimitReset), 10, 64)
+			if err != nil || reset < before.Unix()+50 || reset > before.Unix()+61 {
+				t.Errorf("expected X-RateLimit-Reset within a minute, got %q", w.Header().Get(headerRateLimitReset))
+			}
+			if w.Header().Get("Retry-After") != "" {
+				t.Errorf("expected no Retry-After on 429, got %q", w.Header().Get("Retry-After"))
+			}
+		case http.StatusServiceUnavailable:
+			if w.Header().Get("Retry-After") != "30" {
+				t.Errorf("expected Retry-After capped at 30, got %q", w.Header().Get("Retry-After"))
+			}
+			if w.Header().Get(headerRateLimitReset) != "" {
+				t.Errorf("expected no X-RateLimit-Reset on 503, got %q", w.Header().Get(headerRateLimitReset))
+			}
+		}
+	}
+}
+
+func TestRateLimiterMaxIPs(t *testing.T) {
+	l := newIPRateLimiter(1, 3)
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3", "4.4.4.4"} {
+		if !l.allow(ip) {
+			t.Fatalf("expected the first request of %s to be allowed", ip)
+		}
+		if size := l.size(); size > 3 {
+			t.Fatalf("expected at most 3 tracked IPs, got %d", size)
+		}
+	}
+	if l.allow("4.4.4.4") {
+		t.Error("expected the limit of a tracked IP to hold")
+	}
+	// the oldest IP was evicted and starts over with a full bucket
+	if !l.allow("1.1.1.1") {
+		t.Error("expected the counter of the evicted IP to be reset")
+	}
+}
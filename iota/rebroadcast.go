@@ -0,0 +1,149 @@
+package iota
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+	"github.com/pkg/errors"
+)
+
+const defaultRebroadcastMaxAttempts = 3
+
+const (
+	getInclusionStatesCommand    = "getInclusionStates"
+	broadcastTransactionsCommand = "broadcastTransactions"
+)
+
+type getInclusionStatesReq struct {
+	Command      string         `json:"command"`
+	Transactions []trinary.Hash `json:"transactions"`
+}
+
+type getInclusionStatesRes struct {
+	States []bool `json:"states"`
+}
+
+type broadcastTransactionsReq struct {
+	Command string           `json:"command"`
+	Trytes  []trinary.Trytes `json:"trytes"`
+}
+
+// pendingBundle is a PoWed bundle waiting for its tail transaction to be confirmed.
+type pendingBundle struct {
+	next httpserver.Handler
+	// template for the requests to IRI, holding the client request's URL and headers
+	req      *http.Request
+	tail     trinary.Hash
+	trytes   []trinary.Trytes
+	attempts int
+	timer    *time.Timer
+}
+
+// rebroadcaster broadcasts PoWed bundles again as long as they aren't confirmed
+// after the configured interval, up to the maximum amount of attempts.
+type rebroadcaster struct {
+	interval    time.Duration
+	maxAttempts int
+	logHash     func(trinary.Hash) string
+	logger      *log.Logger
+
+	mu      sync.Mutex
+	pending map[trinary.Hash]*pendingBundle
+	closed  bool
+}
+
+func newRebroadcaster(interval time.Duration, maxAttempts int, logHash func(trinary.Hash) string, logger *log.Logger) *rebroadcaster {
+	return &rebroadcaster{interval: interval, maxAttempts: maxAttempts, logHash: logHash, logger: logger, pending: map[trinary.Hash]*pendingBundle{}}
+}
+
+// schedule starts watching the confirmation of the given PoWed bundle.
+func (rb *rebroadcaster) schedule(next httpserver.Handler, r *http.Request, powedBundle []trinary.Trytes) error {
+	var tail trinary.Hash
+	for _, trytes := range powedBundle {
+		tx, err := transaction.AsTransactionObject(trytes)
+		if err != nil {
+			return err
+		}
+		if tx.CurrentIndex == 0 {
+			tail = tx.Hash
+		}
+	}
+	if tail == "" {
+		return errors.New("bundle has no tail transaction")
+	}
+
+	u := *r.URL
+	req := &http.Request{Method: http.MethodPost, URL: &u, Header: http.Header{}}
+	for k, values := range r.Header {
+		req.Header[k] = append([]string(nil), values...)
+	}
+	b := &pendingBundle{next: next, req: req, tail: tail, trytes: powedBundle}
+
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if rb.closed {
+		return nil
+	}
+	if _, has := rb.pending[tail]; has {
+		return nil
+	}
+	rb.pending[tail] = b
+	b.timer = time.AfterFunc(rb.interval, func() { rb.check(b) })
+	return nil
+}
+
+// check rebroadcasts the bundle if it isn't confirmed yet and schedules the next check.
+func (rb *rebroadcaster) check(b *pendingBundle) {
+	ctx := context.Background()
+	states := &getInclusionStatesRes{}
+	err := callIRI(ctx, b.next, b.req, &getInclusionStatesReq{Command: getInclusionStatesCommand, Transactions: []trinary.Hash{b.tail}}, states)
+	switch {
+	case err != nil:
+		b.attempts++
+		rb.logger.Printf("unable to check inclusion state of %s: %v\n", rb.logHash(b.tail), err)
+	case len(states.States) == 1 && states.States[0]:
+		rb.logger.Printf("transaction %s is confirmed, stopping rebroadcasts\n", rb.logHash(b.tail))
+		rb.remove(b)
+		return
+	default:
+		b.attempts++
+		rb.logger.Printf("rebroadcasting unconfirmed bundle with tail %s (attempt %d/%d)\n", rb.logHash(b.tail), b.attempts, rb.maxAttempts)
+		if err := callIRI(ctx, b.next, b.req, &broadcastTransactionsReq{Command: broadcastTransactionsCommand, Trytes: b.trytes}, &struct{}{}); err != nil {
+			rb.logger.Printf("unable to rebroadcast bundle with tail %s: %v\n", rb.logHash(b.tail), err)
+		}
+	}
+
+	if b.attempts >= rb.maxAttempts {
+		rb.logger.Printf("giving up rebroadcasting bundle with tail %s\n", rb.logHash(b.tail))
+		rb.remove(b)
+		return
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if !rb.closed {
+		b.timer.Reset(rb.interval)
+	}
+}
+
+func (rb *rebroadcaster) remove(b *pendingBundle) {
+	rb.mu.Lock()
+	delete(rb.pending, b.tail)
+	rb.mu.Unlock()
+}
+
+// close stops all pending rebroadcasts.
+func (rb *rebroadcaster) close() {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	rb.closed = true
+	for tail, b := range rb.pending {
+		b.timer.Stop()
+		delete(rb.pending, tail)
+	}
+}
@@ -0,0 +1,42 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+func TestAutoRebroadcast(t *testing.T) {
+	cfg := newConfig()
+	cfg.RebroadcastInterval = 20 * time.Millisecond
+	cfg.RebroadcastMaxAttempts = 2
+	interc, _ := newTestInterceptor(t, cfg)
+	iri := &mockIRI{txs: map[trinary.Hash]trinary.Trytes{}}
+	interc.Next = iri
+	defer interc.rebroadcaster.close()
+
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 0))
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", status, err)
+	}
+
+	broadcasts := func() int {
+		iri.mu.Lock()
+		defer iri.mu.Unlock()
+		return iri.broadcasts
+	}
+	if broadcasts() != 0 {
+		t.Fatal("expected no broadcast before the interval passed")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if n := broadcasts(); n != 1 {
+		t.Fatalf("expected the unconfirmed bundle to be rebroadcast once after the interval, got %d", n)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if n := broadcasts(); n != cfg.RebroadcastMaxAttempts {
+		t.Errorf("expected rebroadcasts to stop after %d attempts, got %d", cfg.RebroadcastMaxAttempts, n)
+	}
+}
@@ -0,0 +1,18 @@
+package iota
+
+import (
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverPanic turns a panic while serving the request into a 500 and logs it with the
+// goroutine's stack. It has to be deferred directly to be able to recover.
+func (interc *Interceptor) recoverPanic(r *http.Request, status *int, err *error) {
+	rec := recover()
+	if rec == nil {
+		return
+	}
+	ip := interc.clientIP(r)
+	interc.logEntry(levelError, "panic", logFields{RemoteAddr: ip}, "recovered panic while serving %s %s from %s: %v\n%s", r.Method, r.URL.Path, ip, rec, debug.Stack())
+	*status, *err = http.StatusInternalServerError, ErrInternal
+}
@@ -0,0 +1,53 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type panickingNext struct{}
+
+func (panickingNext) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
+	panic("broken handler")
+}
+
+func TestRecoverPanics(t *testing.T) {
+	var buf bytes.Buffer
+	origLogger := logger
+	logger = log.New(&buf, "", 0)
+	defer func() { logger = origLogger }()
+
+	cfg := newConfig()
+	cfg.RecoverPanics = true
+	cfg.LogFormat = logFormatJSON
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.Next = panickingNext{}
+
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if status != http.StatusInternalServerError || err != ErrInternal {
+		t.Fatalf("expected the panic to be answered with 500, got %d: %v", status, err)
+	}
+
+	entry := &jsonLogEntry{}
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), entry); err != nil {
+		t.Fatalf("expected the panic to be logged as JSON, got %q: %v", buf.String(), err)
+	}
+	if entry.Event != "panic" || entry.Level != levelError || !strings.Contains(entry.Message, "broken handler") ||
+		!strings.Contains(entry.Message, http.MethodGet) || !strings.Contains(entry.Message, "goroutine") {
+		t.Errorf("expected the panic and its stack to be logged, got %+v", entry)
+	}
+
+	// disabled panics go through to Caddy
+	interc.Config.RecoverPanics = false
+	defer func() {
+		if recover() == nil {
+			t.Error("expected the panic not to be recovered")
+		}
+	}()
+	interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
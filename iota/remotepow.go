@@ -0,0 +1,90 @@
+package iota
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+const defaultRemotePoWTimeout = 30 * time.Second
+
+// name of the PoW implementation reported for bundles PoWed by the remote PoW service
+const remotePoWImplName = "remote"
+
+// remotePoW sends attachToTangle requests to a remote PoW service.
+type remotePoW struct {
+	url    string
+	client *http.Client
+}
+
+func newRemotePoW(serviceURL string, timeout time.Duration) (*remotePoW, error) {
+	u, err := url.Parse(serviceURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid remote PoW URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("remote PoW URL must be an http or https URL, got %s", serviceURL)
+	}
+	return &remotePoW{url: serviceURL, client: &http.Client{Timeout: timeout}}, nil
+}
+
+// do posts the attachToTangle request to the remote PoW service and returns the PoWed trytes.
+// The response is rejected unless it holds the given transactions with nonces fulfilling the MWM.
+func (rp *remotePoW) do(ctx context.Context, req *AttachToTangleReq) ([]trinary.Trytes, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, rp.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set(contentType, contentTypeJSON)
+	httpRes, err := rp.client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer httpRes.Body.Close()
+	if httpRes.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("remote PoW service returned status %d", httpRes.StatusCode)
+	}
+	res := &AttachToTangleRes{}
+	if err := json.NewDecoder(httpRes.Body).Decode(res); err != nil {
+		return nil, errors.Wrap(err, "invalid remote PoW response")
+	}
+
+	if len(res.Trytes) != len(req.Trytes) {
+		return nil, errors.Errorf("remote PoW service returned %d transactions for %d", len(res.Trytes), len(req.Trytes))
+	}
+	sent := make(map[trinary.Hash]map[uint64]bool, 1)
+	for _, trytes := range req.Trytes {
+		tx, err := transaction.AsTransactionObject(trytes)
+		if err != nil {
+			return nil, err
+		}
+		if sent[tx.Bundle] == nil {
+			sent[tx.Bundle] = map[uint64]bool{}
+		}
+		sent[tx.Bundle][tx.CurrentIndex] = true
+	}
+	for _, trytes := range res.Trytes {
+		tx, err := transaction.AsTransactionObject(trytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid transaction trytes in remote PoW response")
+		}
+		if !sent[tx.Bundle][tx.CurrentIndex] {
+			return nil, errors.Errorf("remote PoW service returned transaction %s which wasn't sent", tx.Hash)
+		}
+		if !transaction.HasValidNonce(tx, uint64(req.MWM)) {
+			return nil, errors.Errorf("remote PoW service returned transaction %s without a valid nonce", tx.Hash)
+		}
+	}
+	return res.Trytes, nil
+}
@@ -0,0 +1,95 @@
+package iota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/pow"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+func TestRemotePoW(t *testing.T) {
+	_, realPoW := pow.GetFastestProofOfWorkImpl()
+	var remoteCalls int32
+	var mode atomic.Value
+	mode.Store("ok")
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&remoteCalls, 1)
+		req := &AttachToTangleReq{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil || req.Command != attachToTangleCommand {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch mode.Load().(string) {
+		case "error":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "slow":
+			time.Sleep(200 * time.Millisecond)
+		case "unpowed":
+			json.NewEncoder(w).Encode(&AttachToTangleRes{Trytes: req.Trytes})
+			return
+		}
+		powed, err := pow.DoPoW(req.TrunkTxHash, req.BranchTxHash, req.Trytes, uint64(req.MWM), realPoW)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(&AttachToTangleRes{Trytes: powed})
+	}))
+	defer remote.Close()
+
+	var localCalls int32
+	cfg := newConfig()
+	cfg.RemotePoWURL = remote.URL
+	cfg.RemotePoWTimeout = 100 * time.Millisecond
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.powFn = func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		atomic.AddInt32(&localCalls, 1)
+		return nullPoW(trytes, mwm, parallelism...)
+	}
+
+	tx := txTrytes(t, "TEST", 0)
+	// high enough for the unpowed trytes not to fulfill it by chance
+	const mwm = 9
+	for _, c := range []struct {
+		mode       string
+		impl       string
+		localCalls int32
+	}{
+		{"ok", remotePoWImplName, 0},
+		{"error", "Null", 1},
+		{"slow", "Null", 2},
+		{"unpowed", "Null", 3},
+	} {
+		mode.Store(c.mode)
+		before := atomic.LoadInt32(&remoteCalls)
+		w := httptest.NewRecorder()
+		if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", mwm, tx)); status != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %v", c.mode, status, err)
+		}
+		if atomic.LoadInt32(&remoteCalls) != before+1 {
+			t.Errorf("%s: expected the remote PoW service to be called", c.mode)
+		}
+		if impl := w.Header().Get(headerPoWImpl); impl != c.impl {
+			t.Errorf("%s: expected the PoW to be done by %s, got %s", c.mode, c.impl, impl)
+		}
+		if calls := atomic.LoadInt32(&localCalls); calls != c.localCalls {
+			t.Errorf("%s: expected %d local PoWs, got %d", c.mode, c.localCalls, calls)
+		}
+	}
+
+	// the checks before the PoW apply to both
+	mode.Store("ok")
+	before := atomic.LoadInt32(&remoteCalls)
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", cfg.MaxMWM+1, tx)); status != http.StatusBadRequest {
+		t.Errorf("expected an MWM above the maximum to be rejected, got %d", status)
+	}
+	if atomic.LoadInt32(&remoteCalls) != before {
+		t.Error("expected rejected requests not to be sent to the remote PoW service")
+	}
+}
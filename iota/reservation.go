@@ -0,0 +1,48 @@
+package iota
+
+import (
+	"sync"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+// reservationMap locks the input addresses of value bundles while their PoW is pending,
+// so a second bundle can't spend the same funds in the meantime.
+type reservationMap struct {
+	mu       sync.Mutex
+	reserved map[trinary.Hash]bool
+}
+
+func newReservationMap() *reservationMap {
+	return &reservationMap{reserved: map[trinary.Hash]bool{}}
+}
+
+// reserve locks the addresses of the given transactions' inputs. If one of them is already
+// locked, none are reserved and the locked address is returned.
+func (m *reservationMap) reserve(txs []transaction.Transaction) (trinary.Hash, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range txs {
+		if txs[i].Value < 0 && m.reserved[txs[i].Address] {
+			return txs[i].Address, false
+		}
+	}
+	for i := range txs {
+		if txs[i].Value < 0 {
+			m.reserved[txs[i].Address] = true
+		}
+	}
+	return "", true
+}
+
+// release unlocks the input addresses of transactions reserved before.
+func (m *reservationMap) release(txs []transaction.Transaction) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range txs {
+		if txs[i].Value < 0 {
+			delete(m.reserved, txs[i].Address)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func TestReserveInputAddresses(t *testing.T) {
+	cfg := newConfig()
+	cfg.ReserveInputAddresses = true
+	release := make(chan struct{})
+	var started int32
+	blockingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		atomic.AddInt32(&started, 1)
+		<-release
+		return consts.NullNonceTrytes, nil
+	}
+	interc, err := newInterceptor(cfg, "Blocking", blockingPoW, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interc.Next = &countingNext{}
+
+	spending := func(input trinary.Hash) []trinary.Trytes {
+		in, out := testTx("TEST", -1000), testTx("TEST", 1000)
+		in.Address = trinary.Pad(input, consts.HashTrytesSize)
+		return bundleTrytes(t, "kerl", out, in)
+	}
+	done := make(chan int)
+	go func() {
+		status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, spending("INPUT")...))
+		done <- status
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&started) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("PoW of the first bundle didn't start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, spending("INPUT")...))
+	if status != http.StatusConflict || errors.Cause(err) != ErrAddressLocked {
+		t.Errorf("expected the second bundle spending the same address to be rejected, got %d: %v", status, err)
+	}
+
+	close(release)
+	if status := <-done; status != http.StatusOK {
+		t.Fatalf("expected the first bundle to be PoWed, got %d", status)
+	}
+	// released after the PoW
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, spending("INPUT")...)); status != http.StatusOK {
+		t.Errorf("expected the address to be released after the PoW, got %d: %v", status, err)
+	}
+	if len(interc.reservations.reserved) != 0 {
+		t.Errorf("expected no reservations left, got %v", interc.reservations.reserved)
+	}
+}
+
+func TestReservationMap(t *testing.T) {
+	m := newReservationMap()
+	a, b := testTx("TEST", -1), testTx("TEST", -1)
+	a.Address, b.Address = trinary.Pad("A", consts.HashTrytesSize), trinary.Pad("B", consts.HashTrytesSize)
+	output := testTx("TEST", 2)
+	output.Address = b.Address
+
+	if _, ok := m.reserve([]transaction.Transaction{a, output}); !ok {
+		t.Fatal("expected the first reservation to succeed")
+	}
+	// outputs aren't reserved
+	if _, ok := m.reserve([]transaction.Transaction{b}); !ok {
+		t.Fatal("expected an address only used as output to be reservable")
+	}
+	m.release([]transaction.Transaction{b})
+	if addr, ok := m.reserve([]transaction.Transaction{b, a}); ok || addr != a.Address {
+		t.Errorf("expected %s to be locked, got %s", a.Address, addr)
+	}
+	// failed reservations don't lock any address
+	if _, ok := m.reserve([]transaction.Transaction{b}); !ok {
+		t.Error("expected the failed reservation not to lock other addresses")
+	}
+	m.release([]transaction.Transaction{a, b})
+	if len(m.reserved) != 0 {
+		t.Errorf("expected all addresses to be released, got %v", m.reserved)
+	}
+}
@@ -0,0 +1,106 @@
+package iota
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+const (
+	defaultResultBackupTTL = 24 * time.Hour
+	resultBackupExt        = ".json"
+	resultBackupGzipExt    = ".json.gz"
+)
+
+// resultBackup stores PoW results in a directory, named after their bundle hash, so
+// results which couldn't be sent to the client can be recovered manually. Files older
+// than the TTL are removed. If compression is enabled, the files are gzipped.
+type resultBackup struct {
+	mu       sync.Mutex
+	dir      string
+	ttl      time.Duration
+	compress bool
+}
+
+func newResultBackup(dir string, ttl time.Duration, compress bool) (*resultBackup, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &resultBackup{dir: dir, ttl: ttl, compress: compress}, nil
+}
+
+// store writes the result of the bundle with the given hash and removes expired backups.
+func (rb *resultBackup) store(bundleHash trinary.Hash, res *AttachToTangleRes) error {
+	resBytes, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	name := bundleHash + resultBackupExt
+	if rb.compress {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(resBytes); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		resBytes, name = buf.Bytes(), bundleHash+resultBackupGzipExt
+	}
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	if err := ioutil.WriteFile(filepath.Join(rb.dir, name), resBytes, 0644); err != nil {
+		return err
+	}
+	return rb.rotate(time.Now())
+}
+
+func (rb *resultBackup) rotate(now time.Time) error {
+	infos, err := ioutil.ReadDir(rb.dir)
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		isBackup := strings.HasSuffix(info.Name(), resultBackupExt) || strings.HasSuffix(info.Name(), resultBackupGzipExt)
+		if info.IsDir() || !isBackup || now.Sub(info.ModTime()) < rb.ttl {
+			continue
+		}
+		if err := os.Remove(filepath.Join(rb.dir, info.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readResultBackup reads the backed up result at the given path, decompressing it if the
+// file has the gzip extension.
+func readResultBackup(path string) (*AttachToTangleRes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(path, resultBackupGzipExt) {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	res := &AttachToTangleRes{}
+	if err := json.NewDecoder(r).Decode(res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,136 @@
+package iota
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+// failingWriter fails writing the response body like a dropped client connection.
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func TestResultBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iota-result-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	expired := filepath.Join(dir, "EXPIRED"+resultBackupExt)
+	if err := ioutil.WriteFile(expired, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(expired, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newConfig()
+	cfg.ResultBackupDir = dir
+	cfg.ResultBackupTTL = time.Hour
+	interc, _ := newTestInterceptor(t, cfg)
+	trytes := txTrytes(t, "TEST", 0)
+	status, err := interc.ServeHTTP(failingWriter{httptest.NewRecorder()}, attachRequest(t, "1.1.1.1:1234", 1, trytes))
+	if status != http.StatusInternalServerError || err != ErrBuildingRes {
+		t.Fatalf("expected the failed write to fail the request, got %d: %v", status, err)
+	}
+
+	tx, err := transaction.AsTransactionObject(trytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	backup, err := ioutil.ReadFile(filepath.Join(dir, tx.Bundle+resultBackupExt))
+	if err != nil {
+		t.Fatalf("expected the result to be backed up: %v", err)
+	}
+	res := &AttachToTangleRes{}
+	if err := json.Unmarshal(backup, res); err != nil || len(res.Trytes) != 1 {
+		t.Errorf("expected the backup to hold the PoWed transaction, got %s: %v", backup, err)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Errorf("expected the expired backup to be removed, got %v", err)
+	}
+}
+
+func TestCompressedResultBackup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iota-result-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	plainDir, gzipDir := filepath.Join(dir, "plain"), filepath.Join(dir, "gzip")
+	plain, err := newResultBackup(plainDir, time.Hour, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := newResultBackup(gzipDir, time.Hour, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := &AttachToTangleRes{Trytes: []trinary.Trytes{txTrytes(t, "TEST", 0)}, Duration: 42}
+	const bundleHash = "BUNDLE"
+	if err := plain.store(bundleHash, res); err != nil {
+		t.Fatal(err)
+	}
+	if err := compressed.store(bundleHash, res); err != nil {
+		t.Fatal(err)
+	}
+
+	plainBytes, err := ioutil.ReadFile(filepath.Join(plainDir, bundleHash+resultBackupExt))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(gzipDir, bundleHash+resultBackupGzipExt))
+	if err != nil {
+		t.Fatalf("expected the backup to have the gzip extension: %v", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("expected the backup to be gzipped: %v", err)
+	}
+	decompressed, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, plainBytes) {
+		t.Errorf("expected the decompressed backup to equal the uncompressed one, got %s and %s", decompressed, plainBytes)
+	}
+
+	for _, path := range []string{filepath.Join(plainDir, bundleHash+resultBackupExt), filepath.Join(gzipDir, bundleHash+resultBackupGzipExt)} {
+		read, err := readResultBackup(path)
+		if err != nil {
+			t.Fatalf("unable to read backup %s: %v", path, err)
+		}
+		if !reflect.DeepEqual(read, res) {
+			t.Errorf("expected %s to hold the result, got %+v", path, read)
+		}
+	}
+
+	// compressed backups expire too
+	if err := compressed.rotate(time.Now().Add(2 * time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(gzipDir, bundleHash+resultBackupGzipExt)); !os.IsNotExist(err) {
+		t.Errorf("expected the expired compressed backup to be removed, got %v", err)
+	}
+}
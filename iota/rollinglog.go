@@ -0,0 +1,120 @@
+package iota
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// date format of the archived log files, e.g. iota-2019-06-03.log
+const logArchiveDateFormat = "2006-01-02"
+
+// rollingLogger writes to the log file and, on the first write of a new calendar day in UTC,
+// archives it as <name>-YYYY-MM-DD<ext> with the day of its entries and opens a new one.
+// If keepDays is set, archives older than that many days are deleted on rotation.
+type rollingLogger struct {
+	path     string
+	keepDays int
+	// returns the current time, replaced in tests
+	now func() time.Time
+
+	mu   sync.Mutex
+	file *os.File
+	// day of the entries in the current file
+	day string
+}
+
+func openRollingLogger(path string, keepDays int) (*rollingLogger, error) {
+	l := &rollingLogger{path: path, keepDays: keepDays, now: time.Now}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, err
+	}
+	l.file = file
+	// a file left by a previous run belongs to the day it was last written on
+	l.day = l.now().UTC().Format(logArchiveDateFormat)
+	if info, err := file.Stat(); err == nil && info.Size() > 0 {
+		l.day = info.ModTime().UTC().Format(logArchiveDateFormat)
+	}
+	return l, nil
+}
+
+// Write rotates the file if the day changed and appends p to the current file.
+func (l *rollingLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if today := l.now().UTC().Format(logArchiveDateFormat); today != l.day {
+		l.rotate(today)
+	}
+	return l.file.Write(p)
+}
+
+// rotate archives the current file and opens a new one, must be called with the lock held.
+// Errors go to stderr as the logger itself writes here. If the new file can't be opened,
+// the entries keep going to the archived one so none are lost.
+func (l *rollingLogger) rotate(today string) {
+	archive := l.archivePath(l.day)
+	l.day = today
+	if err := os.Rename(l.path, archive); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to archive log file %s: %v\n", l.path, err)
+		return
+	}
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to open new log file %s, still logging to %s: %v\n", l.path, archive, err)
+		return
+	}
+	l.file.Close()
+	l.file = file
+	if l.keepDays > 0 {
+		l.prune()
+	}
+}
+
+// archivePath returns a path for the archive of the given day which doesn't exist yet,
+// numbered if the day was already archived, e.g. after the clock was turned back.
+func (l *rollingLogger) archivePath(day string) string {
+	ext := filepath.Ext(l.path)
+	base := strings.TrimSuffix(l.path, ext) + "-" + day
+	archive := base + ext
+	for i := 1; ; i++ {
+		if _, err := os.Stat(archive); os.IsNotExist(err) {
+			return archive
+		}
+		archive = base + "." + strconv.Itoa(i) + ext
+	}
+}
+
+// prune deletes the archives older than the kept days.
+func (l *rollingLogger) prune() {
+	ext := filepath.Ext(l.path)
+	prefix := strings.TrimSuffix(l.path, ext) + "-"
+	archives, err := filepath.Glob(prefix + "*" + ext)
+	if err != nil {
+		return
+	}
+	oldest := l.now().UTC().AddDate(0, 0, -l.keepDays).Format(logArchiveDateFormat)
+	for _, archive := range archives {
+		day := strings.TrimPrefix(archive, prefix)
+		if len(day) < len(logArchiveDateFormat) {
+			continue
+		}
+		day = day[:len(logArchiveDateFormat)]
+		if _, err := time.Parse(logArchiveDateFormat, day); err != nil || day >= oldest {
+			continue
+		}
+		if err := os.Remove(archive); err != nil {
+			fmt.Fprintf(os.Stderr, "unable to delete old log file %s: %v\n", archive, err)
+		}
+	}
+}
+
+func (l *rollingLogger) close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Close()
+}
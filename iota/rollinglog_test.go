@@ -0,0 +1,151 @@
+package iota
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRollingLogger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iota-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "iota.log")
+
+	l, err := openRollingLogger(path, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+	now := time.Date(2019, 6, 3, 23, 59, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+	l.day = "2019-06-03"
+
+	// archives older than 7 days are deleted on rotation
+	for _, name := range []string{"iota-2019-05-27.log", "iota-2019-05-28.log", "other-2019-05-01.log"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("old\n"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fmt.Fprintln(l, "monday")
+	now = now.Add(2 * time.Minute)
+	fmt.Fprintln(l, "tuesday")
+
+	expectContent(t, filepath.Join(dir, "iota-2019-06-03.log"), "monday\n")
+	expectContent(t, path, "tuesday\n")
+	expectContent(t, filepath.Join(dir, "iota-2019-05-28.log"), "old\n")
+	expectContent(t, filepath.Join(dir, "other-2019-05-01.log"), "old\n")
+	if _, err := os.Stat(filepath.Join(dir, "iota-2019-05-27.log")); !os.IsNotExist(err) {
+		t.Errorf("expected the archive older than 7 days to be deleted, got %v", err)
+	}
+
+	// a day which is already archived, e.g. after the clock was turned back
+	l.day = "2019-06-03"
+	fmt.Fprintln(l, "wednesday")
+	expectContent(t, filepath.Join(dir, "iota-2019-06-03.1.log"), "tuesday\n")
+	expectContent(t, path, "wednesday\n")
+}
+
+func TestRollingLoggerConcurrentRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iota-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "iota.log")
+
+	l, err := openRollingLogger(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	now := time.Date(2019, 6, 3, 23, 59, 59, 0, time.UTC)
+	l.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	l.day = "2019-06-03"
+
+	const writers, lines = 8, 100
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < lines; j++ {
+				if i == 0 && j == lines/2 {
+					mu.Lock()
+					now = now.Add(time.Second)
+					mu.Unlock()
+				}
+				fmt.Fprintf(l, "writer %d line %d\n", i, j)
+			}
+		}(i)
+	}
+	wg.Wait()
+	l.close()
+
+	total := countLines(t, path) + countLines(t, filepath.Join(dir, "iota-2019-06-03.log"))
+	if total != writers*lines {
+		t.Errorf("expected %d lines across both files, got %d", writers*lines, total)
+	}
+}
+
+func TestRollingLoggerArchivesPreviousRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iota-log")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "iota.log")
+	if err := ioutil.WriteFile(path, []byte("previous run\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	yesterday := time.Now().AddDate(0, 0, -1)
+	if err := os.Chtimes(path, yesterday, yesterday); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := openRollingLogger(path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.close()
+	fmt.Fprintln(l, "today")
+	expectContent(t, filepath.Join(dir, "iota-"+yesterday.UTC().Format(logArchiveDateFormat)+".log"), "previous run\n")
+	expectContent(t, path, "today\n")
+}
+
+func expectContent(t *testing.T, path, expected string) {
+	t.Helper()
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expected {
+		t.Errorf("expected %s to hold %q, got %q", filepath.Base(path), expected, content)
+	}
+}
+
+func countLines(t *testing.T, path string) int {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var n int
+	for s := bufio.NewScanner(f); s.Scan(); {
+		n++
+	}
+	return n
+}
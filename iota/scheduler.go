@@ -0,0 +1,218 @@
+package iota
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// priority of data bundles, value bundles get it multiplied by the configured boost
+const basePoWPriority = 1.0
+
+// errSchedulerClosed is returned to jobs waiting for or asking for a slot after close.
+var errSchedulerClosed = errors.New("the PoW scheduler is closed")
+
+// powScheduler lets the configured amount of PoWs run at a time, one if unset, and hands
+// freed slots to the waiting job with the highest priority. Jobs of the same priority run
+// in arrival order.
+type powScheduler struct {
+	mu      sync.Mutex
+	slots   int
+	running int
+	seq     uint64
+	waiting powTickets
+	// closed on close to free the waiting jobs
+	closed chan struct{}
+}
+
+func newPoWScheduler(slots int) *powScheduler {
+	return &powScheduler{slots: slots, closed: make(chan struct{})}
+}
+
+type powTicket struct {
+	priority float64
+	seq      uint64
+	turn     chan struct{}
+	// position in the heap, -1 once popped
+	index int
+}
+
+// powTickets implements heap.Interface ordered by priority and arrival.
+type powTickets []*powTicket
+
+func (t powTickets) Len() int { return len(t) }
+func (t powTickets) Less(i, j int) bool {
+	if t[i].priority != t[j].priority {
+		return t[i].priority > t[j].priority
+	}
+	return t[i].seq < t[j].seq
+}
+func (t powTickets) Swap(i, j int) {
+	t[i], t[j] = t[j], t[i]
+	t[i].index, t[j].index = i, j
+}
+func (t *powTickets) Push(x interface{}) {
+	ticket := x.(*powTicket)
+	ticket.index = len(*t)
+	*t = append(*t, ticket)
+}
+func (t *powTickets) Pop() interface{} {
+	old := *t
+	ticket := old[len(old)-1]
+	ticket.index = -1
+	*t = old[:len(old)-1]
+	return ticket
+}
+
+// acquire blocks until it's the turn of a job with the given priority. If the context is
+// done or the scheduler closed before, the job is removed from the queue and the context's
+// error or errSchedulerClosed returned.
+func (s *powScheduler) acquire(ctx context.Context, priority float64) error {
+	s.mu.Lock()
+	if s.isClosed() {
+		s.mu.Unlock()
+		return errSchedulerClosed
+	}
+	if s.running < s.capacity() {
+		s.running++
+		s.mu.Unlock()
+		return nil
+	}
+	s.seq++
+	ticket := &powTicket{priority: priority, seq: s.seq, turn: make(chan struct{})}
+	heap.Push(&s.waiting, ticket)
+	s.mu.Unlock()
+	err := errSchedulerClosed
+	select {
+	case <-ticket.turn:
+		return nil
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-s.closed:
+	}
+	s.mu.Lock()
+	if ticket.index >= 0 {
+		heap.Remove(&s.waiting, ticket.index)
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Unlock()
+	// the slot was handed over meanwhile, pass it on
+	s.release()
+	return err
+}
+
+// tryAcquire takes a free slot without waiting and reports whether one was free.
+func (s *powScheduler) tryAcquire() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.isClosed() || s.running >= s.capacity() {
+		return false
+	}
+	s.running++
+	return true
+}
+
+// release hands the slot to the next waiting job.
+func (s *powScheduler) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.waiting.Len() == 0 || s.running > s.capacity() {
+		s.running--
+		return
+	}
+	close(heap.Pop(&s.waiting).(*powTicket).turn)
+}
+
+// close rejects new jobs and frees the waiting ones, running jobs keep their slots.
+func (s *powScheduler) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isClosed() {
+		close(s.closed)
+	}
+}
+
+// isClosed must be called with mu held.
+func (s *powScheduler) isClosed() bool {
+	select {
+	case <-s.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *powScheduler) capacity() int {
+	if s.slots < 1 {
+		return 1
+	}
+	return s.slots
+}
+
+// active returns the amount of running jobs.
+func (s *powScheduler) active() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// queued returns the amount of waiting jobs.
+func (s *powScheduler) queued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waiting.Len()
+}
+
+// ipPoWLimiter caps the amount of simultaneous PoWs per client IP.
+type ipPoWLimiter struct {
+	mu    sync.Mutex
+	max   int
+	slots map[string]*ipPoWSlots
+}
+
+type ipPoWSlots struct {
+	sem chan struct{}
+	// requests holding or waiting for a slot
+	users int
+}
+
+func newIPPoWLimiter(max int) *ipPoWLimiter {
+	return &ipPoWLimiter{max: max, slots: map[string]*ipPoWSlots{}}
+}
+
+// acquire blocks until the IP has a free PoW slot. If the context is done before,
+// the context's error is returned. Each successful acquire must be followed by a release.
+func (l *ipPoWLimiter) acquire(ctx context.Context, ip string) error {
+	l.mu.Lock()
+	slots, has := l.slots[ip]
+	if !has {
+		slots = &ipPoWSlots{sem: make(chan struct{}, l.max)}
+		l.slots[ip] = slots
+	}
+	slots.users++
+	l.mu.Unlock()
+	select {
+	case slots.sem <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if slots.users--; slots.users == 0 {
+		delete(l.slots, ip)
+	}
+	return ctx.Err()
+}
+
+func (l *ipPoWLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	slots := l.slots[ip]
+	<-slots.sem
+	if slots.users--; slots.users == 0 {
+		delete(l.slots, ip)
+	}
+}
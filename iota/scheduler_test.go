@@ -0,0 +1,320 @@
+package iota
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+func TestValueBundlePriorityBoost(t *testing.T) {
+	cfg := newConfig()
+	cfg.ValueBundlePriorityBoost = 10
+	started, release := make(chan struct{}), make(chan struct{})
+	var orderMu sync.Mutex
+	var order []string
+	recordingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		if strings.Contains(trytes, "BLOCKING") {
+			close(started)
+			<-release
+			return consts.NullNonceTrytes, nil
+		}
+		orderMu.Lock()
+		defer orderMu.Unlock()
+		switch {
+		case strings.Contains(trytes, "VALUE"):
+			order = append(order, "value")
+		default:
+			order = append(order, "data")
+		}
+		return consts.NullNonceTrytes, nil
+	}
+	interc, err := newInterceptor(cfg, "Recording", recordingPoW, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interc.Next = &countingNext{}
+
+	var wg sync.WaitGroup
+	attach := func(trytes trinary.Trytes) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, trytes)); status != http.StatusOK {
+				t.Errorf("expected request to succeed, got %d: %v", status, err)
+			}
+		}()
+	}
+	waitQueued := func(n int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for interc.scheduler.queued() != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d queued requests, got %d", n, interc.scheduler.queued())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// occupies the PoW until released
+	attach(txTrytes(t, "BLOCKING", 0))
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("blocking request didn't start its PoW")
+	}
+	for i := 0; i < 5; i++ {
+		attach(txTrytes(t, "DATA", 0))
+		waitQueued(i + 1)
+	}
+	attach(txTrytes(t, "VALUE", 100))
+	waitQueued(6)
+
+	close(release)
+	wg.Wait()
+	if len(order) != 6 || order[0] != "value" {
+		t.Errorf("expected the value bundle to be processed first, got %v", order)
+	}
+}
+
+func TestMaxConcurrentPoWPerIP(t *testing.T) {
+
+	var mu sync.Mutex
+	var running, maxRunning int
+	release := make(chan struct{})
+	blockingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		mu.Lock()
+		if running++; running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+		<-release
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return consts.NullNonceTrytes, nil
+	}
+	waitRunning := func(n int) {
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			mu.Lock()
+			current := running
+			mu.Unlock()
+			if current == n {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %d running PoWs, got %d", n, current)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	cfg := newConfig()
+	cfg.PoWConcurrency = 4
+	cfg.MaxConcurrentPoWPerIP = 2
+	for _, tt := range []struct {
+		name     string
+		ips      []string
+		expected int
+	}{
+		{"same IP", []string{"1.1.1.1", "1.1.1.1", "1.1.1.1", "1.1.1.1"}, 2},
+		{"different IPs", []string{"1.1.1.1", "1.1.1.1", "2.2.2.2", "2.2.2.2"}, 4},
+	} {
+		interc, err := newInterceptor(cfg, "Blocking", blockingPoW, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		interc.Next = &countingNext{}
+		maxRunning = 0
+		release = make(chan struct{})
+
+		var wg sync.WaitGroup
+		for _, ip := range tt.ips {
+			wg.Add(1)
+			go func(ip string) {
+				defer wg.Done()
+				if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, ip+":1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusOK {
+					t.Errorf("expected request to succeed, got %d: %v", status, err)
+				}
+			}(ip)
+		}
+		waitRunning(tt.expected)
+		// give further PoWs the chance to start if the limit doesn't hold
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		if maxRunning != tt.expected {
+			t.Errorf("%s: expected at most %d simultaneous PoWs, got %d", tt.name, tt.expected, maxRunning)
+		}
+	}
+}
+
+func TestIPPoWLimiterCancel(t *testing.T) {
+	l := newIPPoWLimiter(1)
+	if err := l.acquire(context.Background(), "1.1.1.1"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	acquired := make(chan error)
+	go func() { acquired <- l.acquire(ctx, "1.1.1.1") }()
+	select {
+	case err := <-acquired:
+		t.Fatalf("expected the second acquire to wait for the slot, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	cancel()
+	select {
+	case err := <-acquired:
+		if err != context.Canceled {
+			t.Errorf("expected the canceled acquire to fail, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the canceled acquire to return")
+	}
+	if users := l.slots["1.1.1.1"].users; users != 1 {
+		t.Errorf("expected only the slot holder to be counted, got %d users", users)
+	}
+	l.release("1.1.1.1")
+	if len(l.slots) != 0 {
+		t.Errorf("expected the IP to be forgotten, got %v", l.slots)
+	}
+}
+
+func TestIPPoWSlotInterrupted(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{}, 1)
+	blockingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		started <- struct{}{}
+		<-release
+		return consts.NullNonceTrytes, nil
+	}
+	cfg := newConfig()
+	cfg.MaxConcurrentPoWPerIP = 1
+	interc, err := newInterceptor(cfg, "Blocking", blockingPoW, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interc.Next = &countingNext{}
+
+	go interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "FIRST", 0)))
+	<-started
+	waiting := make(chan int)
+	go func() {
+		status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "SECOND", 0)))
+		waiting <- status
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		interc.ipPoW.mu.Lock()
+		users := 0
+		if slots := interc.ipPoW.slots["1.1.1.1"]; slots != nil {
+			users = slots.users
+		}
+		interc.ipPoW.mu.Unlock()
+		if users == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("second request didn't wait for the IP's PoW slot")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// interrupting frees the request waiting for the slot too
+	interc.serveInterruptAttachingToTangle(httptest.NewRecorder(), "1.1.1.1")
+	select {
+	case status := <-waiting:
+		if status != http.StatusBadRequest {
+			t.Errorf("expected the waiting request to be interrupted, got %d", status)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the waiting request to return once interrupted")
+	}
+}
+
+func TestWorkersBusy(t *testing.T) {
+
+	started, release := make(chan struct{}, 2), make(chan struct{})
+	blockingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		if strings.Contains(trytes, "BLOCKING") {
+			started <- struct{}{}
+			<-release
+		}
+		return consts.NullNonceTrytes, nil
+	}
+	cfg := newConfig()
+	cfg.PoWConcurrency = 2
+	cfg.RejectWhenWorkersBusy = true
+	interc, err := newInterceptor(cfg, "Blocking", blockingPoW, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interc.Next = &countingNext{}
+
+	var wg sync.WaitGroup
+	for _, ip := range []string{"1.1.1.1", "2.2.2.2"} {
+		wg.Add(1)
+		go func(ip string) {
+			defer wg.Done()
+			if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, ip+":1234", 1, txTrytes(t, "BLOCKING", 0))); status != http.StatusOK {
+				t.Errorf("expected request to succeed, got %d: %v", status, err)
+			}
+		}(ip)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("blocking requests didn't start their PoW")
+		}
+	}
+
+	w := httptest.NewRecorder()
+	status, err := interc.ServeHTTP(w, attachRequest(t, "3.3.3.3:1234", 1, txTrytes(t, "TEST", 0)))
+	if status != http.StatusTooManyRequests || err != ErrPoWQueueFull {
+		t.Errorf("expected a 429 while all workers are busy, got %d: %v", status, err)
+	}
+	if w.Header().Get(headerRateLimitReset) == "" {
+		t.Error("expected the rate limit reset header to be set")
+	}
+
+	close(release)
+	wg.Wait()
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "3.3.3.3:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusOK {
+		t.Errorf("expected request to succeed after the workers are free, got %d: %v", status, err)
+	}
+}
+
+func TestPoWSchedulerClose(t *testing.T) {
+	s := newPoWScheduler(1)
+	if err := s.acquire(context.Background(), basePoWPriority); err != nil {
+		t.Fatal(err)
+	}
+	waiting := make(chan error, 1)
+	go func() { waiting <- s.acquire(context.Background(), basePoWPriority) }()
+	for s.queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	s.close()
+	if err := <-waiting; err != errSchedulerClosed {
+		t.Errorf("expected the waiting job to be freed, got %v", err)
+	}
+	if s.tryAcquire() {
+		t.Error("expected no slot to be handed out after close")
+	}
+	if s.active() != 1 {
+		t.Errorf("expected the running job to keep its slot, got %d active", s.active())
+	}
+	s.release()
+	if s.active() != 0 {
+		t.Errorf("expected the slot to be released, got %d active", s.active())
+	}
+}
@@ -0,0 +1,39 @@
+package iota
+
+import (
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// loadBundleSchema reads the JSON Schema attachToTangle requests are validated against.
+func loadBundleSchema(file string) (*gojsonschema.Schema, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read bundle schema file")
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(contents))
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid bundle schema %s", file)
+	}
+	return schema, nil
+}
+
+// validateSchema returns ErrSchemaValidation with the violations if the attachToTangle
+// request body doesn't match the bundle schema.
+func (interc *Interceptor) validateSchema(body []byte) error {
+	result, err := interc.bundleSchema.Validate(gojsonschema.NewBytesLoader(body))
+	if err != nil {
+		return errors.Wrap(ErrSchemaValidation, err.Error())
+	}
+	if result.Valid() {
+		return nil
+	}
+	violations := make([]string, len(result.Errors()))
+	for i, violation := range result.Errors() {
+		violations[i] = violation.String()
+	}
+	return errors.Wrap(ErrSchemaValidation, strings.Join(violations, "; "))
+}
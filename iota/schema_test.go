@@ -0,0 +1,58 @@
+package iota
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestBundleSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iota-schema")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	schemaFile := filepath.Join(dir, "bundle.schema.json")
+	schema := `{"type": "object", "required": ["minWeightMagnitude"], "properties": {"minWeightMagnitude": {"type": "integer", "minimum": 9}}}`
+	if err := ioutil.WriteFile(schemaFile, []byte(schema), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newConfig()
+	cfg.BundleSchemaFile = schemaFile
+	interc, _ := newTestInterceptor(t, cfg)
+	for _, tt := range []struct {
+		mwm      int
+		expected int
+	}{
+		{9, http.StatusOK},
+		{8, http.StatusBadRequest},
+	} {
+		status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", tt.mwm, txTrytes(t, "TEST", 0)))
+		if status != tt.expected {
+			t.Errorf("MWM %d: expected %d, got %d: %v", tt.mwm, tt.expected, status, err)
+		}
+		if tt.expected == http.StatusBadRequest {
+			if errors.Cause(err) != ErrSchemaValidation || !strings.Contains(err.Error(), "minWeightMagnitude") {
+				t.Errorf("MWM %d: expected the violation of minWeightMagnitude, got %v", tt.mwm, err)
+			}
+		}
+	}
+
+	if err := ioutil.WriteFile(schemaFile, []byte(`{"type": 1}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newInterceptor(cfg, "Null", nullPoW, logger); err == nil {
+		t.Error("expected an invalid schema to be rejected")
+	}
+	cfg.BundleSchemaFile = filepath.Join(dir, "missing.json")
+	if _, err := newInterceptor(cfg, "Null", nullPoW, logger); err == nil {
+		t.Error("expected a missing schema file to be rejected")
+	}
+}
@@ -0,0 +1,1483 @@
+package iota
+
+import (
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iotaledger/iota.go/guards"
+	"github.com/iotaledger/iota.go/pow"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/iotaledger/iota.go/units"
+	"github.com/mholt/caddy"
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+	"go.opencensus.io/trace"
+)
+
+const (
+	defaultMaxMWM         = 14
+	defaultMaxTxsInBundle = 20
+	defaultMaxRetryAfter  = 60
+	defaultLogHashLength  = 81
+	minLogHashLength      = 8
+)
+
+// Config holds the options parsed from the iota directive.
+type Config struct {
+	// lowest MWM accepted regardless of the validation mode, 0 if only the mode applies
+	MinMWM int
+	// lowest MWM accepted for value bundles, 0 if they accept the same MWMs as data bundles
+	MinMWMValueBundle int
+	MaxMWM            int
+	MaxTxInBundle     int
+	// warn about PoWs doing less hashes per second and call the webhook if set
+	PoWMinHashesPerSec float64
+	PoWDegradedWebhook string
+	// create an OpenCensus span for each intercepted attachToTangle request
+	OpenCensusTrace bool
+	// zipkin or jaeger and the endpoint the spans are sent to, the exporter's default if empty
+	OpenCensusExporter string
+	OpenCensusEndpoint string
+	// exact, min or max: whether the MWM must equal, be at least or be at most the threshold
+	MWMValidationMode string
+	// MWM the validation mode compares against, the max MWM if 0
+	MWMThreshold int
+	// minimum amount of transactions in a bundle
+	MinTxInBundle int
+	// maximum summed input value of a bundle in iotas, 0 disables the limit
+	MaxValue int64
+	// maximum value of a single output transaction in iotas, 0 disables the limit
+	MaxOutputValue int64
+	// URL to POST value bundles moving more than the threshold in iotas to after their PoW
+	AlertWebhook          string
+	AlertWebhookThreshold int64
+	// requests per minute allowed per client IP, 0 disables the limit
+	RateLimit int
+	// IPs whose rate limits are tracked, 0 tracks all
+	RateLimiterMaxIPs int
+	// minimum time between two attachToTangle requests of the same IP
+	MinRequestInterval time.Duration
+	// allow and deny rules for client IPs in the order of their options
+	AccessRules []accessRule
+	// amount of proxies in front of Caddy, the client IP is taken from X-Forwarded-For if set
+	ProxyDepth int
+	// networks of the proxies whose X-Forwarded-For and X-Real-IP headers are trusted
+	TrustedProxies []*net.IPNet
+	// simultaneously served POST requests per IP, 0 disables the limit
+	MaxConnectionsPerIP int
+	// PoWs running at a time across all sites
+	PoWConcurrency int
+	// how long a PoW slot stays taken after the PoW implementation panicked
+	WorkerRestartDelay time.Duration
+	// stop the PoW of requests whose client disconnected
+	CancelPoWOnDisconnect bool
+	// how long a PoW may take before the request fails with a 503, 0 disables the limit
+	PoWTimeout time.Duration
+	// let clients request a shorter PoW timeout via X-IOTA-PoW-Timeout-Ms, failing with a 504
+	ClientRequestedTimeout bool
+	// reject PoWs with a 429 if all slots are taken instead of waiting for one
+	RejectWhenWorkersBusy bool
+	// PoWs running at a time per IP, 0 disables the limit
+	MaxConcurrentPoWPerIP int
+	// requests per minute allowed across all clients, 0 disables the limit
+	GlobalRateLimit int
+	// maximum summed body size of the requests waiting for or doing PoW, 0 disables the limit
+	MaxPendingQueueBytes int64
+	// maximum age of the trunk and branch transaction, 0 disables the check
+	MaxTipAge time.Duration
+	// how long looked up tip ages are cached
+	TipAgeCacheTTL time.Duration
+	// how long getTransactionsToApprove responses are cached, 0 disables the cache
+	TipCacheTTL time.Duration
+	// how many attachToTangle results are kept and for how long to answer identical requests
+	// without doing the PoW again, 0 disables the cache
+	PoWCacheSize int
+	PoWCacheTTL  time.Duration
+	// PoW service attachToTangle requests are sent to, falling back to the local PoW if it
+	// fails or doesn't answer within the timeout
+	RemotePoWURL     string
+	RemotePoWTimeout time.Duration
+	// reject branch transactions which aren't confirmed
+	RequireConfirmedBranch bool
+	// how long looked up inclusion states are cached
+	ConfirmationCacheTTL time.Duration
+	// reject bundles with at least this many confirmed transactions, 0 disables the check
+	MinConfirmationsBeforeReattach int
+	// path to serve the Prometheus metrics on, empty disables them
+	MetricsPath string
+	// path to serve the runtime status on, empty disables it
+	AdminPath string
+	// networks which may access the metrics, stats and admin status, empty allows all
+	AdminAllowedIPs []*net.IPNet
+	// log duration percentiles of the last N PoWs after every N PoWs, 0 disables it
+	StatsEvery int
+	// distinct 3 tryte tag prefixes to keep statistics for on statsPath, 0 disables them
+	MaxTagStatsEntries int
+	// only do PoW and answer all other commands with a 501 instead of forwarding them
+	LightNodeMode bool
+	// do and time the PoW of attachToTangle calls but discard the result and only return the timing
+	DryRun bool
+	// IRI to forward requests to directly instead of via the next handler, presenting
+	// the client certificate if set
+	IRIUpstream       string
+	IRIClientCertFile string
+	IRIClientKeyFile  string
+	// connection pool limits of the IRI upstream, 0 keeps the defaults of http.DefaultTransport
+	BackendMaxIdleConns    int
+	BackendMaxConnsPerHost int
+	BackendIdleConnTimeout time.Duration
+	// reject value bundles spending more than their input addresses hold
+	CheckBalances bool
+	// reject value bundles spending addresses of bundles whose PoW is still pending
+	ReserveInputAddresses bool
+	// how long looking up the balances may take and how long they are cached
+	BalanceCheckTimeout time.Duration
+	BalanceCacheTTL     time.Duration
+	// requests per minute allowed per tag prefix
+	TagRateLimits map[string]int
+	// bundles whose first transaction has one of the denied tags are rejected, as are those
+	// without one of the allowed tags if any are set
+	DeniedTags  []trinary.Trytes
+	AllowedTags []trinary.Trytes
+	// how the PoW results of bundles per tag prefix are delivered, in the response if none matches
+	TagResultHandlers map[string]TagResultHandler
+	// upper bound of the seconds in the Retry-After header of requests rejected due to a full queue
+	MaxRetryAfter int
+	// status code returned to rate limited requests
+	RateLimitStatusCode int
+	// reject attachToTangle requests containing unknown fields
+	StrictJSON bool
+	// reject trytes with characters outside the trytes alphabet
+	StrictTrytesValidation bool
+	TrytesAlphabet         string
+	// skip invalid transaction trytes instead of failing the whole bundle
+	PartialBundleRecovery bool
+	// set the attachment timestamps of transactions to the server time before PoW
+	CorrectAttachmentTimestamp bool
+	// uppercase tags and replace characters which aren't trytes with 9 before parsing
+	NormalizeTags bool
+	// tag to write the expanded metadata template into, empty disables it
+	EmbedMetadataField string
+	MetadataTemplate   string
+	// validate the transactions of storeTransactions calls before forwarding them
+	InterceptStore bool
+	// add the command to the response to distinguish it from IRI's responses
+	IncludeCommandInResponse bool
+	// answer HEAD requests with the interceptor's capabilities instead of forwarding them
+	HeadCapabilities bool
+	// directory to serve GET requests not handled by the plugin from
+	StaticDir string
+	// directory to store the compressed bodies of intercepted requests in
+	BodyCachePath string
+	// amount of most recent request bodies to keep
+	BodyCacheKeep int
+	// directory to back up PoW results in and how long they are kept
+	ResultBackupDir string
+	ResultBackupTTL time.Duration
+	// gzip the backups, stored as <bundle hash>.json.gz
+	ResultBackupCompress bool
+	// replace trunk and branch of forwarded attachToTangle calls with the coordinator tips
+	InjectCoordinatorTips bool
+	CoordinatorTrunk      trinary.Hash
+	CoordinatorBranch     trinary.Hash
+	// NATS server and subject to publish PoW results to
+	NATSURL     string
+	NATSSubject string
+	// amount of messages to buffer while the NATS server is unreachable
+	NATSBufferSize int
+	// CloudWatch Logs stream to send PoW audit events to, the region defaults to the one
+	// of the environment
+	CloudWatchLogGroup  string
+	CloudWatchLogStream string
+	AWSRegion           string
+	// how often the batched audit events are sent at least
+	CloudWatchFlushInterval time.Duration
+	// broadcast PoWed bundles again which aren't confirmed after the interval
+	RebroadcastInterval    time.Duration
+	RebroadcastMaxAttempts int
+	// IRI the PoWed bundles are additionally broadcast to via broadcastTransactions
+	AutoBroadcastURL string
+	// cron schedule on which the PoW of the template request is done ahead of time
+	PrefetchSchedule string
+	PrefetchTemplate string
+	// how long a prefetched PoW answers matching requests
+	PrefetchTTL time.Duration
+	// read-only commands for which concurrent identical requests are forwarded only once
+	DedupCommands []string
+	// factor by which value bundles are preferred over data bundles waiting for PoW
+	ValueBundlePriorityBoost float64
+	// let clients choose the PoW implementation via the X-IOTA-PoW-Preference header
+	HonorPoWPreference bool
+	// PoW implementations to try in order instead of the fastest available one
+	PoWFallbackChain []string
+	// verify the bundle hash of each bundle before doing PoW, enabled by default
+	ValidateBundleHash bool
+	// sponge function the bundle hash is verified with, see bundleHashAlgorithms
+	BundleHashAlgorithm string
+	// pin bundle hashes to the first output address they're seen with, persisted in the database file
+	TOFUPinMode bool
+	TOFUPinDB   string
+	// address types bundles may use: normal, restricted or both
+	AllowedAddressTypes string
+	// reject transactions not carrying the network magic byte, see networkMagicOffset
+	ValidateNetworkMagic bool
+	// -1 if not set
+	NetworkMagicByte int
+	// sign intercepted responses with the Ed25519 key stored in the key file
+	SignResponses  bool
+	SigningKeyFile string
+	// key to set the HMAC-SHA256 of intercepted responses as signature header with
+	SignKey []byte
+	// amount of the pre-shared keys whose HMAC-SHA256 signatures attachToTangle calls must hold
+	MultiSigRequired int
+	MultiSigKeys     []string
+	// JSON Schema file attachToTangle requests must match
+	BundleSchemaFile string
+	// replace message fragments in the log output with [REDACTED]
+	RedactMessageFragments bool
+	// how long to keep serving while announcing the shutdown to clients
+	ShutdownAnnounce time.Duration
+	// how long to wait on shutdown for running PoWs to complete
+	ShutdownPoWDeadline time.Duration
+	// origin allowed to read intercepted responses, * allows all
+	CORSOrigin string
+	// format of attachToTangle responses: legacy trytes or chrysalis hex encoded bytes
+	OutputFormat string
+	// answer panics while serving a request with a 500 instead of crashing Caddy
+	RecoverPanics bool
+	// reject requests on conditions which otherwise only log a warning
+	StrictMode bool
+	// file the log is written to besides stdout, - or stdout disable file logging
+	LogFile string
+	// days the daily archived log files are kept, 0 keeps all
+	LogKeepDays int
+	// amount of trytes of bundle, trunk and branch hashes to log
+	LogHashLength int
+	// full or short, short logs only the first 8 trytes of hashes
+	LogHashFormat string
+	// unit values are logged in, one of valueUnits
+	LogValueUnit string
+	// text or json, json logs the entries of requests as JSON objects without the text prefix
+	LogFormat string
+	// files additionally receiving the entries of value respectively data bundles once the
+	// bundle is parsed
+	ValueBundleLogFile string
+	DataBundleLogFile  string
+}
+
+// units accepted by the maxvalue and log_value_unit options
+var valueUnits = map[string]units.Unit{
+	"i":  units.I,
+	"Ki": units.Ki,
+	"Mi": units.Mi,
+	"Gi": units.Gi,
+	"Ti": units.Ti,
+	"Pi": units.Pi,
+}
+
+const defaultLogValueUnit = "Mi"
+
+// newConfig returns a Config holding the default options.
+func newConfig() *Config {
+	return &Config{
+		LogFile:                  defaultLogFile,
+		MaxMWM:                   defaultMaxMWM,
+		MaxTxInBundle:            defaultMaxTxsInBundle,
+		MinTxInBundle:            1,
+		PoWConcurrency:           1,
+		WorkerRestartDelay:       defaultWorkerRestartDelay,
+		TrytesAlphabet:           defaultTrytesAlphabet,
+		MWMValidationMode:        mwmValidationMax,
+		OpenCensusExporter:       traceExporterZipkin,
+		TagRateLimits:            map[string]int{},
+		TagResultHandlers:        map[string]TagResultHandler{},
+		RateLimitStatusCode:      http.StatusTooManyRequests,
+		MaxRetryAfter:            defaultMaxRetryAfter,
+		TipAgeCacheTTL:           defaultTipAgeCacheTTL,
+		NATSBufferSize:           defaultNATSBufferSize,
+		ValidateBundleHash:       true,
+		BundleHashAlgorithm:      defaultBundleHashAlgorithm,
+		TOFUPinDB:                defaultTOFUPinDB,
+		OutputFormat:             outputFormatLegacy,
+		CORSOrigin:               "*",
+		ValueBundlePriorityBoost: 1,
+		PrefetchTTL:              defaultPrefetchTTL,
+		ConfirmationCacheTTL:     defaultConfirmationCacheTTL,
+		BalanceCheckTimeout:      defaultBalanceCheckTimeout,
+		BalanceCacheTTL:          defaultBalanceCacheTTL,
+		LogHashLength:            defaultLogHashLength,
+		LogHashFormat:            logHashFormatFull,
+		LogValueUnit:             defaultLogValueUnit,
+		LogFormat:                logFormatText,
+		AllowedAddressTypes:      addressTypeBoth,
+		NetworkMagicByte:         -1,
+		RebroadcastMaxAttempts:   defaultRebroadcastMaxAttempts,
+		ResultBackupTTL:          defaultResultBackupTTL,
+		ShutdownPoWDeadline:      defaultShutdownPoWDeadline,
+		RemotePoWTimeout:         defaultRemotePoWTimeout,
+		CloudWatchFlushInterval:  defaultCloudWatchFlushInterval,
+	}
+}
+
+func setup(c *caddy.Controller) error {
+	return setupInterceptor(c, c.OnShutdown, c.OnFinalShutdown)
+}
+
+// setupInterceptor sets up the interceptor of the iota block and registers its cleanups with
+// the given funcs, c.OnShutdown and c.OnFinalShutdown outside of tests.
+func setupInterceptor(c *caddy.Controller, onShutdown, onFinalShutdown func(func() error)) error {
+	cfg, err := parseConfig(c)
+	if err != nil {
+		return err
+	}
+	// the startup messages and the interceptor of this block log through its own logger
+	logger, closeLog, err := openLog(cfg.LogFile, cfg.LogKeepDays, cfg.LogFormat)
+	if err != nil {
+		return c.Errf("unable to open log file %s: %v", cfg.LogFile, err)
+	}
+	onShutdown(closeLog)
+	name, powFunc := pow.GetFastestProofOfWorkImpl()
+	if len(cfg.PoWFallbackChain) > 0 {
+		// the interceptor chains the implementations
+		name = strings.Join(cfg.PoWFallbackChain, ",")
+	}
+	logger.Printf("iota API call interception configured with max bundle txs limit of %d and max MWM of %d\n", cfg.MaxTxInBundle, cfg.MaxMWM)
+	if cfg.MWMValidationMode != mwmValidationMax || cfg.MWMThreshold > 0 {
+		logger.Printf("validating the MWM in %s mode against %d\n", cfg.MWMValidationMode, cfg.mwmThreshold())
+	}
+	if cfg.MinMWM > 0 {
+		logger.Printf("only accepting MWMs between %d and %d\n", cfg.MinMWM, cfg.MaxMWM)
+	}
+	if cfg.MinMWMValueBundle > 0 {
+		logger.Printf("requiring value bundles to use an MWM of at least %d\n", cfg.MinMWMValueBundle)
+	}
+	if cfg.MaxValue > 0 {
+		logger.Printf("rejecting bundles moving more than %di\n", cfg.MaxValue)
+	}
+	if cfg.MaxOutputValue > 0 {
+		logger.Printf("rejecting bundles sending more than %di to a single output\n", cfg.MaxOutputValue)
+	}
+	if cfg.AlertWebhook != "" {
+		logger.Printf("alerting %s of bundles moving more than %di\n", cfg.AlertWebhook, cfg.AlertWebhookThreshold)
+	}
+	if cfg.MinTxInBundle > 1 {
+		logger.Printf("requiring bundles to have at least %d txs\n", cfg.MinTxInBundle)
+	}
+	logger.Printf("using PoW implementation: %s\n", name)
+	if cfg.ValueBundlePriorityBoost != 1 {
+		logger.Printf("boosting the PoW priority of value bundles by %g\n", cfg.ValueBundlePriorityBoost)
+	}
+	if cfg.HonorPoWPreference {
+		logger.Println("honoring PoW implementation preferences of clients")
+	}
+	if cfg.RateLimit > 0 {
+		logger.Printf("limiting attachToTangle calls to %d per minute per IP\n", cfg.RateLimit)
+		if cfg.RateLimiterMaxIPs > 0 {
+			logger.Printf("tracking the rate limits of the %d most recent IPs\n", cfg.RateLimiterMaxIPs)
+		}
+	}
+	if cfg.MinRequestInterval > 0 {
+		logger.Printf("requiring at least %v between attachToTangle calls of the same IP\n", cfg.MinRequestInterval)
+	}
+	if cfg.ProxyDepth > 0 {
+		logger.Printf("taking client IPs from X-Forwarded-For behind %d proxies\n", cfg.ProxyDepth)
+	}
+	for _, network := range cfg.AdminAllowedIPs {
+		logger.Printf("allowing clients of %s to access the admin endpoints\n", network)
+	}
+	for _, network := range cfg.TrustedProxies {
+		logger.Printf("trusting X-Forwarded-For and X-Real-IP of proxies in %s\n", network)
+	}
+	for _, rule := range cfg.AccessRules {
+		if rule.allow {
+			logger.Printf("allowing clients of %s\n", rule.network)
+		} else {
+			logger.Printf("denying clients of %s\n", rule.network)
+		}
+	}
+	if cfg.MaxConnectionsPerIP > 0 {
+		logger.Printf("limiting simultaneous connections to %d per IP\n", cfg.MaxConnectionsPerIP)
+	}
+	switch {
+	case cfg.RejectWhenWorkersBusy:
+		logger.Printf("running PoWs on %d workers, rejecting requests while all are busy\n", cfg.PoWConcurrency)
+	case cfg.PoWConcurrency > 1:
+		logger.Printf("running up to %d PoWs at a time\n", cfg.PoWConcurrency)
+	}
+	if cfg.PoWTimeout > 0 {
+		logger.Printf("failing PoWs taking longer than %v\n", cfg.PoWTimeout)
+	}
+	if cfg.ClientRequestedTimeout {
+		logger.Printf("letting clients request shorter PoW timeouts via %s\n", headerPoWTimeout)
+	}
+	if cfg.CancelPoWOnDisconnect {
+		logger.Printf("canceling the PoW of requests whose client disconnected\n")
+	}
+	if cfg.MaxConcurrentPoWPerIP > 0 {
+		logger.Printf("limiting simultaneous PoWs to %d per IP\n", cfg.MaxConcurrentPoWPerIP)
+	}
+	if cfg.GlobalRateLimit > 0 {
+		logger.Printf("limiting attachToTangle calls to %d per minute across all clients\n", cfg.GlobalRateLimit)
+	}
+	if cfg.MaxPendingQueueBytes > 0 {
+		logger.Printf("limiting queued attachToTangle requests to %d bytes\n", cfg.MaxPendingQueueBytes)
+	}
+	if cfg.MaxTipAge > 0 {
+		logger.Printf("rejecting trunk and branch transactions older than %v\n", cfg.MaxTipAge)
+	}
+	if cfg.TipCacheTTL > 0 {
+		logger.Printf("caching getTransactionsToApprove responses for %v\n", cfg.TipCacheTTL)
+	}
+	if cfg.RemotePoWURL != "" {
+		logger.Printf("doing PoW via %s, falling back to local PoW after %v\n", cfg.RemotePoWURL, cfg.RemotePoWTimeout)
+	}
+	if cfg.PoWCacheSize > 0 {
+		logger.Printf("caching the last %d PoW results for %v\n", cfg.PoWCacheSize, cfg.PoWCacheTTL)
+	}
+	if cfg.RequireConfirmedBranch {
+		logger.Println("rejecting unconfirmed branch transactions")
+	}
+	if cfg.MinConfirmationsBeforeReattach > 0 {
+		logger.Printf("rejecting re-attachments of bundles with at least %d confirmed transactions\n", cfg.MinConfirmationsBeforeReattach)
+	}
+	if cfg.CORSOrigin != "*" {
+		logger.Printf("only allowing CORS origin %s\n", cfg.CORSOrigin)
+	}
+	if cfg.MetricsPath != "" {
+		logger.Printf("serving Prometheus metrics on %s\n", cfg.MetricsPath)
+	}
+	if cfg.AdminPath != "" {
+		logger.Printf("serving the runtime status on %s\n", cfg.AdminPath)
+	}
+	if cfg.StatsEvery > 0 {
+		logger.Printf("logging PoW duration percentiles every %d PoWs\n", cfg.StatsEvery)
+	}
+	if cfg.MaxTagStatsEntries > 0 {
+		logger.Printf("serving statistics of up to %d tag prefixes on %s\n", cfg.MaxTagStatsEntries, statsPath)
+	}
+	if cfg.IRIUpstream != "" {
+		logger.Printf("forwarding requests directly to IRI at %s\n", cfg.IRIUpstream)
+		if cfg.IRIClientCertFile != "" {
+			logger.Printf("presenting client certificate %s to IRI\n", cfg.IRIClientCertFile)
+		}
+		if cfg.BackendMaxConnsPerHost > 0 {
+			logger.Printf("opening at most %d connections to IRI\n", cfg.BackendMaxConnsPerHost)
+		}
+	}
+	if cfg.CheckBalances {
+		logger.Println("rejecting bundles spending more than their inputs hold")
+	}
+	if cfg.ReserveInputAddresses {
+		logger.Println("locking the input addresses of value bundles while their PoW is pending")
+	}
+	for prefix, rpm := range cfg.TagRateLimits {
+		logger.Printf("limiting attachToTangle calls with tag prefix %s to %d per minute\n", prefix, rpm)
+	}
+	if len(cfg.DeniedTags) > 0 {
+		logger.Printf("rejecting bundles with the tags %s\n", strings.Join(cfg.DeniedTags, ", "))
+	}
+	if len(cfg.AllowedTags) > 0 {
+		logger.Printf("only accepting bundles with the tags %s\n", strings.Join(cfg.AllowedTags, ", "))
+	}
+	if cfg.CorrectAttachmentTimestamp {
+		logger.Println("correcting attachment timestamps to the server time")
+	}
+	if cfg.InterceptStore {
+		logger.Println("validating storeTransactions calls")
+	}
+	if cfg.StrictTrytesValidation {
+		logger.Printf("rejecting trytes with characters outside of %s\n", cfg.TrytesAlphabet)
+	}
+	if cfg.EmbedMetadataField != "" {
+		logger.Printf("embedding metadata %q into the %s of transactions\n", cfg.MetadataTemplate, cfg.EmbedMetadataField)
+	}
+	if cfg.NormalizeTags {
+		logger.Println("normalizing transaction tags to uppercase trytes")
+	}
+	if cfg.PartialBundleRecovery {
+		logger.Println("partial bundle recovery enabled, invalid transactions will be skipped")
+	}
+	if cfg.StaticDir != "" {
+		logger.Printf("serving static files from %s\n", cfg.StaticDir)
+	}
+	if cfg.BodyCachePath != "" {
+		logger.Printf("caching the last %d request bodies in %s\n", cfg.BodyCacheKeep, cfg.BodyCachePath)
+	}
+	if cfg.ResultBackupDir != "" {
+		logger.Printf("backing up PoW results in %s for %v\n", cfg.ResultBackupDir, cfg.ResultBackupTTL)
+	}
+	if cfg.SignResponses {
+		logger.Printf("signing responses with the Ed25519 key from %s\n", cfg.SigningKeyFile)
+	}
+	if cfg.SignKey != nil {
+		logger.Printf("setting the HMAC-SHA256 of responses in the %s header\n", headerHMACSignature)
+	}
+	if cfg.MultiSigRequired > 0 {
+		logger.Printf("requiring authorizations of %d of %d keys for attachToTangle calls\n", cfg.MultiSigRequired, len(cfg.MultiSigKeys))
+	}
+	if cfg.BundleSchemaFile != "" {
+		logger.Printf("validating attachToTangle requests against the JSON Schema %s\n", cfg.BundleSchemaFile)
+	}
+	if cfg.StrictMode {
+		logger.Println("strict mode enabled, warnings are turned into errors")
+	}
+	if cfg.RecoverPanics {
+		logger.Println("recovering panics while serving requests")
+	}
+	if cfg.AllowedAddressTypes != addressTypeBoth {
+		logger.Printf("only allowing %s addresses\n", cfg.AllowedAddressTypes)
+	}
+	if cfg.ValidateNetworkMagic {
+		logger.Printf("rejecting transactions without network magic byte 0x%02x\n", cfg.NetworkMagicByte)
+	}
+	if cfg.TOFUPinMode {
+		logger.Printf("pinning bundle hashes to output addresses in %s\n", cfg.TOFUPinDB)
+	}
+	if !cfg.ValidateBundleHash {
+		logger.Println("not validating bundle hashes")
+	} else if cfg.BundleHashAlgorithm != defaultBundleHashAlgorithm {
+		logger.Printf("validating bundle hashes using %s\n", cfg.BundleHashAlgorithm)
+	}
+	if len(cfg.DedupCommands) > 0 {
+		logger.Printf("deduplicating concurrent identical %v requests\n", cfg.DedupCommands)
+	}
+	if cfg.InjectCoordinatorTips {
+		logger.Printf("injecting coordinator tips into forwarded attachToTangle calls\n")
+	}
+	if cfg.LightNodeMode {
+		logger.Println("light node mode enabled, commands other than attachToTangle receive a 501")
+	}
+	if cfg.DryRun {
+		logger.Println("DRY RUN mode enabled, PoW results are discarded and never returned to clients")
+	}
+	interc, err := newInterceptor(cfg, name, powFunc, logger)
+	if err != nil {
+		return c.Err(err.Error())
+	}
+	if cfg.ValueBundleLogFile != "" {
+		valueLog, closeValueLog, err := openBundleLog(cfg.ValueBundleLogFile, cfg.LogFormat)
+		if err != nil {
+			return c.Errf("unable to open value bundle log file %s: %v", cfg.ValueBundleLogFile, err)
+		}
+		interc.valueLog = valueLog
+		onShutdown(closeValueLog)
+		logger.Printf("logging value bundles to %s\n", cfg.ValueBundleLogFile)
+	}
+	if cfg.DataBundleLogFile != "" {
+		dataLog, closeDataLog, err := openBundleLog(cfg.DataBundleLogFile, cfg.LogFormat)
+		if err != nil {
+			return c.Errf("unable to open data bundle log file %s: %v", cfg.DataBundleLogFile, err)
+		}
+		interc.dataLog = dataLog
+		onShutdown(closeDataLog)
+		logger.Printf("logging data bundles to %s\n", cfg.DataBundleLogFile)
+	}
+	mid := func(next httpserver.Handler) httpserver.Handler {
+		interc.setNext(next)
+		return interc
+	}
+	if interc.rebroadcaster != nil {
+		logger.Printf("rebroadcasting unconfirmed bundles every %v up to %d times\n", cfg.RebroadcastInterval, cfg.RebroadcastMaxAttempts)
+		onShutdown(func() error {
+			interc.rebroadcaster.close()
+			return nil
+		})
+	}
+	if cfg.AutoBroadcastURL != "" {
+		logger.Printf("broadcasting PoWed bundles to %s\n", cfg.AutoBroadcastURL)
+	}
+	if cfg.PoWMinHashesPerSec > 0 {
+		logger.Printf("warning about PoWs doing less than %.0f hashes per second\n", cfg.PoWMinHashesPerSec)
+	}
+	if cfg.OpenCensusTrace {
+		exporter, closeExporter, err := newTraceExporter(cfg.OpenCensusExporter, cfg.OpenCensusEndpoint)
+		if err != nil {
+			return err
+		}
+		trace.RegisterExporter(exporter)
+		logger.Printf("exporting OpenCensus spans of attachToTangle requests to %s\n", cfg.OpenCensusExporter)
+		onShutdown(func() error {
+			trace.UnregisterExporter(exporter)
+			closeExporter()
+			return nil
+		})
+	}
+	if interc.prefetch != nil {
+		logger.Printf("prefetching the PoW of %s on schedule '%s'\n", cfg.PrefetchTemplate, cfg.PrefetchSchedule)
+		interc.prefetch.start()
+		onShutdown(func() error {
+			interc.prefetch.close()
+			return nil
+		})
+	}
+	if cfg.ShutdownAnnounce > 0 {
+		onFinalShutdown(interc.drainForShutdown)
+	}
+	// registered after the announcement so PoWs are still accepted while it lasts
+	onFinalShutdown(interc.stopPoW)
+	if interc.bundlePins != nil {
+		onShutdown(interc.bundlePins.close)
+	}
+	if interc.natsPub != nil {
+		logger.Printf("publishing PoW results to NATS subject %s on %s\n", cfg.NATSSubject, cfg.NATSURL)
+		onShutdown(func() error {
+			interc.natsPub.close()
+			return nil
+		})
+	}
+	if interc.resultRouter != nil {
+		for prefix, handler := range cfg.TagResultHandlers {
+			logger.Printf("delivering PoW results of bundles with tag prefix %s via %s\n", prefix, handler.Type)
+		}
+		onShutdown(interc.resultRouter.close)
+	}
+	if interc.auditor != nil {
+		logger.Printf("sending PoW audit events to CloudWatch Logs stream %s/%s every %v\n", cfg.CloudWatchLogGroup, cfg.CloudWatchLogStream, cfg.CloudWatchFlushInterval)
+		onShutdown(interc.auditor.close)
+	}
+	if cfg.AdminPath != "" {
+		// added first so it runs in front of the interceptor
+		httpserver.GetConfig(c).AddMiddleware(func(next httpserver.Handler) httpserver.Handler {
+			return &adminStatus{interc: interc, next: next}
+		})
+	}
+	httpserver.GetConfig(c).AddMiddleware(mid)
+	return nil
+}
+
+func parseConfig(c *caddy.Controller) (*Config, error) {
+	cfg := newConfig()
+	var err error
+	for c.Next() {
+		args := c.RemainingArgs()
+		if len(args) != 2 && len(args) != 3 {
+			return nil, c.ArgErr()
+		}
+		if len(args) == 3 {
+			cfg.LogFile = args[2]
+		}
+		cfg.MaxMWM, err = strconv.Atoi(args[0])
+		if err != nil {
+			cfg.MaxMWM = defaultMaxMWM
+			logger.Printf("setting max allowed MWM to %d\n", cfg.MaxMWM)
+		}
+		cfg.MaxTxInBundle, err = strconv.Atoi(args[1])
+		if err != nil {
+			cfg.MaxTxInBundle = defaultMaxTxsInBundle
+			logger.Printf("setting max txs per bundle to %d\n", cfg.MaxTxInBundle)
+		}
+
+		for c.NextBlock() {
+			switch c.Val() {
+			case "pow_min_hashes_per_sec":
+				arg, err := stringArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if cfg.PoWMinHashesPerSec, err = strconv.ParseFloat(arg, 64); err != nil || cfg.PoWMinHashesPerSec <= 0 {
+					return nil, c.Errf("pow_min_hashes_per_sec expects a positive number, got '%s'", arg)
+				}
+			case "pow_degraded_webhook":
+				if cfg.PoWDegradedWebhook, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "opencensus_trace":
+				if cfg.OpenCensusTrace, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "opencensus_exporter":
+				// Format: opencensus_exporter zipkin|jaeger [<endpoint>]
+				args := c.RemainingArgs()
+				if len(args) < 1 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case traceExporterZipkin, traceExporterJaeger:
+				case traceExporterStackdriver:
+					return nil, c.Err("the stackdriver exporter is not available in this build, use zipkin or jaeger")
+				default:
+					return nil, c.Errf("unknown OpenCensus exporter '%s', use zipkin or jaeger", args[0])
+				}
+				cfg.OpenCensusExporter = args[0]
+				if len(args) == 2 {
+					cfg.OpenCensusEndpoint = args[1]
+				}
+			case "mwm_validation_mode":
+				// Format: mwm_validation_mode exact|min|max [<threshold>]
+				args := c.RemainingArgs()
+				if len(args) < 1 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				switch args[0] {
+				case mwmValidationExact, mwmValidationMin, mwmValidationMax:
+					cfg.MWMValidationMode = args[0]
+				default:
+					return nil, c.Errf("unknown MWM validation mode '%s', use exact, min or max", args[0])
+				}
+				if len(args) == 2 {
+					if cfg.MWMThreshold, err = strconv.Atoi(args[1]); err != nil || cfg.MWMThreshold <= 0 {
+						return nil, c.Errf("mwm_validation_mode expects a positive threshold, got '%s'", args[1])
+					}
+				}
+			case "mwm":
+				// Format: mwm <min> <max>
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				if cfg.MinMWM, err = strconv.Atoi(args[0]); err != nil || cfg.MinMWM <= 0 {
+					return nil, c.Errf("mwm expects a positive min MWM, got '%s'", args[0])
+				}
+				if cfg.MaxMWM, err = strconv.Atoi(args[1]); err != nil {
+					return nil, c.Errf("mwm expects a max MWM, got '%s'", args[1])
+				}
+			case "min_mwm_value_bundle":
+				if cfg.MinMWMValueBundle, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "min_tx_per_bundle":
+				if cfg.MinTxInBundle, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "rate_limit":
+				if cfg.RateLimit, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "maxvalue":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				if cfg.MaxValue, err = parseValue(c, "maxvalue", args[0], args[1]); err != nil {
+					return nil, err
+				}
+			case "max_output_value_miota":
+				amount, err := stringArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if cfg.MaxOutputValue, err = parseValue(c, "max_output_value_miota", amount, "Mi"); err != nil {
+					return nil, err
+				}
+			case "alertwebhook":
+				// Format: alertwebhook <url> <amount> <unit>
+				args := c.RemainingArgs()
+				if len(args) != 3 {
+					return nil, c.ArgErr()
+				}
+				cfg.AlertWebhook = args[0]
+				if cfg.AlertWebhookThreshold, err = parseValue(c, "alertwebhook", args[1], args[2]); err != nil {
+					return nil, err
+				}
+			case "rate_limiter_max_ips":
+				if cfg.RateLimiterMaxIPs, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "min_request_interval_ms":
+				ms, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.MinRequestInterval = time.Duration(ms) * time.Millisecond
+			case "proxy_depth":
+				if cfg.ProxyDepth, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "max_connections_per_ip":
+				if cfg.MaxConnectionsPerIP, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "pow_concurrency":
+				if cfg.PoWConcurrency, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "workers":
+				if cfg.PoWConcurrency, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+				cfg.RejectWhenWorkersBusy = true
+			case "worker_restart_delay_ms":
+				ms, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.WorkerRestartDelay = time.Duration(ms) * time.Millisecond
+			case "powtimeout":
+				if cfg.PoWTimeout, err = positiveDurationArg(c); err != nil {
+					return nil, err
+				}
+			case "client_requested_timeout":
+				if cfg.ClientRequestedTimeout, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "cancel_pow_on_disconnect":
+				if cfg.CancelPoWOnDisconnect, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "max_concurrent_pow_per_ip":
+				if cfg.MaxConcurrentPoWPerIP, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "global_rate_limit_rpm":
+				if cfg.GlobalRateLimit, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "max_pending_queue_bytes":
+				n, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.MaxPendingQueueBytes = int64(n)
+			case "max_retry_after_sec":
+				if cfg.MaxRetryAfter, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "max_tip_age_minutes":
+				minutes, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.MaxTipAge = time.Duration(minutes) * time.Minute
+			case "tip_age_cache_ttl_ms":
+				ms, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.TipAgeCacheTTL = time.Duration(ms) * time.Millisecond
+			case "remotepow":
+				if cfg.RemotePoWURL, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "remotepow_timeout":
+				if cfg.RemotePoWTimeout, err = positiveDurationArg(c); err != nil {
+					return nil, err
+				}
+			case "powcache":
+				// Format: powcache <entries> <ttl>
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				if cfg.PoWCacheSize, err = strconv.Atoi(args[0]); err != nil || cfg.PoWCacheSize <= 0 {
+					return nil, c.Errf("powcache expects a positive amount of entries, got '%s'", args[0])
+				}
+				if cfg.PoWCacheTTL, err = time.ParseDuration(args[1]); err != nil || cfg.PoWCacheTTL <= 0 {
+					return nil, c.Errf("powcache expects a positive duration like 10s, got '%s'", args[1])
+				}
+			case "tipcache":
+				if cfg.TipCacheTTL, err = positiveDurationArg(c); err != nil {
+					return nil, err
+				}
+			case "require_confirmed_branch":
+				if cfg.RequireConfirmedBranch, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "confirmation_cache_ttl_ms":
+				ms, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.ConfirmationCacheTTL = time.Duration(ms) * time.Millisecond
+			case "min_confirmations_before_reattach":
+				if cfg.MinConfirmationsBeforeReattach, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "cors":
+				if cfg.CORSOrigin, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "metrics":
+				if cfg.MetricsPath, err = stringArg(c); err != nil {
+					return nil, err
+				}
+				if !strings.HasPrefix(cfg.MetricsPath, "/") {
+					return nil, c.Errf("metrics path must start with /, got '%s'", cfg.MetricsPath)
+				}
+			case "statsevery":
+				if cfg.StatsEvery, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "max_tag_stats_entries":
+				if cfg.MaxTagStatsEntries, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "adminpath":
+				if cfg.AdminPath, err = stringArg(c); err != nil {
+					return nil, err
+				}
+				if !strings.HasPrefix(cfg.AdminPath, "/") {
+					return nil, c.Errf("adminpath must start with /, got '%s'", cfg.AdminPath)
+				}
+			case "admin_allowed_ips":
+				cidrs := c.RemainingArgs()
+				if len(cidrs) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, cidr := range cidrs {
+					rule, err := parseAccessRule(true, cidr)
+					if err != nil {
+						return nil, c.Errf("invalid CIDR '%s': %v", cidr, err)
+					}
+					cfg.AdminAllowedIPs = append(cfg.AdminAllowedIPs, rule.network)
+				}
+			case "allow", "deny":
+				allow := c.Val() == "allow"
+				cidr, err := stringArg(c)
+				if err != nil {
+					return nil, err
+				}
+				rule, err := parseAccessRule(allow, cidr)
+				if err != nil {
+					return nil, c.Errf("invalid CIDR '%s': %v", cidr, err)
+				}
+				cfg.AccessRules = append(cfg.AccessRules, rule)
+			case "trustedproxy":
+				cidrs := c.RemainingArgs()
+				if len(cidrs) == 0 {
+					return nil, c.ArgErr()
+				}
+				for _, cidr := range cidrs {
+					rule, err := parseAccessRule(true, cidr)
+					if err != nil {
+						return nil, c.Errf("invalid CIDR '%s': %v", cidr, err)
+					}
+					cfg.TrustedProxies = append(cfg.TrustedProxies, rule.network)
+				}
+			case "light_node_mode":
+				if cfg.LightNodeMode, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "dryrun":
+				if cfg.DryRun, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "iri_upstream":
+				if cfg.IRIUpstream, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "iota_client_cert_file":
+				if cfg.IRIClientCertFile, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "iota_client_key_file":
+				if cfg.IRIClientKeyFile, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "backend_max_idle_conns":
+				if cfg.BackendMaxIdleConns, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "backend_max_conns_per_host":
+				if cfg.BackendMaxConnsPerHost, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "backend_idle_conn_timeout_sec":
+				secs, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.BackendIdleConnTimeout = time.Duration(secs) * time.Second
+			case "check_balances":
+				if cfg.CheckBalances, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "reserve_input_addresses":
+				if cfg.ReserveInputAddresses, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "balance_check_timeout_ms":
+				ms, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.BalanceCheckTimeout = time.Duration(ms) * time.Millisecond
+			case "balance_cache_ttl_ms":
+				ms, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.BalanceCacheTTL = time.Duration(ms) * time.Millisecond
+			case "tag_rate_limit":
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				rpm, err := strconv.Atoi(args[1])
+				if err != nil || rpm <= 0 {
+					return nil, c.Errf("invalid requests per minute '%s' for tag prefix %s", args[1], args[0])
+				}
+				cfg.TagRateLimits[args[0]] = rpm
+			case "denytag", "allowtag":
+				deny := c.Val() == "denytag"
+				tag, err := stringArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if !guards.IsTag(tag) {
+					return nil, c.Errf("invalid tag '%s', expected 27 trytes", tag)
+				}
+				if deny {
+					cfg.DeniedTags = append(cfg.DeniedTags, tag)
+				} else {
+					cfg.AllowedTags = append(cfg.AllowedTags, tag)
+				}
+			case "tag_result_handler":
+				if err := parseTagResultHandlers(c, cfg.TagResultHandlers); err != nil {
+					return nil, err
+				}
+			case "rate_limit_status_code":
+				if cfg.RateLimitStatusCode, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+				if cfg.RateLimitStatusCode < 400 || cfg.RateLimitStatusCode > 599 {
+					return nil, c.Errf("rate limit status code must be a 4xx or 5xx code, got %d", cfg.RateLimitStatusCode)
+				}
+			case "correct_attachment_timestamp":
+				if cfg.CorrectAttachmentTimestamp, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "embed_metadata_field":
+				if cfg.EmbedMetadataField, err = stringArg(c); err != nil {
+					return nil, err
+				}
+				if cfg.EmbedMetadataField != metadataFieldTag {
+					return nil, c.Errf("unknown metadata field '%s', use tag", cfg.EmbedMetadataField)
+				}
+			case "metadata_template":
+				if cfg.MetadataTemplate, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "normalize_tags":
+				if cfg.NormalizeTags, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "intercept_store":
+				if cfg.InterceptStore, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "include_command_in_response":
+				if cfg.IncludeCommandInResponse, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "strict_trytes_validation":
+				if cfg.StrictTrytesValidation, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "trytes_alphabet":
+				if cfg.TrytesAlphabet, err = stringArg(c); err != nil {
+					return nil, err
+				}
+				if _, err := newTrytesAlphabet(cfg.TrytesAlphabet); err != nil {
+					return nil, c.Errf("invalid trytes_alphabet: %v", err)
+				}
+			case "strict_json":
+				if cfg.StrictJSON, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "partial_bundle_recovery":
+				if cfg.PartialBundleRecovery, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "head_capabilities":
+				if cfg.HeadCapabilities, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "static_dir":
+				if cfg.StaticDir, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "body_cache_path":
+				// Format: body_cache_path <dir> [<keep>]
+				args := c.RemainingArgs()
+				if len(args) == 0 || len(args) > 2 {
+					return nil, c.ArgErr()
+				}
+				cfg.BodyCachePath = args[0]
+				cfg.BodyCacheKeep = defaultBodyCacheKeep
+				if len(args) == 2 {
+					keep, err := strconv.Atoi(args[1])
+					if err != nil || keep <= 0 {
+						return nil, c.Errf("invalid amount of request bodies to keep '%s'", args[1])
+					}
+					cfg.BodyCacheKeep = keep
+				}
+			case "result_backup_dir":
+				if cfg.ResultBackupDir, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "result_backup_compress":
+				if cfg.ResultBackupCompress, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "result_backup_ttl_hours":
+				hours, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.ResultBackupTTL = time.Duration(hours) * time.Hour
+			case "inject_coordinator_tips":
+				if cfg.InjectCoordinatorTips, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "coordinator_tips":
+				// Format: coordinator_tips <trunk> <branch>
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				for _, hash := range args {
+					if !guards.IsHash(hash) {
+						return nil, c.Errf("invalid coordinator tip '%s'", hash)
+					}
+				}
+				cfg.CoordinatorTrunk, cfg.CoordinatorBranch = args[0], args[1]
+			case "nats_url":
+				if cfg.NATSURL, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "nats_subject":
+				if cfg.NATSSubject, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "nats_buffer_size":
+				if cfg.NATSBufferSize, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "cloudwatch_log_group":
+				if cfg.CloudWatchLogGroup, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "cloudwatch_log_stream":
+				if cfg.CloudWatchLogStream, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "aws_region":
+				if cfg.AWSRegion, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "cloudwatch_flush_interval_sec":
+				secs, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.CloudWatchFlushInterval = time.Duration(secs) * time.Second
+			case "auto_rebroadcast_interval_sec":
+				secs, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.RebroadcastInterval = time.Duration(secs) * time.Second
+			case "auto_rebroadcast_max_attempts":
+				if cfg.RebroadcastMaxAttempts, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "auto_broadcast_url":
+				if cfg.AutoBroadcastURL, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "prefetch_pow_schedule":
+				// Format: prefetch_pow_schedule "<cron schedule>" <template file>
+				args := c.RemainingArgs()
+				if len(args) != 2 {
+					return nil, c.ArgErr()
+				}
+				if _, err := parseCronSchedule(args[0]); err != nil {
+					return nil, c.Errf("prefetch_pow_schedule: %v", err)
+				}
+				cfg.PrefetchSchedule, cfg.PrefetchTemplate = args[0], args[1]
+			case "prefetch_ttl_ms":
+				ms, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.PrefetchTTL = time.Duration(ms) * time.Millisecond
+			case "dedup_readonly_commands":
+				// Format: dedup_readonly_commands [<command>...]
+				cfg.DedupCommands = c.RemainingArgs()
+				if len(cfg.DedupCommands) == 0 {
+					cfg.DedupCommands = defaultDedupCommands
+				}
+			case "pow_fallback_chain":
+				// Format: pow_fallback_chain <impl> [<impl>...]
+				if cfg.PoWFallbackChain = c.RemainingArgs(); len(cfg.PoWFallbackChain) == 0 {
+					return nil, c.ArgErr()
+				}
+			case "tofu_pin_mode":
+				if cfg.TOFUPinMode, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "tofu_pin_db":
+				if cfg.TOFUPinDB, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "value_bundle_priority_boost":
+				arg, err := stringArg(c)
+				if err != nil {
+					return nil, err
+				}
+				boost, err := strconv.ParseFloat(arg, 64)
+				if err != nil || boost < 1 {
+					return nil, c.Errf("value_bundle_priority_boost expects a factor of at least 1, got '%s'", arg)
+				}
+				cfg.ValueBundlePriorityBoost = boost
+			case "honor_pow_preference":
+				if cfg.HonorPoWPreference, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "validate_bundle_hash":
+				if cfg.ValidateBundleHash, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "bundle_hash_algorithm":
+				if cfg.BundleHashAlgorithm, err = stringArg(c); err != nil {
+					return nil, err
+				}
+				if _, ok := bundleHashAlgorithms[cfg.BundleHashAlgorithm]; !ok {
+					return nil, c.Errf("unknown bundle hash algorithm '%s', use kerl or curlp81", cfg.BundleHashAlgorithm)
+				}
+			case "allowed_address_types":
+				if cfg.AllowedAddressTypes, err = stringArg(c); err != nil {
+					return nil, err
+				}
+				switch cfg.AllowedAddressTypes {
+				case addressTypeNormal, addressTypeRestricted, addressTypeBoth:
+				default:
+					return nil, c.Errf("invalid address types '%s', use normal, restricted or both", cfg.AllowedAddressTypes)
+				}
+			case "validate_network_magic":
+				if cfg.ValidateNetworkMagic, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "network_magic_byte":
+				arg, err := stringArg(c)
+				if err != nil {
+					return nil, err
+				}
+				magic, err := strconv.ParseUint(arg, 0, 8)
+				if err != nil {
+					return nil, c.Errf("invalid network magic byte '%s'", arg)
+				}
+				cfg.NetworkMagicByte = int(magic)
+			case "ed25519_sign_responses":
+				if cfg.SignResponses, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "ed25519_key_file":
+				if cfg.SigningKeyFile, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "signkey":
+				key, err := stringArg(c)
+				if err != nil {
+					return nil, err
+				}
+				if cfg.SignKey, err = hex.DecodeString(key); err != nil || len(cfg.SignKey) != hmacSignKeySize {
+					return nil, c.Errf("signkey must be a hex encoded %d byte key", hmacSignKeySize)
+				}
+			case "multi_sig_required":
+				if cfg.MultiSigRequired, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "multi_sig_keys":
+				if cfg.MultiSigKeys = c.RemainingArgs(); len(cfg.MultiSigKeys) == 0 {
+					return nil, c.ArgErr()
+				}
+			case "bundle_schema_file":
+				if cfg.BundleSchemaFile, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "redact_message_fragments":
+				if cfg.RedactMessageFragments, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "shutdown_announce_sec":
+				secs, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.ShutdownAnnounce = time.Duration(secs) * time.Second
+			case "shutdown_pow_deadline_sec":
+				secs, err := positiveIntArg(c)
+				if err != nil {
+					return nil, err
+				}
+				cfg.ShutdownPoWDeadline = time.Duration(secs) * time.Second
+			case "output_format":
+				if cfg.OutputFormat, err = stringArg(c); err != nil {
+					return nil, err
+				}
+				if cfg.OutputFormat != outputFormatLegacy && cfg.OutputFormat != outputFormatChrysalis {
+					return nil, c.Errf("unknown output format '%s', use legacy or chrysalis", cfg.OutputFormat)
+				}
+			case "recover_panics":
+				if cfg.RecoverPanics, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "strict_mode":
+				if cfg.StrictMode, err = boolArg(c); err != nil {
+					return nil, err
+				}
+			case "logformat":
+				if cfg.LogFormat, err = stringArg(c); err != nil {
+					return nil, err
+				}
+				if cfg.LogFormat != logFormatText && cfg.LogFormat != logFormatJSON {
+					return nil, c.Errf("unknown log format '%s', use text or json", cfg.LogFormat)
+				}
+			case "log_value_unit":
+				if cfg.LogValueUnit, err = stringArg(c); err != nil {
+					return nil, err
+				}
+				if cfg.LogValueUnit == "I" {
+					cfg.LogValueUnit = "i"
+				}
+				if _, ok := valueUnits[cfg.LogValueUnit]; !ok {
+					return nil, c.Errf("unknown unit '%s', use i, Ki, Mi, Gi, Ti or Pi", cfg.LogValueUnit)
+				}
+			case "log_hash_format":
+				if cfg.LogHashFormat, err = stringArg(c); err != nil {
+					return nil, err
+				}
+				if cfg.LogHashFormat != logHashFormatFull && cfg.LogHashFormat != logHashFormatShort {
+					return nil, c.Errf("unknown log hash format '%s', use full or short", cfg.LogHashFormat)
+				}
+			case "value_bundle_log_file":
+				if cfg.ValueBundleLogFile, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "data_bundle_log_file":
+				if cfg.DataBundleLogFile, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "logfile":
+				if cfg.LogFile, err = stringArg(c); err != nil {
+					return nil, err
+				}
+			case "logkeep":
+				if cfg.LogKeepDays, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+			case "log_hash_truncate_length":
+				if cfg.LogHashLength, err = positiveIntArg(c); err != nil {
+					return nil, err
+				}
+				if cfg.LogHashLength < minLogHashLength {
+					return nil, c.Errf("log_hash_truncate_length must be at least %d, got %d", minLogHashLength, cfg.LogHashLength)
+				}
+			default:
+				return nil, c.Errf("unknown iota option '%s'", c.Val())
+			}
+		}
+	}
+	if err := validateConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// stringArg returns the single argument of the current option.
+func stringArg(c *caddy.Controller) (string, error) {
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return "", c.ArgErr()
+	}
+	return args[0], nil
+}
+
+// parseTagResultHandlers parses the block of the tag_result_handler option, holding a
+// tag prefix and its handler type per line:
+//
+//	tag_result_handler {
+//		<prefix> http
+//		<prefix> kafka <broker>[,<broker>...] <topic>
+//		<prefix> cache
+//	}
+func parseTagResultHandlers(c *caddy.Controller, handlers map[string]TagResultHandler) error {
+	if !c.NextArg() || c.Val() != "{" {
+		return c.ArgErr()
+	}
+	for c.Next() {
+		if c.Val() == "}" {
+			return nil
+		}
+		prefix := c.Val()
+		args := c.RemainingArgs()
+		if len(args) == 0 {
+			return c.ArgErr()
+		}
+		handler := TagResultHandler{Type: args[0]}
+		switch handler.Type {
+		case resultHandlerHTTP, resultHandlerCache:
+			if len(args) != 1 {
+				return c.ArgErr()
+			}
+		case resultHandlerKafka:
+			if len(args) != 3 {
+				return c.Errf("the kafka handler of tag prefix %s expects brokers and a topic", prefix)
+			}
+			handler.KafkaBrokers, handler.KafkaTopic = strings.Split(args[1], ","), args[2]
+		default:
+			return c.Errf("unknown result handler '%s' for tag prefix %s, use http, kafka or cache", handler.Type, prefix)
+		}
+		handlers[prefix] = handler
+	}
+	return c.Err("tag_result_handler block isn't closed")
+}
+
+// parseValue parses a positive amount of the given unit into iotas.
+func parseValue(c *caddy.Controller, option string, amount string, unit string) (int64, error) {
+	u, ok := valueUnits[unit]
+	if !ok {
+		return 0, c.Errf("unknown unit '%s', use i, Ki, Mi, Gi, Ti or Pi", unit)
+	}
+	value, err := strconv.ParseFloat(amount, 64)
+	if err != nil || value <= 0 {
+		return 0, c.Errf("%s expects a positive amount, got '%s'", option, amount)
+	}
+	return int64(units.ConvertUnits(value, u, units.I)), nil
+}
+
+// positiveDurationArg parses the single argument of the current option as a duration > 0, e.g. 30s.
+func positiveDurationArg(c *caddy.Controller) (time.Duration, error) {
+	name := c.Val()
+	arg, err := stringArg(c)
+	if err != nil {
+		return 0, err
+	}
+	d, err := time.ParseDuration(arg)
+	if err != nil || d <= 0 {
+		return 0, c.Errf("%s expects a positive duration like 30s, got '%s'", name, arg)
+	}
+	return d, nil
+}
+
+// positiveIntArg parses the single argument of the current option as an integer > 0.
+func positiveIntArg(c *caddy.Controller) (int, error) {
+	name := c.Val()
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return 0, c.ArgErr()
+	}
+	n, err := strconv.Atoi(args[0])
+	if err != nil || n <= 0 {
+		return 0, c.Errf("%s expects a positive number, got '%s'", name, args[0])
+	}
+	return n, nil
+}
+
+// boolArg parses the single argument of the current option as a boolean.
+func boolArg(c *caddy.Controller) (bool, error) {
+	name := c.Val()
+	args := c.RemainingArgs()
+	if len(args) != 1 {
+		return false, c.ArgErr()
+	}
+	b, err := strconv.ParseBool(args[0])
+	if err != nil {
+		return false, c.Errf("%s expects true or false, got '%s'", name, args[0])
+	}
+	return b, nil
+}
@@ -0,0 +1,895 @@
+package iota
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mholt/caddy"
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+)
+
+// setupTest sets up the interceptor of the input and returns its controller and a func
+// running the registered shutdown callbacks in the order Caddy does.
+func setupTest(t *testing.T, input string) (*caddy.Controller, func(), error) {
+	c := caddy.NewTestController("http", input)
+	var callbacks, finalCallbacks []func() error
+	err := setupInterceptor(c,
+		func(fn func() error) { callbacks = append(callbacks, fn) },
+		func(fn func() error) { finalCallbacks = append(finalCallbacks, fn) })
+	return c, func() {
+		for _, fn := range append(callbacks, finalCallbacks...) {
+			if err := fn(); err != nil {
+				t.Errorf("shutdown callback failed: %v", err)
+			}
+		}
+	}, err
+}
+
+func TestSetup(t *testing.T) {
+	c, shutdown, err := setupTest(t, `iota 14 20 {
+		logfile -
+	}`)
+	defer shutdown()
+	if err != nil {
+		t.Fatalf("expected no errors, got: %v", err)
+	}
+	mids := httpserver.GetConfig(c).Middleware()
+	if len(mids) == 0 {
+		t.Fatal("expected middleware, got 0 instead")
+	}
+	handler := mids[0](httpserver.EmptyNext)
+	interc, ok := handler.(*Interceptor)
+	if !ok {
+		t.Fatalf("expected handler to be type *Interceptor, got: %#v", handler)
+	}
+	if !httpserver.SameNext(interc.Next, httpserver.EmptyNext) {
+		t.Error("'Next' field of handler was not set properly")
+	}
+}
+
+func TestSetupPoWFallbackChain(t *testing.T) {
+	c, shutdown, err := setupTest(t, `iota 14 20 {
+		logfile -
+		pow_fallback_chain syncgo go
+	}`)
+	defer shutdown()
+	if err != nil {
+		t.Fatalf("expected no errors, got: %v", err)
+	}
+	interc := httpserver.GetConfig(c).Middleware()[0](httpserver.EmptyNext).(*Interceptor)
+	if interc.powImplName != "syncgo,go" {
+		t.Errorf("unexpected PoW implementation name %s", interc.powImplName)
+	}
+
+	_, shutdownFailed, err := setupTest(t, `iota 14 20 {
+		logfile -
+		pow_fallback_chain go openCL
+	}`)
+	defer shutdownFailed()
+	if err == nil {
+		t.Error("expected unavailable implementation to fail setup")
+	}
+}
+
+func TestSetupLogPerBlock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iotalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths := make([]string, 2)
+	for i := range paths {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("block%d.log", i))
+		c, shutdown, err := setupTest(t, fmt.Sprintf(`iota 14 20 {
+			logfile %s
+		}`, paths[i]))
+		if err != nil {
+			shutdown()
+			t.Fatalf("expected no errors, got: %v", err)
+		}
+		interc := httpserver.GetConfig(c).Middleware()[0](httpserver.EmptyNext).(*Interceptor)
+		if interc.logger == logger {
+			t.Error("expected the interceptor to log through the logger of its block")
+		}
+		interc.logEntry(levelInfo, "test", logFields{}, "entry of block %d\n", i)
+		shutdown()
+	}
+
+	for i, path := range paths {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(contents), fmt.Sprintf("entry of block %d", i)) || strings.Contains(string(contents), fmt.Sprintf("entry of block %d", 1-i)) {
+			t.Errorf("expected only the entries of block %d in its log, got %q", i, contents)
+		}
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		input     string
+		shouldErr bool
+		check     func(*Config) bool
+	}{
+		{`iota 14 20`, false, func(cfg *Config) bool {
+			return cfg.MaxMWM == 14 && cfg.MaxTxInBundle == 20 && cfg.RateLimit == 0
+		}},
+		{`iota 9 5 {
+			rate_limit 30
+			min_request_interval_ms 500
+			global_rate_limit_rpm 100
+			tag_rate_limit TENANTA 10
+			tag_rate_limit TENANTB 20
+		}`, false, func(cfg *Config) bool {
+			return cfg.MaxMWM == 9 && cfg.MaxTxInBundle == 5 && cfg.RateLimit == 30 && cfg.GlobalRateLimit == 100 && cfg.MinRequestInterval == 500*time.Millisecond &&
+				cfg.TagRateLimits["TENANTA"] == 10 && cfg.TagRateLimits["TENANTB"] == 20
+		}},
+		{`iota 14 20 {
+			partial_bundle_recovery true
+			validate_bundle_hash false
+			head_capabilities true
+		}`, false, func(cfg *Config) bool {
+			return cfg.PartialBundleRecovery && !cfg.ValidateBundleHash && cfg.HeadCapabilities
+		}},
+		{`iota 14 20 {
+			partial_bundle_recovery true
+		}`, true, nil},
+		{`iota 14 20 {
+			partial_bundle_recovery yes
+		}`, true, nil},
+		{`iota 14 20 {
+			body_cache_path /tmp/bodies
+		}`, false, func(cfg *Config) bool {
+			return cfg.BodyCachePath == "/tmp/bodies" && cfg.BodyCacheKeep == defaultBodyCacheKeep
+		}},
+		{`iota 14 20 {
+			body_cache_path /tmp/bodies 10
+		}`, false, func(cfg *Config) bool {
+			return cfg.BodyCacheKeep == 10
+		}},
+		{`iota 14 20 {
+			body_cache_path /tmp/bodies 0
+		}`, true, nil},
+		{`iota 14 20 {
+			inject_coordinator_tips true
+			coordinator_tips ` + strings.Repeat("A", 81) + ` ` + strings.Repeat("B", 81) + `
+		}`, false, func(cfg *Config) bool {
+			return cfg.InjectCoordinatorTips && cfg.CoordinatorTrunk == strings.Repeat("A", 81) &&
+				cfg.CoordinatorBranch == strings.Repeat("B", 81)
+		}},
+		{`iota 14 20 {
+			inject_coordinator_tips true
+		}`, true, nil},
+		{`iota 14 20 {
+			coordinator_tips ABC DEF
+		}`, true, nil},
+		{`iota 14 20 {
+			dedup_readonly_commands
+		}`, false, func(cfg *Config) bool {
+			return len(cfg.DedupCommands) == len(defaultDedupCommands)
+		}},
+		{`iota 14 20 {
+			dedup_readonly_commands getBalances
+		}`, false, func(cfg *Config) bool {
+			return len(cfg.DedupCommands) == 1 && cfg.DedupCommands[0] == "getBalances"
+		}},
+		{`iota 14 20 {
+			bundle_hash_algorithm curlp81
+		}`, false, func(cfg *Config) bool {
+			return cfg.ValidateBundleHash && cfg.BundleHashAlgorithm == "curlp81"
+		}},
+		{`iota 14 20 {
+			validate_bundle_hash false
+		}`, false, func(cfg *Config) bool {
+			return !cfg.ValidateBundleHash
+		}},
+		{`iota 14 20 {
+			bundle_hash_algorithm spongeware
+		}`, true, nil},
+		{`iota 14 20 {
+			rate_limit_status_code 503
+		}`, false, func(cfg *Config) bool {
+			return cfg.RateLimitStatusCode == 503
+		}},
+		{`iota 14 20 {
+			rate_limit_status_code 200
+		}`, true, nil},
+		{`iota 14 20 {
+			max_tip_age_minutes 10
+			tip_age_cache_ttl_ms 500
+		}`, false, func(cfg *Config) bool {
+			return cfg.MaxTipAge == 10*time.Minute && cfg.TipAgeCacheTTL == 500*time.Millisecond
+		}},
+		{`iota 14 20 {
+			max_tip_age_minutes 0
+		}`, true, nil},
+		{`iota 14 20 {
+			validate_network_magic true
+			network_magic_byte 0x42
+		}`, false, func(cfg *Config) bool {
+			return cfg.ValidateNetworkMagic && cfg.NetworkMagicByte == 0x42
+		}},
+		{`iota 14 20 {
+			validate_network_magic true
+		}`, true, nil},
+		{`iota 14 20 {
+			network_magic_byte 0x100
+		}`, true, nil},
+		{`iota 14 20 {
+			log_hash_truncate_length 16
+		}`, false, func(cfg *Config) bool {
+			return cfg.LogHashLength == 16
+		}},
+		{`iota 14 20 {
+			log_hash_truncate_length 7
+		}`, true, nil},
+		{`iota 14 20 {
+			strict_mode true
+		}`, false, func(cfg *Config) bool {
+			return cfg.StrictMode
+		}},
+		{`iota 14 20 {
+			recover_panics true
+		}`, false, func(cfg *Config) bool {
+			return cfg.RecoverPanics
+		}},
+		{`iota 14 20 {
+			ed25519_sign_responses true
+			ed25519_key_file /etc/iotacaddy/ed25519.key
+		}`, false, func(cfg *Config) bool {
+			return cfg.SignResponses && cfg.SigningKeyFile == "/etc/iotacaddy/ed25519.key"
+		}},
+		{`iota 14 20 {
+			ed25519_sign_responses true
+		}`, true, nil},
+		{`iota 14 20 {
+			redact_message_fragments true
+		}`, false, func(cfg *Config) bool {
+			return cfg.RedactMessageFragments
+		}},
+		{`iota 14 20 {
+			auto_rebroadcast_interval_sec 60
+		}`, false, func(cfg *Config) bool {
+			return cfg.RebroadcastInterval == time.Minute && cfg.RebroadcastMaxAttempts == defaultRebroadcastMaxAttempts
+		}},
+		{`iota 14 20 {
+			auto_rebroadcast_interval_sec 60
+			auto_rebroadcast_max_attempts 5
+		}`, false, func(cfg *Config) bool {
+			return cfg.RebroadcastMaxAttempts == 5
+		}},
+		{`iota 14 20 {
+			max_pending_queue_bytes 1048576
+		}`, false, func(cfg *Config) bool {
+			return cfg.MaxPendingQueueBytes == 1048576
+		}},
+		{`iota 14 20 {
+			honor_pow_preference true
+		}`, false, func(cfg *Config) bool {
+			return cfg.HonorPoWPreference
+		}},
+		{`iota 14 20 {
+			static_dir /var/www/wallet
+		}`, false, func(cfg *Config) bool {
+			return cfg.StaticDir == "/var/www/wallet"
+		}},
+		{`iota 14 20 {
+			allowed_address_types restricted
+		}`, false, func(cfg *Config) bool {
+			return cfg.AllowedAddressTypes == "restricted"
+		}},
+		{`iota 14 20 {
+			allowed_address_types tokenized
+		}`, true, nil},
+		{`iota 14 20 {
+			shutdown_announce_sec 10
+		}`, false, func(cfg *Config) bool {
+			return cfg.ShutdownAnnounce == 10*time.Second
+		}},
+		{`iota 14 20 {
+			max_retry_after_sec 30
+		}`, false, func(cfg *Config) bool {
+			return cfg.MaxRetryAfter == 30
+		}},
+		{`iota 14 20 {
+			tofu_pin_mode true
+			tofu_pin_db /tmp/pins.db
+		}`, false, func(cfg *Config) bool {
+			return cfg.TOFUPinMode && cfg.TOFUPinDB == "/tmp/pins.db"
+		}},
+		{`iota 14 20 {
+			output_format chrysalis
+		}`, false, func(cfg *Config) bool {
+			return cfg.OutputFormat == "chrysalis"
+		}},
+		{`iota 14 20 {
+			output_format stardust
+		}`, true, nil},
+		{`iota 14 20 {
+			strict_json true
+		}`, false, func(cfg *Config) bool {
+			return cfg.StrictJSON
+		}},
+		{`iota 14 20 {
+			value_bundle_priority_boost 2.5
+		}`, false, func(cfg *Config) bool {
+			return cfg.ValueBundlePriorityBoost == 2.5
+		}},
+		{`iota 14 20 {
+			value_bundle_priority_boost 0.5
+		}`, true, nil},
+		{`iota 14 20 {
+			max_connections_per_ip 4
+		}`, false, func(cfg *Config) bool {
+			return cfg.MaxConnectionsPerIP == 4
+		}},
+		{`iota 14 20 {
+			max_connections_per_ip 0
+		}`, true, nil},
+		{`iota 14 20 {
+			proxy_depth 2
+		}`, false, func(cfg *Config) bool {
+			return cfg.ProxyDepth == 2
+		}},
+		{`iota 14 20 {
+			trustedproxy 10.0.0.0/8 192.168.0.1
+			trustedproxy fd00::/8
+		}`, false, func(cfg *Config) bool {
+			return len(cfg.TrustedProxies) == 3 && cfg.TrustedProxies[0].String() == "10.0.0.0/8" &&
+				cfg.TrustedProxies[1].String() == "192.168.0.1/32" && cfg.TrustedProxies[2].String() == "fd00::/8"
+		}},
+		{`iota 14 20 {
+			trustedproxy
+		}`, true, nil},
+		{`iota 14 20 {
+			trustedproxy 10.0.0.0/33
+		}`, true, nil},
+		{`iota 14 20 {
+			prefetch_pow_schedule "*/10 8-18 * * 1-5" prefetch.json
+			prefetch_ttl_ms 5000
+		}`, false, func(cfg *Config) bool {
+			return cfg.PrefetchSchedule == "*/10 8-18 * * 1-5" && cfg.PrefetchTemplate == "prefetch.json" && cfg.PrefetchTTL == 5*time.Second
+		}},
+		{`iota 14 20 {
+			prefetch_pow_schedule "61 * * * *" prefetch.json
+		}`, true, nil},
+		{`iota 14 20 {
+			log_hash_format short
+		}`, false, func(cfg *Config) bool {
+			return cfg.LogHashFormat == logHashFormatShort
+		}},
+		{`iota 14 20 {
+			log_hash_format medium
+		}`, true, nil},
+		{`iota 14 20 {
+			log_hash_format short
+			log_hash_truncate_length 16
+		}`, true, nil},
+		{`iota 14 20 {
+			min_tx_per_bundle 2
+		}`, false, func(cfg *Config) bool {
+			return cfg.MinTxInBundle == 2
+		}},
+		{`iota 14 5 {
+			min_tx_per_bundle 6
+		}`, true, nil},
+		{`iota 14 20 {
+			require_confirmed_branch true
+			confirmation_cache_ttl_ms 5000
+		}`, false, func(cfg *Config) bool {
+			return cfg.RequireConfirmedBranch && cfg.ConfirmationCacheTTL == 5*time.Second
+		}},
+		{`iota 14 20 {
+			normalize_tags true
+		}`, false, func(cfg *Config) bool {
+			return cfg.NormalizeTags
+		}},
+		{`iota 14 20 {
+			mwm_validation_mode exact 9
+		}`, false, func(cfg *Config) bool {
+			return cfg.MWMValidationMode == mwmValidationExact && cfg.MWMThreshold == 9
+		}},
+		{`iota 14 20 {
+			mwm_validation_mode min
+		}`, false, func(cfg *Config) bool {
+			return cfg.MWMValidationMode == mwmValidationMin && cfg.mwmThreshold() == 14
+		}},
+		{`iota 14 20 {
+			mwm_validation_mode between 9
+		}`, true, nil},
+		{`iota 14 20 {
+			mwm_validation_mode max 15
+		}`, true, nil},
+		{`iota 14 20 {
+			opencensus_trace true
+			opencensus_exporter jaeger localhost:6831
+		}`, false, func(cfg *Config) bool {
+			return cfg.OpenCensusTrace && cfg.OpenCensusExporter == "jaeger" && cfg.OpenCensusEndpoint == "localhost:6831"
+		}},
+		{`iota 14 20 {
+			opencensus_exporter stackdriver
+		}`, true, nil},
+		{`iota 14 20 {
+			correct_attachment_timestamp true
+		}`, false, func(cfg *Config) bool {
+			return cfg.CorrectAttachmentTimestamp
+		}},
+		{`iota 14 20 {
+			pow_min_hashes_per_sec 1e6
+			pow_degraded_webhook http://127.0.0.1/alert
+		}`, false, func(cfg *Config) bool {
+			return cfg.PoWMinHashesPerSec == 1e6 && cfg.PoWDegradedWebhook == "http://127.0.0.1/alert"
+		}},
+		{`iota 14 20 {
+			pow_min_hashes_per_sec -5
+		}`, true, nil},
+		{`iota 14 20 {
+			include_command_in_response true
+		}`, false, func(cfg *Config) bool {
+			return cfg.IncludeCommandInResponse
+		}},
+		{`iota 14 20 {
+			pow_concurrency 4
+			max_concurrent_pow_per_ip 2
+		}`, false, func(cfg *Config) bool {
+			return cfg.PoWConcurrency == 4 && cfg.MaxConcurrentPoWPerIP == 2
+		}},
+		{`iota 14 20 {
+			max_concurrent_pow_per_ip 0
+		}`, true, nil},
+		{`iota 14 20 {
+			strict_trytes_validation true
+			trytes_alphabet 9ABCDEFGHIJKLMNOPQRSTUVWXYZ
+		}`, false, func(cfg *Config) bool {
+			return cfg.StrictTrytesValidation && cfg.TrytesAlphabet == "9ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+		}},
+		{`iota 14 20 {
+			trytes_alphabet ABC
+		}`, true, nil},
+		{`iota 14 20 {
+			trytes_alphabet AACDEFGHIJKLMNOPQRSTUVWXYZ9
+		}`, true, nil},
+		{`iota 14 20 /var/log/iotacaddy/iota.log`, false, func(cfg *Config) bool {
+			return cfg.LogFile == "/var/log/iotacaddy/iota.log"
+		}},
+		{`iota 14 20 {
+			logfile stdout
+		}`, false, func(cfg *Config) bool {
+			return cfg.LogFile == logFileStdout
+		}},
+		{`iota 14 20 a b`, true, nil},
+		{`iota 14 20 {
+			intercept_store true
+		}`, false, func(cfg *Config) bool {
+			return cfg.InterceptStore
+		}},
+		{`iota 14 20 {
+			workers 4
+		}`, false, func(cfg *Config) bool {
+			return cfg.PoWConcurrency == 4 && cfg.RejectWhenWorkersBusy
+		}},
+		{`iota 14 20 {
+			workers 0
+		}`, true, nil},
+		{`iota 14 20 {
+			rate_limit 30
+			rate_limiter_max_ips 10000
+		}`, false, func(cfg *Config) bool {
+			return cfg.RateLimit == 30 && cfg.RateLimiterMaxIPs == 10000
+		}},
+		{`iota 14 20 {
+			check_balances true
+			balance_check_timeout_ms 2000
+			balance_cache_ttl_ms 60000
+		}`, false, func(cfg *Config) bool {
+			return cfg.CheckBalances && cfg.BalanceCheckTimeout == 2*time.Second && cfg.BalanceCacheTTL == time.Minute
+		}},
+		{`iota 14 20 {
+			worker_restart_delay_ms 250
+		}`, false, func(cfg *Config) bool {
+			return cfg.WorkerRestartDelay == 250*time.Millisecond
+		}},
+		{`iota 14 20 {
+			maxvalue 100 Mi
+		}`, false, func(cfg *Config) bool {
+			return cfg.MaxValue == 100000000
+		}},
+		{`iota 14 20 {
+			maxvalue 1.5 Ki
+		}`, false, func(cfg *Config) bool {
+			return cfg.MaxValue == 1500
+		}},
+		{`iota 14 20 {
+			maxvalue 100 Xi
+		}`, true, nil},
+		{`iota 14 20 {
+			maxvalue -1 Mi
+		}`, true, nil},
+		{`iota 14 20 {
+			max_output_value_miota 2.5
+		}`, false, func(cfg *Config) bool {
+			return cfg.MaxOutputValue == 2500000
+		}},
+		{`iota 14 20 {
+			max_output_value_miota 0
+		}`, true, nil},
+		{`iota 14 20 {
+			max_output_value_miota 1 Mi
+		}`, true, nil},
+		{`iota 14 20 {
+			iri_upstream https://127.0.0.1:14265
+			iota_client_cert_file client.pem
+			iota_client_key_file client.key
+		}`, false, func(cfg *Config) bool {
+			return cfg.IRIUpstream == "https://127.0.0.1:14265" && cfg.IRIClientCertFile == "client.pem" && cfg.IRIClientKeyFile == "client.key"
+		}},
+		{`iota 14 20 {
+			iri_upstream http://127.0.0.1:14265
+			backend_max_idle_conns 20
+			backend_max_conns_per_host 10
+			backend_idle_conn_timeout_sec 30
+		}`, false, func(cfg *Config) bool {
+			return cfg.BackendMaxIdleConns == 20 && cfg.BackendMaxConnsPerHost == 10 && cfg.BackendIdleConnTimeout == 30*time.Second
+		}},
+		{`iota 14 20 {
+			backend_max_conns_per_host 0
+		}`, true, nil},
+		{`iota 14 20 {
+			embed_metadata_field tag
+			metadata_template "{{.ServerID}}"
+		}`, false, func(cfg *Config) bool {
+			return cfg.EmbedMetadataField == metadataFieldTag && cfg.MetadataTemplate == "{{.ServerID}}"
+		}},
+		{`iota 14 20 {
+			embed_metadata_field obsolete_tag
+			metadata_template "{{.ServerID}}"
+		}`, true, nil},
+		{`iota 14 20 {
+			embed_metadata_field message
+			metadata_template "{{.ServerID}}"
+		}`, true, nil},
+		{`iota 14 20 {
+			logformat json
+		}`, false, func(cfg *Config) bool {
+			return cfg.LogFormat == logFormatJSON
+		}},
+		{`iota 14 20 {
+			logformat yaml
+		}`, true, nil},
+		{`iota 14 20 {
+			log_value_unit I
+		}`, false, func(cfg *Config) bool {
+			return cfg.LogValueUnit == "i"
+		}},
+		{`iota 14 20 {
+			log_value_unit Gi
+		}`, false, func(cfg *Config) bool {
+			return cfg.LogValueUnit == "Gi"
+		}},
+		{`iota 14 20 {
+			log_value_unit Mio
+		}`, true, nil},
+		{`iota 14 20 {
+			allow 10.0.0.0/8
+			deny 10.0.0.13
+		}`, false, func(cfg *Config) bool {
+			return len(cfg.AccessRules) == 2 && cfg.AccessRules[0].allow && cfg.AccessRules[0].network.String() == "10.0.0.0/8" &&
+				!cfg.AccessRules[1].allow && cfg.AccessRules[1].network.String() == "10.0.0.13/32"
+		}},
+		{`iota 14 20 {
+			deny 10.0.0.0/33
+		}`, true, nil},
+		{`iota 14 20 {
+			light_node_mode true
+		}`, false, func(cfg *Config) bool {
+			return cfg.LightNodeMode
+		}},
+		{`iota 14 20 {
+			alertwebhook https://hooks.example.com/alert 50 Mi
+		}`, false, func(cfg *Config) bool {
+			return cfg.AlertWebhook == "https://hooks.example.com/alert" && cfg.AlertWebhookThreshold == 50000000
+		}},
+		{`iota 14 20 {
+			alertwebhook https://hooks.example.com/alert 50
+		}`, true, nil},
+		{`iota 14 20 {
+			alertwebhook https://hooks.example.com/alert 50 Xi
+		}`, true, nil},
+		{`iota 14 20 {
+			result_backup_dir /var/lib/iotacaddy/results
+			result_backup_ttl_hours 48
+		}`, false, func(cfg *Config) bool {
+			return cfg.ResultBackupDir == "/var/lib/iotacaddy/results" && cfg.ResultBackupTTL == 48*time.Hour
+		}},
+		{`iota 14 20 {
+			tipcache 5s
+		}`, false, func(cfg *Config) bool {
+			return cfg.TipCacheTTL == 5*time.Second
+		}},
+		{`iota 14 20 {
+			tipcache soon
+		}`, true, nil},
+		{`iota 14 20 {
+			powtimeout 30s
+		}`, false, func(cfg *Config) bool {
+			return cfg.PoWTimeout == 30*time.Second
+		}},
+		{`iota 14 20 {
+			powtimeout 0s
+		}`, true, nil},
+		{`iota 14 20 {
+			powtimeout 30
+		}`, true, nil},
+		{`iota 14 20 {
+			cancel_pow_on_disconnect true
+		}`, false, func(cfg *Config) bool {
+			return cfg.CancelPoWOnDisconnect
+		}},
+		{`iota 14 20 {
+			cors https://wallet.example.com
+		}`, false, func(cfg *Config) bool {
+			return cfg.CORSOrigin == "https://wallet.example.com"
+		}},
+		{`iota 14 20 {
+			cors
+		}`, true, nil},
+		{`iota 14 20 {
+			metrics /metrics
+		}`, false, func(cfg *Config) bool {
+			return cfg.MetricsPath == "/metrics"
+		}},
+		{`iota 14 20 {
+			metrics metrics
+		}`, true, nil},
+		{`iota 14 20 {
+			shutdown_pow_deadline_sec 60
+		}`, false, func(cfg *Config) bool {
+			return cfg.ShutdownPoWDeadline == time.Minute
+		}},
+		{`iota 14 20 {
+			shutdown_pow_deadline_sec 0
+		}`, true, nil},
+		{`iota 14 20 {
+			powcache 64 10s
+		}`, false, func(cfg *Config) bool {
+			return cfg.PoWCacheSize == 64 && cfg.PoWCacheTTL == 10*time.Second
+		}},
+		{`iota 14 20 {
+			powcache 64
+		}`, true, nil},
+		{`iota 14 20 {
+			powcache 0 10s
+		}`, true, nil},
+		{`iota 14 20 {
+			powcache 64 soon
+		}`, true, nil},
+		{`iota 14 20 {
+			value_bundle_log_file value.log
+			data_bundle_log_file data.log
+		}`, false, func(cfg *Config) bool {
+			return cfg.ValueBundleLogFile == "value.log" && cfg.DataBundleLogFile == "data.log"
+		}},
+		{`iota 14 20 {
+			value_bundle_log_file
+		}`, true, nil},
+		{`iota 14 20 {
+			remotepow https://powsrv.io/pow
+			remotepow_timeout 5s
+		}`, false, func(cfg *Config) bool {
+			return cfg.RemotePoWURL == "https://powsrv.io/pow" && cfg.RemotePoWTimeout == 5*time.Second
+		}},
+		{`iota 14 20 {
+			remotepow_timeout 0s
+		}`, true, nil},
+		{`iota 14 20 {
+			client_requested_timeout true
+		}`, false, func(cfg *Config) bool {
+			return cfg.ClientRequestedTimeout
+		}},
+		{`iota 14 20 {
+			client_requested_timeout sometimes
+		}`, true, nil},
+		{`iota 14 20 {
+			adminpath /_iotacaddy/status
+		}`, false, func(cfg *Config) bool {
+			return cfg.AdminPath == "/_iotacaddy/status"
+		}},
+		{`iota 14 20 {
+			adminpath status
+		}`, true, nil},
+		{`iota 14 20 {
+			cloudwatch_log_group iotacaddy
+			cloudwatch_log_stream pow
+			aws_region eu-central-1
+			cloudwatch_flush_interval_sec 10
+		}`, false, func(cfg *Config) bool {
+			return cfg.CloudWatchLogGroup == "iotacaddy" && cfg.CloudWatchLogStream == "pow" && cfg.AWSRegion == "eu-central-1" && cfg.CloudWatchFlushInterval == 10*time.Second
+		}},
+		{`iota 14 20 {
+			cloudwatch_flush_interval_sec 0
+		}`, true, nil},
+		{`iota 14 20 {
+			result_backup_dir /tmp/results
+			result_backup_compress true
+		}`, false, func(cfg *Config) bool {
+			return cfg.ResultBackupCompress
+		}},
+		{`iota 14 20 {
+			statsevery 100
+		}`, false, func(cfg *Config) bool {
+			return cfg.StatsEvery == 100
+		}},
+		{`iota 14 20 {
+			statsevery 0
+		}`, true, nil},
+		{`iota 14 20 {
+			tag_result_handler {
+				TENANTA http
+				TENANTB kafka 10.0.0.1:9092,10.0.0.2:9092 pow-results
+				TENANTC cache
+			}
+			rate_limit 30
+		}`, false, func(cfg *Config) bool {
+			kafka := cfg.TagResultHandlers["TENANTB"]
+			return len(cfg.TagResultHandlers) == 3 && cfg.TagResultHandlers["TENANTA"].Type == "http" && cfg.TagResultHandlers["TENANTC"].Type == "cache" &&
+				kafka.Type == "kafka" && len(kafka.KafkaBrokers) == 2 && kafka.KafkaBrokers[1] == "10.0.0.2:9092" && kafka.KafkaTopic == "pow-results" && cfg.RateLimit == 30
+		}},
+		{`iota 14 20 {
+			tag_result_handler {
+				TENANTA sqs
+			}
+		}`, true, nil},
+		{`iota 14 20 {
+			tag_result_handler {
+				TENANTB kafka 10.0.0.1:9092
+			}
+		}`, true, nil},
+		{`iota 14 20 {
+			tag_result_handler TENANTA http
+		}`, true, nil},
+		{`iota 14 20 {
+			denytag SPAMTAG99999999999999999999
+			denytag SPAMBOT99999999999999999999
+			allowtag MYAPP9999999999999999999999
+		}`, false, func(cfg *Config) bool {
+			return len(cfg.DeniedTags) == 2 && cfg.DeniedTags[1] == "SPAMBOT99999999999999999999" &&
+				len(cfg.AllowedTags) == 1 && cfg.AllowedTags[0] == "MYAPP9999999999999999999999"
+		}},
+		{`iota 14 20 {
+			denytag SPAMTAG
+		}`, true, nil},
+		{`iota 14 20 {
+			allowtag myapp9999999999999999999999
+		}`, true, nil},
+		{`iota 14 20 {
+			min_confirmations_before_reattach 2
+		}`, false, func(cfg *Config) bool {
+			return cfg.MinConfirmationsBeforeReattach == 2
+		}},
+		{`iota 14 20 {
+			min_confirmations_before_reattach 0
+		}`, true, nil},
+		{`iota 14 20 {
+			logkeep 7
+		}`, false, func(cfg *Config) bool {
+			return cfg.LogKeepDays == 7
+		}},
+		{`iota 14 20 {
+			logkeep 0
+		}`, true, nil},
+		{`iota 14 20 {
+			multi_sig_required 2
+			multi_sig_keys alice bob carol
+		}`, false, func(cfg *Config) bool {
+			return cfg.MultiSigRequired == 2 && len(cfg.MultiSigKeys) == 3 && cfg.MultiSigKeys[2] == "carol"
+		}},
+		{`iota 14 20 {
+			multi_sig_keys
+		}`, true, nil},
+		{`iota 14 20 {
+			mwm 9 12
+		}`, false, func(cfg *Config) bool {
+			return cfg.MinMWM == 9 && cfg.MaxMWM == 12
+		}},
+		{`iota 14 20 {
+			mwm 12 9
+		}`, true, nil},
+		{`iota 14 20 {
+			min_mwm_value_bundle 14
+		}`, false, func(cfg *Config) bool {
+			return cfg.MinMWMValueBundle == 14
+		}},
+		{`iota 9 20 {
+			min_mwm_value_bundle 14
+		}`, true, nil},
+		{`iota 14 20 {
+			mwm 0 14
+		}`, true, nil},
+		{`iota 14 20 {
+			mwm 9
+		}`, true, nil},
+		{`iota 14 20 {
+			max_tag_stats_entries 500
+		}`, false, func(cfg *Config) bool {
+			return cfg.MaxTagStatsEntries == 500
+		}},
+		{`iota 14 20 {
+			max_tag_stats_entries 0
+		}`, true, nil},
+		{`iota 14 20 {
+			dryrun true
+		}`, false, func(cfg *Config) bool {
+			return cfg.DryRun
+		}},
+		{`iota 14 20 {
+			auto_broadcast_url http://10.0.0.2:14265
+		}`, false, func(cfg *Config) bool {
+			return cfg.AutoBroadcastURL == "http://10.0.0.2:14265"
+		}},
+		{`iota 14 20 {
+			admin_allowed_ips 127.0.0.1 10.1.0.0/16
+		}`, false, func(cfg *Config) bool {
+			return len(cfg.AdminAllowedIPs) == 2 && cfg.AdminAllowedIPs[0].String() == "127.0.0.1/32"
+		}},
+		{`iota 14 20 {
+			admin_allowed_ips
+		}`, true, nil},
+		{`iota 14 20 {
+			signkey 000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f
+		}`, false, func(cfg *Config) bool {
+			return len(cfg.SignKey) == 32 && cfg.SignKey[31] == 0x1f
+		}},
+		{`iota 14 20 {
+			signkey 0001020304
+		}`, true, nil},
+		{`iota 14 20 {
+			signkey nonsense
+		}`, true, nil},
+		{`iota 14 20 {
+			bundle_schema_file /etc/iotacaddy/bundle.schema.json
+		}`, false, func(cfg *Config) bool {
+			return cfg.BundleSchemaFile == "/etc/iotacaddy/bundle.schema.json"
+		}},
+		{`iota 14 20 {
+			reserve_input_addresses true
+		}`, false, func(cfg *Config) bool {
+			return cfg.ReserveInputAddresses
+		}},
+		{`iota 14`, true, nil},
+		{`iota 14 20 {
+			tag_rate_limit TENANTA
+		}`, true, nil},
+		{`iota 14 20 {
+			tag_rate_limit TENANTA -1
+		}`, true, nil},
+		{`iota 14 20 {
+			rate_limit abc
+		}`, true, nil},
+		{`iota 14 20 {
+			unknown 1
+		}`, true, nil},
+	}
+	for i, test := range tests {
+		cfg, err := parseConfig(caddy.NewTestController("http", test.input))
+		if test.shouldErr {
+			if err == nil {
+				t.Errorf("test %d: expected error but got none", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: expected no error, got: %v", i, err)
+			continue
+		}
+		if !test.check(cfg) {
+			t.Errorf("test %d: unexpected config %+v", i, cfg)
+		}
+	}
+}
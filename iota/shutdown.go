@@ -0,0 +1,69 @@
+package iota
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const headerShutdownIn = "X-IOTA-Shutdown-In"
+
+const defaultShutdownPoWDeadline = 30 * time.Second
+
+// drainForShutdown announces the shutdown on all responses and keeps serving
+// requests for the configured grace period before returning.
+func (interc *Interceptor) drainForShutdown() error {
+	grace := interc.Config.ShutdownAnnounce
+	interc.logEntry(levelInfo, "shutdown_announced", logFields{}, "shutting down in %v, announcing it to clients\n", grace)
+	atomic.StoreInt64(&interc.shutdownAt, time.Now().Add(grace).UnixNano())
+	time.Sleep(grace)
+	return nil
+}
+
+// setShutdownHeader sets the remaining seconds until the shutdown if one is announced.
+func (interc *Interceptor) setShutdownHeader(w http.ResponseWriter) {
+	at := atomic.LoadInt64(&interc.shutdownAt)
+	if at == 0 {
+		return
+	}
+	w.Header().Set(headerShutdownIn, retryAfterSeconds(time.Until(time.Unix(0, at))))
+}
+
+// beginPoW registers a PoW job and reports whether it may run.
+// Each successful beginPoW must be followed by an endPoW.
+func (interc *Interceptor) beginPoW() bool {
+	interc.powMu.Lock()
+	defer interc.powMu.Unlock()
+	if interc.powStopped {
+		return false
+	}
+	interc.powJobs.Add(1)
+	return true
+}
+
+func (interc *Interceptor) endPoW() {
+	interc.powJobs.Done()
+}
+
+// stopPoW stops accepting new PoW jobs, rejects the queued ones and waits for the running
+// ones to complete, at most for the configured deadline.
+func (interc *Interceptor) stopPoW() error {
+	interc.powMu.Lock()
+	interc.powStopped = true
+	interc.powMu.Unlock()
+	interc.scheduler.close()
+
+	done := make(chan struct{})
+	go func() {
+		interc.powJobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(interc.Config.ShutdownPoWDeadline):
+		return errors.Errorf("running PoWs didn't complete within %v", interc.Config.ShutdownPoWDeadline)
+	}
+}
@@ -0,0 +1,162 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+func TestShutdownAnnounce(t *testing.T) {
+	cfg := newConfig()
+	cfg.ShutdownAnnounce = 2 * time.Second
+	interc, _ := newTestInterceptor(t, cfg)
+
+	w := httptest.NewRecorder()
+	interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+	if w.Header().Get(headerShutdownIn) != "" {
+		t.Fatal("expected no shutdown announcement before the shutdown")
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		interc.drainForShutdown()
+		close(drained)
+	}()
+	for atomic.LoadInt64(&interc.shutdownAt) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	for _, r := range []*http.Request{
+		attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)),
+		// forwarded requests are announced too
+		httptest.NewRequest(http.MethodGet, "/", nil),
+	} {
+		w := httptest.NewRecorder()
+		if status, err := interc.ServeHTTP(w, r); status != http.StatusOK {
+			t.Fatalf("expected requests to be served while draining, got %d: %v", status, err)
+		}
+		secs, err := strconv.Atoi(w.Header().Get(headerShutdownIn))
+		if err != nil || secs <= 0 || secs > 2 {
+			t.Errorf("expected a positive countdown of at most 2 seconds, got %q", w.Header().Get(headerShutdownIn))
+		}
+	}
+
+	select {
+	case <-drained:
+		t.Fatal("expected the drain to last for the grace period")
+	default:
+	}
+	<-drained
+}
+
+func TestStopPoW(t *testing.T) {
+	started := make(chan struct{})
+	slowPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		return consts.NullNonceTrytes, nil
+	}
+	cfg := newConfig()
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.powFn = slowPoW
+
+	served := make(chan int, 1)
+	go func() {
+		status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+		served <- status
+	}()
+	<-started
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- interc.stopPoW() }()
+	// wait until new PoWs are rejected
+	for {
+		interc.powMu.Lock()
+		s := interc.powStopped
+		interc.powMu.Unlock()
+		if s {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+	if status != http.StatusServiceUnavailable || err != ErrServerShuttingDown {
+		t.Fatalf("expected requests after the shutdown to be rejected, got %d: %v", status, err)
+	}
+	select {
+	case <-stopped:
+		t.Fatal("expected the shutdown to wait for the running PoW")
+	default:
+	}
+	if status := <-served; status != http.StatusOK {
+		t.Errorf("expected the running PoW to complete, got %d", status)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("expected the shutdown to succeed, got %v", err)
+	}
+}
+
+func TestStopPoWDeadline(t *testing.T) {
+	cfg := newConfig()
+	cfg.ShutdownPoWDeadline = 10 * time.Millisecond
+	interc, _ := newTestInterceptor(t, cfg)
+	if !interc.beginPoW() {
+		t.Fatal("expected the PoW to be accepted")
+	}
+	defer interc.endPoW()
+	if err := interc.stopPoW(); err == nil {
+		t.Error("expected an error when the PoW doesn't complete within the deadline")
+	}
+}
+
+func TestStopPoWRejectsQueued(t *testing.T) {
+	started, gate := make(chan struct{}), make(chan struct{})
+	blockingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		close(started)
+		<-gate
+		return consts.NullNonceTrytes, nil
+	}
+	interc, _ := newTestInterceptor(t, newConfig())
+	interc.powFn = blockingPoW
+
+	attach := func(remoteAddr string) chan int {
+		served := make(chan int, 1)
+		go func() {
+			status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, remoteAddr, 1, txTrytes(t, "TEST", 0)))
+			served <- status
+		}()
+		return served
+	}
+	running := attach("1.1.1.1:1234")
+	<-started
+	queued := attach("2.2.2.2:1234")
+	for interc.scheduler.queued() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- interc.stopPoW() }()
+	select {
+	case status := <-queued:
+		if status != http.StatusServiceUnavailable {
+			t.Errorf("expected the queued PoW to be rejected with 503, got %d", status)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the queued PoW to leave the queue on shutdown")
+	}
+
+	close(gate)
+	if status := <-running; status != http.StatusOK {
+		t.Errorf("expected the running PoW to complete, got %d", status)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("expected the shutdown to succeed, got %v", err)
+	}
+}
@@ -0,0 +1,72 @@
+package iota
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ed25519"
+)
+
+const (
+	headerSignature     = "X-IOTA-Ed25519-Signature"
+	headerHMACSignature = "X-IotaCaddy-Signature"
+)
+
+// length of the signkey in bytes
+const hmacSignKeySize = 32
+
+// signatureEncoding is used for the signature header and the published public key.
+var signatureEncoding = base64.RawURLEncoding
+
+// loadSigningKey reads an Ed25519 private key from the given file. The file contains
+// either the base64 encoded 32 byte seed or the 64 byte private key.
+func loadSigningKey(file string) (ed25519.PrivateKey, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read Ed25519 key file")
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return nil, errors.Wrap(err, "Ed25519 key file must contain a base64 encoded key")
+	}
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	}
+	return nil, errors.Errorf("Ed25519 key must be %d or %d bytes long, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+}
+
+// signResponse sets the signatures over the given response body if response signing is enabled.
+// It has to be called before the body is written for the headers to be sent.
+func (interc *Interceptor) signResponse(w http.ResponseWriter, body []byte) {
+	if interc.signingKey != nil {
+		w.Header().Set(headerSignature, signatureEncoding.EncodeToString(ed25519.Sign(interc.signingKey, body)))
+	}
+	if interc.Config.SignKey != nil {
+		w.Header().Set(headerHMACSignature, hex.EncodeToString(hmacSHA256(interc.Config.SignKey, body)))
+	}
+}
+
+// VerifyPoWSignature reports whether the signature of the X-IotaCaddy-Signature header
+// is the hex encoded HMAC-SHA256 of the attachToTangle response body with the given key.
+func VerifyPoWSignature(responseBody []byte, key []byte, signature string) bool {
+	sig, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(sig, hmacSHA256(key, responseBody))
+}
+
+func hmacSHA256(key, msg []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(msg)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,111 @@
+package iota
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestSignResponses(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	dir, err := ioutil.TempDir("", "iota-signing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "ed25519.key")
+	if err := ioutil.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString(seed)+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newConfig()
+	cfg.SignResponses = true
+	cfg.SigningKeyFile = keyFile
+	interc, _ := newTestInterceptor(t, cfg)
+
+	// fetch the public key
+	w := httptest.NewRecorder()
+	if status, err := interc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, pubKeyPath, nil)); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	keyRes := &pubKeyRes{}
+	if err := json.Unmarshal(w.Body.Bytes(), keyRes); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	pubKey, err := signatureEncoding.DecodeString(keyRes.PublicKey)
+	if err != nil || len(pubKey) != ed25519.PublicKeySize {
+		t.Fatalf("invalid public key %q: %v", keyRes.PublicKey, err)
+	}
+
+	w = httptest.NewRecorder()
+	if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	sig, err := signatureEncoding.DecodeString(w.Header().Get(headerSignature))
+	if err != nil {
+		t.Fatalf("invalid signature header: %v", err)
+	}
+	body := w.Body.Bytes()
+	if !ed25519.Verify(pubKey, body, sig) {
+		t.Fatal("expected the signature to be valid for the response body")
+	}
+	tampered := append([]byte(nil), body...)
+	tampered[len(tampered)-2]++
+	if ed25519.Verify(pubKey, tampered, sig) {
+		t.Error("expected the signature to be invalid for a tampered body")
+	}
+}
+
+func TestHMACSignResponses(t *testing.T) {
+	key := make([]byte, hmacSignKeySize)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	cfg := newConfig()
+	cfg.SignKey = key
+	interc, _ := newTestInterceptor(t, cfg)
+
+	w := httptest.NewRecorder()
+	if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	sig := w.Header().Get(headerHMACSignature)
+	body := w.Body.Bytes()
+	if !VerifyPoWSignature(body, key, sig) {
+		t.Fatalf("expected the signature %q to be valid for the response body", sig)
+	}
+	tampered := append([]byte(nil), body...)
+	tampered[len(tampered)-2]++
+	if VerifyPoWSignature(tampered, key, sig) {
+		t.Error("expected the signature to be invalid for a tampered body")
+	}
+	otherKey := append([]byte(nil), key...)
+	otherKey[0]++
+	if VerifyPoWSignature(body, otherKey, sig) {
+		t.Error("expected the signature to be invalid for another key")
+	}
+	if VerifyPoWSignature(body, key, "nonsense") {
+		t.Error("expected a non hex signature to be invalid")
+	}
+	if w.Header().Get(headerSignature) != "" {
+		t.Error("expected no Ed25519 signature without its key")
+	}
+}
+
+func TestPubKeyEndpointWithoutSigning(t *testing.T) {
+	interc, next := newTestInterceptor(t, newConfig())
+	interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, pubKeyPath, nil))
+	if next.calls != 1 {
+		t.Error("expected the request to be forwarded if signing is disabled")
+	}
+}
@@ -0,0 +1,61 @@
+package iota
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+
+	"github.com/pkg/errors"
+)
+
+const staticIndexFile = "index.html"
+
+// staticFiles serves the files of a directory, e.g. a web wallet, next to the IRI API.
+type staticFiles struct {
+	root       http.Dir
+	fileServer http.Handler
+}
+
+func newStaticFiles(dir string) (*staticFiles, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't open static directory")
+	}
+	if !info.IsDir() {
+		return nil, errors.Errorf("static directory %s is not a directory", dir)
+	}
+	return &staticFiles{root: http.Dir(dir), fileServer: http.FileServer(http.Dir(dir))}, nil
+}
+
+// serve serves the requested file and reports whether it existed. Directories are
+// only served if they contain an index.html, so no directory listings are exposed.
+func (s *staticFiles) serve(w http.ResponseWriter, r *http.Request) bool {
+	name := path.Clean("/" + r.URL.Path)
+	info, ok := s.stat(name)
+	if !ok {
+		return false
+	}
+	if info.IsDir() {
+		if info, ok = s.stat(path.Join(name, staticIndexFile)); !ok || info.IsDir() {
+			return false
+		}
+	}
+	// http.ServeContent takes care of Last-Modified but only evaluates If-None-Match against a set ETag
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	s.fileServer.ServeHTTP(w, r)
+	return true
+}
+
+func (s *staticFiles) stat(name string) (os.FileInfo, bool) {
+	f, err := s.root.Open(name)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, false
+	}
+	return info, true
+}
@@ -0,0 +1,108 @@
+package iota
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticDirHeadCapabilities(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iota-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := ioutil.WriteFile(filepath.Join(dir, "wallet.js"), []byte("wallet()"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newConfig()
+	cfg.StaticDir = dir
+	cfg.HeadCapabilities = true
+	interc, _ := newTestInterceptor(t, cfg)
+
+	w := httptest.NewRecorder()
+	interc.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/wallet.js", nil))
+	if w.Code != http.StatusOK || w.Header().Get(headerMaxMWM) != "" || w.Header().Get("ETag") == "" {
+		t.Errorf("expected HEAD of a static file to be served by the file server, got %d: %v", w.Code, w.Header())
+	}
+
+	w = httptest.NewRecorder()
+	interc.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/", nil))
+	if w.Code != http.StatusOK || w.Header().Get(headerMaxMWM) != "14" {
+		t.Errorf("expected HEAD of the API to be answered with the capabilities, got %d: %v", w.Code, w.Header())
+	}
+}
+
+func TestStaticDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iota-static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	const page = "<html><body>wallet</body></html>"
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte(page), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "assets"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newConfig()
+	cfg.StaticDir = dir
+	interc, next := newTestInterceptor(t, cfg)
+
+	for _, p := range []string{"/", "/index.html"} {
+		w := httptest.NewRecorder()
+		interc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+		if p == "/index.html" {
+			// the file server redirects to the directory
+			if w.Code != http.StatusMovedPermanently {
+				t.Errorf("expected %s to redirect, got %d", p, w.Code)
+			}
+			continue
+		}
+		if w.Code != http.StatusOK || w.Body.String() != page {
+			t.Fatalf("expected %s to serve the index, got %d: %s", p, w.Code, w.Body.String())
+		}
+		if w.Header().Get("ETag") == "" || w.Header().Get("Last-Modified") == "" {
+			t.Errorf("expected ETag and Last-Modified to be set, got %v", w.Header())
+		}
+
+		r := httptest.NewRequest(http.MethodGet, p, nil)
+		r.Header.Set("If-None-Match", w.Header().Get("ETag"))
+		cached := httptest.NewRecorder()
+		interc.ServeHTTP(cached, r)
+		if cached.Code != http.StatusNotModified {
+			t.Errorf("expected 304 for a matching ETag, got %d", cached.Code)
+		}
+
+		r = httptest.NewRequest(http.MethodGet, p, nil)
+		r.Header.Set("If-Modified-Since", w.Header().Get("Last-Modified"))
+		cached = httptest.NewRecorder()
+		interc.ServeHTTP(cached, r)
+		if cached.Code != http.StatusNotModified {
+			t.Errorf("expected 304 for an unmodified file, got %d", cached.Code)
+		}
+	}
+	if next.calls != 0 {
+		t.Errorf("expected static files not to be forwarded, got %d calls", next.calls)
+	}
+
+	// missing files, directories without an index and API calls go to IRI
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/missing.js", nil),
+		httptest.NewRequest(http.MethodGet, "/assets/", nil),
+		httptest.NewRequest(http.MethodGet, "/../../etc/passwd", nil),
+		httptest.NewRequest(http.MethodPost, "/", nil),
+	} {
+		before := next.calls
+		interc.ServeHTTP(httptest.NewRecorder(), r)
+		if next.calls != before+1 {
+			t.Errorf("expected %s %s to be forwarded", r.Method, r.URL.Path)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package iota
+
+import (
+	"net/http"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+const storeTransactionsCommand = "storeTransactions"
+
+// storedBundle holds the transactions of a storeTransactions call belonging to the same bundle.
+type storedBundle struct {
+	transactions []transaction.Transaction
+	trytes       []trinary.Trytes
+}
+
+// serveStoreTransactions runs the attachToTangle validations on the transactions of a
+// storeTransactions call, bundle by bundle, and only forwards the call to IRI if all pass.
+func (interc *Interceptor) serveStoreTransactions(w http.ResponseWriter, r *http.Request, txTrytes []trinary.Trytes, ip string) (int, error) {
+	fields := logFields{RemoteAddr: ip}
+	interc.logEntry(levelInfo, "store_request", fields, "new storeTransactions request from %s\n", ip)
+	if len(txTrytes) == 0 {
+		return interc.Next.ServeHTTP(w, r)
+	}
+	if len(txTrytes) > interc.Config.MaxTxInBundle {
+		interc.logEntry(levelWarn, "too_many_txs", fields, "canceling request as it exceeds the txs per bundle limit (%d>%d)\n", len(txTrytes), interc.Config.MaxTxInBundle)
+		return http.StatusBadRequest, errors.Wrapf(ErrTxBundleLimitExceeded, "max allowed is %d", interc.Config.MaxTxInBundle)
+	}
+
+	var hashes []trinary.Hash
+	bundles := map[trinary.Hash]*storedBundle{}
+	for i, trytes := range txTrytes {
+		if interc.alphabet != nil {
+			if err := interc.alphabet.check(trytes); err != nil {
+				interc.logEntry(levelWarn, "invalid_trytes", fields, "rejecting request: %v\n", err)
+				return http.StatusBadRequest, errors.Wrapf(err, "transaction at index %d", i)
+			}
+		}
+		tx, err := transaction.AsTransactionObject(trytes)
+		if err != nil {
+			return http.StatusBadRequest, ErrBuildingTx
+		}
+		b, has := bundles[tx.Bundle]
+		if !has {
+			b = &storedBundle{}
+			bundles[tx.Bundle] = b
+			hashes = append(hashes, tx.Bundle)
+		}
+		b.transactions = append(b.transactions, *tx)
+		b.trytes = append(b.trytes, trytes)
+	}
+
+	for _, hash := range hashes {
+		b := bundles[hash]
+		fields.BundleHash, fields.TxCount = hash, len(b.transactions)
+		interc.logEntry(levelInfo, "store_bundle", fields, "storing bundle: %s with %d txs\n", interc.logHash(hash), len(b.transactions))
+		if status, err := interc.validateBundle(b.transactions, b.trytes); err != nil {
+			interc.logEntry(levelWarn, "invalid_bundle", fields, "rejecting bundle: %v\n", err)
+			return status, err
+		}
+	}
+	return interc.Next.ServeHTTP(w, r)
+}
@@ -0,0 +1,62 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func storeRequest(t *testing.T, trytes ...trinary.Trytes) *http.Request {
+	body, err := json.Marshal(&AttachToTangleReq{Command: storeTransactionsCommand, Trytes: trytes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+	r.RemoteAddr = "1.1.1.1:1234"
+	return r
+}
+
+func TestInterceptStore(t *testing.T) {
+	valid := bundleTrytes(t, "kerl", testTx("TEST", 0), testTx("TEST", 0))
+	// carries the null bundle hash instead of the computed one
+	tx := testTx("TEST", 0)
+	invalid := transaction.MustTransactionToTrytes(&tx)
+
+	cfg := newConfig()
+	cfg.InterceptStore = true
+	interc, next := newTestInterceptor(t, cfg)
+
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), storeRequest(t, valid...)); status != http.StatusOK || err != nil {
+		t.Errorf("expected a valid bundle to be stored, got %d: %v", status, err)
+	}
+	if next.calls != 1 {
+		t.Fatalf("expected the valid store request to be forwarded, got %d calls", next.calls)
+	}
+	var forwarded AttachToTangleReq
+	if err := json.Unmarshal(next.body, &forwarded); err != nil || len(forwarded.Trytes) != len(valid) {
+		t.Errorf("expected the request body to be forwarded unchanged, got %s", next.body)
+	}
+
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), storeRequest(t, invalid))
+	if status != http.StatusUnprocessableEntity || errors.Cause(err) != ErrInvalidBundle {
+		t.Errorf("expected a bundle with an invalid hash to be rejected, got %d: %v", status, err)
+	}
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), storeRequest(t, "CORRUPT")); status != http.StatusBadRequest || err != ErrBuildingTx {
+		t.Errorf("expected corrupt trytes to be rejected, got %d: %v", status, err)
+	}
+	if next.calls != 1 {
+		t.Errorf("expected invalid store requests not to be forwarded, got %d calls", next.calls)
+	}
+
+	cfg.InterceptStore = false
+	interc, next = newTestInterceptor(t, cfg)
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), storeRequest(t, invalid)); status != http.StatusOK || next.calls != 1 {
+		t.Errorf("expected store requests to be forwarded without intercept_store, got %d: %v", status, err)
+	}
+}
@@ -0,0 +1,201 @@
+package iota
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/golang-lru/simplelru"
+	"github.com/segmentio/kafka-go"
+)
+
+// types of the tag_result_handler entries
+const (
+	resultHandlerHTTP  = "http"
+	resultHandlerKafka = "kafka"
+	resultHandlerCache = "cache"
+)
+
+// how long a single result may take to be written to Kafka
+const kafkaWriteTimeout = 5 * time.Second
+
+// size and TTL of the results kept for clients to fetch from resultsPath
+const (
+	resultCacheSize = 1000
+	resultCacheTTL  = time.Hour
+)
+
+// path prefix the results of the cache type are served on, followed by the job ID
+const resultsPath = "/iota/results/"
+
+const headerResultJobID = "X-IOTA-Job-ID"
+
+// TagResultHandler configures how the PoW results of bundles with a tag prefix are delivered.
+type TagResultHandler struct {
+	// http, kafka or cache
+	Type string
+	// brokers and topic of the kafka type
+	KafkaBrokers []string
+	KafkaTopic   string
+}
+
+type jobAcceptedRes struct {
+	JobID string `json:"job_id"`
+}
+
+// resultSink delivers the encoded PoW result of the job with the given ID.
+type resultSink interface {
+	deliver(jobID string, resBytes []byte) error
+}
+
+// kafkaWriter is the subset of *kafka.Writer used by the Kafka sink.
+type kafkaWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// kafkaSink writes the results to a Kafka topic, keyed by the job ID.
+type kafkaSink struct {
+	writer kafkaWriter
+}
+
+func (s *kafkaSink) deliver(jobID string, resBytes []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kafkaWriteTimeout)
+	defer cancel()
+	return s.writer.WriteMessages(ctx, kafka.Message{Key: []byte(jobID), Value: resBytes})
+}
+
+type resultCacheEntry struct {
+	resBytes []byte
+	added    time.Time
+}
+
+// resultCache keeps the results for clients to fetch from resultsPath within the TTL.
+type resultCache struct {
+	mu      sync.Mutex
+	entries *simplelru.LRU
+}
+
+func newResultCache() *resultCache {
+	// only fails for non positive sizes
+	entries, _ := simplelru.NewLRU(resultCacheSize, nil)
+	return &resultCache{entries: entries}
+}
+
+func (c *resultCache) deliver(jobID string, resBytes []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries.Add(jobID, resultCacheEntry{resBytes: resBytes, added: time.Now()})
+	return nil
+}
+
+// get returns the result of the job or nil if there is none within the TTL.
+func (c *resultCache) get(jobID string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.entries.Get(jobID)
+	if !ok {
+		return nil
+	}
+	entry := v.(resultCacheEntry)
+	if time.Since(entry.added) >= resultCacheTTL {
+		c.entries.Remove(jobID)
+		return nil
+	}
+	return entry.resBytes
+}
+
+// tagResultRouter picks the sink of the longest configured tag prefix a bundle's tag
+// starts with. Results of tags routed to http or not matching any prefix are returned
+// in the response as usual.
+type tagResultRouter struct {
+	// nil for the http type
+	sinks   map[string]resultSink
+	cache   *resultCache
+	writers []kafkaWriter
+}
+
+func newTagResultRouter(handlers map[string]TagResultHandler) *tagResultRouter {
+	router := &tagResultRouter{sinks: make(map[string]resultSink, len(handlers))}
+	for prefix, handler := range handlers {
+		switch handler.Type {
+		case resultHandlerKafka:
+			writer := kafka.NewWriter(kafka.WriterConfig{Brokers: handler.KafkaBrokers, Topic: handler.KafkaTopic})
+			router.writers = append(router.writers, writer)
+			router.sinks[prefix] = &kafkaSink{writer: writer}
+		case resultHandlerCache:
+			if router.cache == nil {
+				router.cache = newResultCache()
+			}
+			router.sinks[prefix] = router.cache
+		default:
+			router.sinks[prefix] = nil
+		}
+	}
+	return router
+}
+
+// sink returns the sink of the given tag or nil if the result goes into the response.
+func (router *tagResultRouter) sink(tag string) resultSink {
+	var match string
+	for prefix := range router.sinks {
+		if strings.HasPrefix(tag, prefix) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return nil
+	}
+	return router.sinks[match]
+}
+
+// close closes the Kafka writers.
+func (router *tagResultRouter) close() error {
+	for _, writer := range router.writers {
+		if err := writer.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverResult hands the result to the sink and answers with 202 and the job ID. If the
+// sink fails, the result is returned in the response instead so the PoW isn't lost.
+func (interc *Interceptor) deliverResult(w http.ResponseWriter, sink resultSink, resBytes []byte, powImplName string, fields logFields) (int, error) {
+	jobID := uuid.New().String()
+	if err := sink.deliver(jobID, resBytes); err != nil {
+		interc.logEntry(levelError, "result_delivery_failed", fields, "unable to deliver PoW result of job %s, returning it in the response: %v\n", jobID, err)
+		return interc.writeAttachRes(w, resBytes, powImplName)
+	}
+	interc.logEntry(levelInfo, "result_delivered", fields, "delivered PoW result as job %s\n", jobID)
+	// can't fail for a struct of a string
+	accepted, _ := json.Marshal(&jobAcceptedRes{JobID: jobID})
+	interc.setResponseHeaders(w)
+	w.Header().Set(headerPoWImpl, powImplName)
+	w.Header().Set(headerResultJobID, jobID)
+	w.WriteHeader(http.StatusAccepted)
+	if _, err := w.Write(accepted); err != nil {
+		return http.StatusInternalServerError, ErrBuildingRes
+	}
+	return http.StatusAccepted, nil
+}
+
+// serveResult serves the cached result of the job in the request path.
+func (interc *Interceptor) serveResult(w http.ResponseWriter, r *http.Request) (int, error) {
+	var resBytes []byte
+	if interc.resultRouter.cache != nil {
+		resBytes = interc.resultRouter.cache.get(strings.TrimPrefix(r.URL.Path, resultsPath))
+	}
+	if resBytes == nil {
+		return http.StatusNotFound, ErrResultNotFound
+	}
+	interc.setResponseHeaders(w)
+	if _, err := w.Write(resBytes); err != nil {
+		return http.StatusInternalServerError, ErrBuildingRes
+	}
+	return http.StatusOK, nil
+}
@@ -0,0 +1,99 @@
+package iota
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/segmentio/kafka-go"
+)
+
+type mockKafkaWriter struct {
+	mu   sync.Mutex
+	msgs []kafka.Message
+	err  error
+}
+
+func (m *mockKafkaWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+	m.msgs = append(m.msgs, msgs...)
+	return nil
+}
+
+func (m *mockKafkaWriter) Close() error { return nil }
+
+func TestTagResultHandler(t *testing.T) {
+	cfg := newConfig()
+	cfg.TagResultHandlers = map[string]TagResultHandler{
+		"HTTP":  {Type: resultHandlerHTTP},
+		"KAFKA": {Type: resultHandlerKafka, KafkaBrokers: []string{"127.0.0.1:9092"}, KafkaTopic: "results"},
+		"CACHE": {Type: resultHandlerCache},
+	}
+	interc, _ := newTestInterceptor(t, cfg)
+	writer := &mockKafkaWriter{}
+	interc.resultRouter.sinks["KAFKA"] = &kafkaSink{writer: writer}
+
+	attach := func(tag string) (*httptest.ResponseRecorder, int) {
+		w := httptest.NewRecorder()
+		status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, bundleTrytes(t, "kerl", testTx(tag, 0))...))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tag, err)
+		}
+		return w, status
+	}
+	jobID := func(w *httptest.ResponseRecorder) string {
+		res := &jobAcceptedRes{}
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil || res.JobID == "" {
+			t.Fatalf("expected a job ID, got %s: %v", w.Body.String(), err)
+		}
+		if header := w.Header().Get(headerResultJobID); header != res.JobID {
+			t.Errorf("expected the job ID header to be %s, got %s", res.JobID, header)
+		}
+		return res.JobID
+	}
+	isResult := func(body []byte) bool {
+		res := &AttachToTangleRes{}
+		return json.Unmarshal(body, res) == nil && len(res.Trytes) == 1
+	}
+
+	for _, tag := range []string{"HTTP", "OTHER"} {
+		if w, status := attach(tag); status != http.StatusOK || !isResult(w.Body.Bytes()) {
+			t.Errorf("%s: expected the result in the response, got %d: %s", tag, status, w.Body.String())
+		}
+	}
+
+	w, status := attach("KAFKA")
+	if status != http.StatusAccepted || w.Code != http.StatusAccepted {
+		t.Fatalf("kafka: expected 202, got %d", status)
+	}
+	id := jobID(w)
+	if len(writer.msgs) != 1 || string(writer.msgs[0].Key) != id || !isResult(writer.msgs[0].Value) {
+		t.Fatalf("kafka: expected the result to be written keyed by the job ID, got %v", writer.msgs)
+	}
+
+	writer.err = errors.New("broker down")
+	if w, status := attach("KAFKA"); status != http.StatusOK || !isResult(w.Body.Bytes()) {
+		t.Errorf("kafka: expected the result in the response if writing fails, got %d: %s", status, w.Body.String())
+	}
+
+	w, status = attach("CACHE")
+	if status != http.StatusAccepted {
+		t.Fatalf("cache: expected 202, got %d", status)
+	}
+	id = jobID(w)
+	w = httptest.NewRecorder()
+	if status, err := interc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, resultsPath+id, nil)); status != http.StatusOK || !isResult(w.Body.Bytes()) {
+		t.Errorf("cache: expected the cached result, got %d: %v %s", status, err, w.Body.String())
+	}
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, resultsPath+"unknown", nil)); status != http.StatusNotFound || err != ErrResultNotFound {
+		t.Errorf("cache: expected 404 for unknown jobs, got %d: %v", status, err)
+	}
+}
@@ -0,0 +1,49 @@
+package iota
+
+import (
+	"strings"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+// tryte offset and size of the tag within the transaction trytes, the obsolete tag isn't
+// normalized as it's part of the bundle essence the client signed
+const (
+	tagOffset = consts.TagTrinaryOffset / 3
+	tagSize   = consts.TagTrinarySize / 3
+)
+
+// normalizeTag uppercases the given tag and replaces characters which aren't trytes with 9.
+func normalizeTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		if (r < 'A' || r > 'Z') && r != '9' {
+			return '9'
+		}
+		return r
+	}, tag)
+}
+
+// normalizeTags normalizes the tag of the given transaction trytes in place and warns about
+// every changed tag, in strict mode the warning is returned instead. Trytes of the wrong
+// length are left for the parser to reject.
+func (interc *Interceptor) normalizeTags(txTrytes []trinary.Trytes) error {
+	for i, trytes := range txTrytes {
+		if len(trytes) != consts.TransactionTrytesSize {
+			continue
+		}
+		original := trytes[tagOffset : tagOffset+tagSize]
+		normalized := normalizeTag(original)
+		if normalized == original {
+			continue
+		}
+		if err := interc.warningToError("normalized tag of transaction at index %d from %s to %s", i, original, normalized); err != nil {
+			return err
+		}
+		txTrytes[i] = trytes[:tagOffset] + normalized + trytes[tagOffset+tagSize:]
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package iota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func TestNormalizeTag(t *testing.T) {
+	tests := map[string]string{
+		"TEST":   "TEST",
+		"test":   "TEST",
+		"TeSt99": "TEST99",
+		"te-st!": "TE9ST9",
+	}
+	for tag, expected := range tests {
+		if normalized := normalizeTag(tag); normalized != expected {
+			t.Errorf("expected %s to be normalized to %s, got %s", tag, expected, normalized)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	trytes := txTrytes(t, "TEST", 0)
+	offset := consts.TagTrinaryOffset / 3
+	lowercase := trytes[:offset] + "test" + trytes[offset+4:]
+
+	for _, normalize := range []bool{false, true} {
+		cfg := newConfig()
+		cfg.NormalizeTags = normalize
+		interc, _ := newTestInterceptor(t, cfg)
+		w := httptest.NewRecorder()
+		status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, lowercase))
+		if !normalize {
+			if status != http.StatusBadRequest || err != ErrBuildingTx {
+				t.Errorf("expected lowercase tags to be rejected without normalization, got %d: %v", status, err)
+			}
+			continue
+		}
+		if status != http.StatusOK {
+			t.Fatalf("expected the normalized bundle to be PoWed, got %d: %v", status, err)
+		}
+		res := &AttachToTangleRes{}
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		tx, err := transaction.AsTransactionObject(res.Trytes[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := trinary.Pad("TEST", consts.TagTrinarySize/3); tx.Tag != expected {
+			t.Errorf("expected the normalized tag %s in the response, got %s", expected, tx.Tag)
+		}
+		if strings.Contains(res.Trytes[0], "test") {
+			t.Error("expected no lowercase trytes in the response")
+		}
+	}
+}
+
+func TestNormalizeTagsObsoleteTag(t *testing.T) {
+	trytes := txTrytes(t, "TEST", 0)
+	offset := consts.ObsoleteTagTrinaryOffset / 3
+	lowercase := trytes[:offset] + "test" + trytes[offset+4:]
+
+	cfg := newConfig()
+	cfg.NormalizeTags = true
+	interc, _ := newTestInterceptor(t, cfg)
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, lowercase))
+	if status != http.StatusBadRequest || err != ErrBuildingTx {
+		t.Errorf("expected a lowercase obsolete tag to stay rejected, got %d: %v", status, err)
+	}
+}
+
+func TestNormalizeTagsStrictMode(t *testing.T) {
+	trytes := txTrytes(t, "TEST", 0)
+	offset := consts.TagTrinaryOffset / 3
+	lowercase := trytes[:offset] + "test" + trytes[offset+4:]
+
+	cfg := newConfig()
+	cfg.NormalizeTags = true
+	cfg.StrictMode = true
+	interc, _ := newTestInterceptor(t, cfg)
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, lowercase))
+	if status != http.StatusBadRequest || errors.Cause(err) != ErrStrictMode {
+		t.Errorf("expected strict mode to reject the normalization, got %d: %v", status, err)
+	}
+
+	status, err = interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, trytes))
+	if status != http.StatusOK {
+		t.Errorf("expected an uppercase tag to pass in strict mode, got %d: %v", status, err)
+	}
+}
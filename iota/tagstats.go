@@ -0,0 +1,68 @@
+package iota
+
+import (
+	"sync"
+
+	"github.com/hashicorp/golang-lru/simplelru"
+)
+
+// length of the tag prefixes the statistics are kept per
+const tagStatsPrefixLength = 3
+
+const statsPath = "/iota/stats"
+
+type tagStat struct {
+	Count      uint64  `json:"count"`
+	TotalPoWMs int64   `json:"total_pow_ms"`
+	TotalMi    float64 `json:"total_mi"`
+}
+
+type statsRes struct {
+	TotalRequests uint64             `json:"total_requests"`
+	TotalErrors   uint64             `json:"total_errors"`
+	TagStats      map[string]tagStat `json:"tag_stats"`
+}
+
+// tagStats counts the attached bundles, their PoW durations and input values per tag prefix.
+// Only the most recently seen prefixes up to the maximum are kept.
+type tagStats struct {
+	mu    sync.Mutex
+	stats *simplelru.LRU
+}
+
+func newTagStats(maxEntries int) *tagStats {
+	// only fails for non positive sizes
+	stats, _ := simplelru.NewLRU(maxEntries, nil)
+	return &tagStats{stats: stats}
+}
+
+func (s *tagStats) record(tag string, powMs int64, inputMi float64) {
+	prefix := tag
+	if len(prefix) > tagStatsPrefixLength {
+		prefix = prefix[:tagStatsPrefixLength]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stat := &tagStat{}
+	if v, ok := s.stats.Get(prefix); ok {
+		stat = v.(*tagStat)
+	} else {
+		s.stats.Add(prefix, stat)
+	}
+	stat.Count++
+	stat.TotalPoWMs += powMs
+	stat.TotalMi += inputMi
+}
+
+// snapshot returns a copy of the statistics per prefix.
+func (s *tagStats) snapshot() map[string]tagStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := make(map[string]tagStat, s.stats.Len())
+	for _, prefix := range s.stats.Keys() {
+		// Peek doesn't update the recentness
+		v, _ := s.stats.Peek(prefix)
+		snapshot[prefix.(string)] = *v.(*tagStat)
+	}
+	return snapshot
+}
@@ -0,0 +1,70 @@
+package iota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTagStats(t *testing.T) {
+	cfg := newConfig()
+	cfg.MaxTagStatsEntries = 3
+	interc, _ := newTestInterceptor(t, cfg)
+
+	for _, tt := range []struct {
+		tag   string
+		value int64
+	}{
+		{"AAAONE", 0},
+		{"AAATWO", 2000000},
+		{"BBB", 0},
+		{"CCC", 1000000},
+	} {
+		bundle := bundleTrytes(t, "kerl", testTx(tt.tag, tt.value), testTx(tt.tag, -tt.value))
+		if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+			t.Fatalf("%s: expected 200, got %d: %v", tt.tag, status, err)
+		}
+	}
+
+	getStats := func() *statsRes {
+		w := httptest.NewRecorder()
+		if status, err := interc.ServeHTTP(w, httptest.NewRequest(http.MethodGet, statsPath, nil)); status != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %v", status, err)
+		}
+		res := &statsRes{}
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	res := getStats()
+	// the stats request itself is counted too
+	if res.TotalRequests != 5 {
+		t.Errorf("expected 5 requests, got %d", res.TotalRequests)
+	}
+	if len(res.TagStats) != 3 {
+		t.Fatalf("expected stats of 3 tag prefixes, got %v", res.TagStats)
+	}
+	if aaa := res.TagStats["AAA"]; aaa.Count != 2 || aaa.TotalMi != 2 || aaa.TotalPoWMs <= 0 {
+		t.Errorf("expected the bundles of both AAA tags to be summed, got %+v", aaa)
+	}
+	if ccc := res.TagStats["CCC"]; ccc.Count != 1 || ccc.TotalMi != 1 {
+		t.Errorf("expected the CCC bundle to be counted, got %+v", ccc)
+	}
+
+	// the least recently seen prefix is evicted
+	bundle := bundleTrytes(t, "kerl", testTx("DDD", 0))
+	interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...))
+	res = getStats()
+	if _, has := res.TagStats["AAA"]; has || len(res.TagStats) != 3 {
+		t.Errorf("expected AAA to be evicted, got %v", res.TagStats)
+	}
+
+	// not served if disabled
+	interc, next := newTestInterceptor(t, newConfig())
+	interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, statsPath, nil))
+	if next.calls != 1 {
+		t.Errorf("expected the request to be forwarded without tag stats, got %d calls", next.calls)
+	}
+}
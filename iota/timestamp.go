@@ -0,0 +1,32 @@
+package iota
+
+import (
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+// correctAttachmentTimestamps sets the attachment timestamp of the given transactions to the
+// given time, resets the bounds to the ones PoW uses and returns the re-serialized trytes.
+func (interc *Interceptor) correctAttachmentTimestamps(txs []transaction.Transaction, now time.Time, fields logFields) ([]trinary.Trytes, error) {
+	attachedAt := now.UnixNano() / int64(time.Millisecond)
+	txTrytes := make([]trinary.Trytes, len(txs))
+	for i := range txs {
+		if txs[i].AttachmentTimestamp != attachedAt {
+			interc.logEntry(levelInfo, "timestamp_corrected", fields, "correcting attachment timestamp of transaction at index %d by %v\n", i,
+				time.Duration(attachedAt-txs[i].AttachmentTimestamp)*time.Millisecond)
+		}
+		txs[i].AttachmentTimestamp = attachedAt
+		txs[i].AttachmentTimestampLowerBound = consts.LowerBoundAttachmentTimestamp
+		txs[i].AttachmentTimestampUpperBound = consts.UpperBoundAttachmentTimestamp
+		trytes, err := transaction.TransactionToTrytes(&txs[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to serialize transaction at index %d", i)
+		}
+		txTrytes[i] = trytes
+	}
+	return txTrytes, nil
+}
@@ -0,0 +1,53 @@
+package iota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+)
+
+func TestCorrectAttachmentTimestamp(t *testing.T) {
+	yearAgo := time.Now().AddDate(-1, 0, 0).UnixNano() / int64(time.Millisecond)
+	tx := testTx("TEST", 0)
+	tx.AttachmentTimestamp = yearAgo
+	tx.AttachmentTimestampLowerBound = yearAgo
+	tx.AttachmentTimestampUpperBound = yearAgo
+	trytes := bundleTrytes(t, defaultBundleHashAlgorithm, tx)[0]
+
+	cfg := newConfig()
+	cfg.CorrectAttachmentTimestamp = true
+	interc, _ := newTestInterceptor(t, cfg)
+	now := time.Now()
+	corrected, err := interc.correctAttachmentTimestamps([]transaction.Transaction{tx}, now, logFields{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	correctedTx, err := transaction.AsTransactionObject(corrected[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if correctedTx.AttachmentTimestamp != now.UnixNano()/int64(time.Millisecond) || correctedTx.AttachmentTimestampLowerBound == yearAgo || correctedTx.AttachmentTimestampUpperBound == yearAgo {
+		t.Errorf("expected the re-serialized trytes to carry the server time, got %+v", correctedTx)
+	}
+
+	w := httptest.NewRecorder()
+	before := time.Now().UnixNano() / int64(time.Millisecond)
+	if status, err := interc.ServeHTTP(w, attachRequest(t, "1.1.1.1:1234", 1, trytes)); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", status, err)
+	}
+	res := &AttachToTangleRes{}
+	if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+		t.Fatal(err)
+	}
+	powedTx, err := transaction.AsTransactionObject(res.Trytes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if powedTx.AttachmentTimestamp < before || powedTx.AttachmentTimestamp > time.Now().UnixNano()/int64(time.Millisecond) {
+		t.Errorf("expected the response to carry the corrected attachment timestamp, got %d", powedTx.AttachmentTimestamp)
+	}
+}
@@ -0,0 +1,121 @@
+package iota
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/mholt/caddy/caddyhttp/httpserver"
+	"github.com/pkg/errors"
+)
+
+const defaultTipAgeCacheTTL = 30 * time.Second
+
+const getTrytesCommand = "getTrytes"
+
+type getTrytesReq struct {
+	Command string         `json:"command"`
+	Hashes  []trinary.Hash `json:"hashes"`
+}
+
+type getTrytesRes struct {
+	Trytes []trinary.Trytes `json:"trytes"`
+}
+
+type tipAgeEntry struct {
+	// zero if the transaction is unknown to IRI
+	attached time.Time
+	fetched  time.Time
+}
+
+// tipAgeChecker rejects trunk and branch transactions which were attached longer ago
+// than the allowed maximum. The attachment times are looked up via getTrytes on the
+// next handler and cached for the configured TTL.
+type tipAgeChecker struct {
+	maxAge time.Duration
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[trinary.Hash]tipAgeEntry
+}
+
+func newTipAgeChecker(maxAge, ttl time.Duration) *tipAgeChecker {
+	return &tipAgeChecker{maxAge: maxAge, ttl: ttl, cache: map[trinary.Hash]tipAgeEntry{}}
+}
+
+// check returns ErrStaleTip if one of the given tips is older than the maximum age.
+// Tips unknown to IRI are not rejected as IRI refuses to attach to them anyway.
+func (c *tipAgeChecker) check(next httpserver.Handler, r *http.Request, tips ...trinary.Hash) error {
+	now := time.Now()
+	attached := make(map[trinary.Hash]time.Time, len(tips))
+	var missing []trinary.Hash
+	queued := map[trinary.Hash]bool{}
+	c.mu.Lock()
+	for _, tip := range tips {
+		entry, ok := c.cache[tip]
+		if ok && now.Sub(entry.fetched) < c.ttl {
+			attached[tip] = entry.attached
+			continue
+		}
+		if !queued[tip] {
+			queued[tip] = true
+			missing = append(missing, tip)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(missing) > 0 {
+		fetched, err := lookupAttachmentTimes(next, r, missing)
+		if err != nil {
+			return err
+		}
+		c.mu.Lock()
+		for hash, entry := range c.cache {
+			if now.Sub(entry.fetched) >= c.ttl {
+				delete(c.cache, hash)
+			}
+		}
+		for i, tip := range missing {
+			c.cache[tip] = tipAgeEntry{attached: fetched[i], fetched: now}
+			attached[tip] = fetched[i]
+		}
+		c.mu.Unlock()
+	}
+
+	for _, tip := range tips {
+		if t := attached[tip]; !t.IsZero() && now.Sub(t) > c.maxAge {
+			return errors.Wrapf(ErrStaleTip, "%s was attached %v ago", tip, now.Sub(t).Truncate(time.Second))
+		}
+	}
+	return nil
+}
+
+// lookupAttachmentTimes fetches the transactions with the given hashes via getTrytes
+// and returns their attachment times in the same order.
+func lookupAttachmentTimes(next httpserver.Handler, r *http.Request, hashes []trinary.Hash) ([]time.Time, error) {
+	trytesRes := &getTrytesRes{}
+	if err := callIRI(r.Context(), next, r, &getTrytesReq{Command: getTrytesCommand, Hashes: hashes}, trytesRes); err != nil {
+		return nil, errors.Wrap(err, "getTrytes failed")
+	}
+	if len(trytesRes.Trytes) != len(hashes) {
+		return nil, errors.Errorf("getTrytes returned %d trytes for %d hashes", len(trytesRes.Trytes), len(hashes))
+	}
+
+	times := make([]time.Time, len(hashes))
+	for i, trytes := range trytesRes.Trytes {
+		tx, err := transaction.AsTransactionObject(trytes)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid transaction trytes in getTrytes response")
+		}
+		switch {
+		case tx.AttachmentTimestamp != 0:
+			times[i] = time.Unix(0, tx.AttachmentTimestamp*int64(time.Millisecond))
+		case tx.Timestamp != 0:
+			// transactions attached before the attachment timestamp was introduced
+			times[i] = time.Unix(int64(tx.Timestamp), 0)
+		}
+	}
+	return times, nil
+}
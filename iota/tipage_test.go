@@ -0,0 +1,176 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+// mockIRI answers getTrytes calls with the registered transactions and
+// unknown hashes with empty transaction trytes like IRI does. Only the transactions
+// in confirmed are confirmed and addresses hold the given balances. getInclusionStates,
+// getBalances, getTransactionsToApprove and broadcastTransactions calls are counted,
+// the latter answered with tips.
+type mockIRI struct {
+	mu               sync.Mutex
+	txs              map[trinary.Hash]trinary.Trytes
+	confirmed        map[trinary.Hash]bool
+	balances         map[trinary.Hash]uint64
+	lookups          int
+	inclusionLookups int
+	balanceLookups   int
+	broadcasts       int
+	tipSelections    int
+	tips             GetTransactionsToApproveRes
+}
+
+func (m *mockIRI) ServeHTTP(w http.ResponseWriter, r *http.Request) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	req := &getTrytesReq{}
+	if err := json.Unmarshal(body, req); err != nil {
+		return http.StatusOK, nil
+	}
+	switch req.Command {
+	case getInclusionStatesCommand:
+		m.inclusionLookups++
+		statesReq := &getInclusionStatesReq{}
+		json.Unmarshal(body, statesReq)
+		res := &getInclusionStatesRes{}
+		for _, hash := range statesReq.Transactions {
+			res.States = append(res.States, m.confirmed[hash])
+		}
+		return writeJSON(w, res)
+	case getBalancesCommand:
+		m.balanceLookups++
+		balancesReq := &getBalancesReq{}
+		json.Unmarshal(body, balancesReq)
+		res := &getBalancesRes{}
+		for _, address := range balancesReq.Addresses {
+			res.Balances = append(res.Balances, strconv.FormatUint(m.balances[address], 10))
+		}
+		return writeJSON(w, res)
+	case broadcastTransactionsCommand:
+		m.broadcasts++
+		return writeJSON(w, struct{}{})
+	case getTransactionsToApproveCommand:
+		m.tipSelections++
+		return writeJSON(w, &m.tips)
+	case getTrytesCommand:
+	default:
+		return http.StatusOK, nil
+	}
+	m.lookups++
+	res := &getTrytesRes{}
+	for _, hash := range req.Hashes {
+		trytes, ok := m.txs[hash]
+		if !ok {
+			trytes = trinary.Pad("", consts.TransactionTrytesSize)
+		}
+		res.Trytes = append(res.Trytes, trytes)
+	}
+	return writeJSON(w, res)
+}
+
+// addTip registers a transaction under the given hash which was attached at the given time.
+func (m *mockIRI) addTip(t *testing.T, hash trinary.Hash, attached time.Time) {
+	tx := testTx("TIP", 0)
+	tx.AttachmentTimestamp = attached.UnixNano() / int64(time.Millisecond)
+	trytes, err := transaction.TransactionToTrytes(&tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.txs[hash] = trytes
+}
+
+func tipAttachRequest(t *testing.T, trunk, branch trinary.Hash) *http.Request {
+	body, err := json.Marshal(&AttachToTangleReq{
+		Command:      attachToTangleCommand,
+		TrunkTxHash:  trunk,
+		BranchTxHash: branch,
+		MWM:          1,
+		Trytes:       []trinary.Trytes{txTrytes(t, "TEST", 0)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+}
+
+func TestMaxTipAge(t *testing.T) {
+	fresh := trinary.Pad("FRESH", consts.HashTrytesSize)
+	stale := trinary.Pad("STALE", consts.HashTrytesSize)
+	unknown := trinary.Pad("UNKNOWN", consts.HashTrytesSize)
+
+	cfg := newConfig()
+	cfg.MaxTipAge = 10 * time.Minute
+	interc, _ := newTestInterceptor(t, cfg)
+	iri := &mockIRI{txs: map[trinary.Hash]trinary.Trytes{}}
+	iri.addTip(t, fresh, time.Now().Add(-time.Minute))
+	iri.addTip(t, stale, time.Now().Add(-time.Hour))
+	interc.Next = iri
+
+	tests := []struct {
+		name          string
+		trunk, branch trinary.Hash
+		status        int
+	}{
+		{"fresh tips", fresh, fresh, http.StatusOK},
+		{"stale trunk", stale, fresh, http.StatusBadRequest},
+		{"stale branch", fresh, stale, http.StatusBadRequest},
+		{"unknown tips", unknown, fresh, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, err := interc.ServeHTTP(httptest.NewRecorder(), tipAttachRequest(t, test.trunk, test.branch))
+			if status != test.status {
+				t.Fatalf("expected status %d, got %d: %v", test.status, status, err)
+			}
+			if status == http.StatusBadRequest && errors.Cause(err) != ErrStaleTip {
+				t.Errorf("expected ErrStaleTip, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTipAgeCache(t *testing.T) {
+	tip := trinary.Pad("TIP", consts.HashTrytesSize)
+	iri := &mockIRI{txs: map[trinary.Hash]trinary.Trytes{}}
+	iri.addTip(t, tip, time.Now())
+
+	checker := newTipAgeChecker(10*time.Minute, time.Minute)
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	for i := 0; i < 3; i++ {
+		if err := checker.check(iri, r, tip, tip); err != nil {
+			t.Fatalf("expected the fresh tip to pass, got %v", err)
+		}
+	}
+	if iri.lookups != 1 {
+		t.Fatalf("expected tips to be looked up once, got %d lookups", iri.lookups)
+	}
+
+	// expire the cached entry instead of waiting for the TTL
+	checker.mu.Lock()
+	entry := checker.cache[tip]
+	entry.fetched = entry.fetched.Add(-time.Minute)
+	checker.cache[tip] = entry
+	checker.mu.Unlock()
+	if err := checker.check(iri, r, tip, tip); err != nil {
+		t.Fatalf("expected the fresh tip to pass, got %v", err)
+	}
+	if iri.lookups != 2 {
+		t.Errorf("expected tips to be looked up again after the TTL, got %d lookups", iri.lookups)
+	}
+}
@@ -0,0 +1,93 @@
+package iota
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iotaledger/iota.go/trinary"
+)
+
+const getTransactionsToApproveCommand = "getTransactionsToApprove"
+
+type GetTransactionsToApproveReq struct {
+	Command   string       `json:"command"`
+	Depth     int          `json:"depth"`
+	Reference trinary.Hash `json:"reference,omitempty"`
+}
+
+type GetTransactionsToApproveRes struct {
+	TrunkTransaction  trinary.Hash `json:"trunkTransaction"`
+	BranchTransaction trinary.Hash `json:"branchTransaction"`
+	Duration          int64        `json:"duration"`
+}
+
+type tipCacheEntry struct {
+	res     *GetTransactionsToApproveRes
+	fetched time.Time
+}
+
+// tipCache keeps the most recent tips IRI selected per depth for the configured TTL,
+// so clients calling getTransactionsToApprove right before attachToTangle don't each
+// cause a tip selection.
+type tipCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[int]tipCacheEntry
+}
+
+func newTipCache(ttl time.Duration) *tipCache {
+	return &tipCache{ttl: ttl, entries: map[int]tipCacheEntry{}}
+}
+
+// get returns the cached tips for the depth or nil if there are none within the TTL.
+func (c *tipCache) get(depth int) *GetTransactionsToApproveRes {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[depth]
+	if !ok || time.Since(entry.fetched) >= c.ttl {
+		return nil
+	}
+	return entry.res
+}
+
+func (c *tipCache) put(depth int, res *GetTransactionsToApproveRes) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[depth] = tipCacheEntry{res: res, fetched: time.Now()}
+}
+
+// invalidate drops the cached tips an attachment used as trunk or branch,
+// as other clients would otherwise attach to the same tips.
+func (c *tipCache) invalidate(trunk, branch trinary.Hash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for depth, entry := range c.entries {
+		if entry.res.TrunkTransaction == trunk || entry.res.BranchTransaction == branch ||
+			entry.res.TrunkTransaction == branch || entry.res.BranchTransaction == trunk {
+			delete(c.entries, depth)
+		}
+	}
+}
+
+// serveTransactionsToApprove answers getTransactionsToApprove from the tip cache and otherwise
+// asks IRI and caches its answer. Calls with a reference transaction aren't cached and calls
+// IRI fails for are forwarded as is so the client receives IRI's error.
+func (interc *Interceptor) serveTransactionsToApprove(w http.ResponseWriter, r *http.Request, contents []byte) (int, error) {
+	req := &GetTransactionsToApproveReq{}
+	if err := json.Unmarshal(contents, req); err != nil || req.Reference != "" {
+		return interc.Next.ServeHTTP(w, r)
+	}
+	if res := interc.tipCache.get(req.Depth); res != nil {
+		return writeJSON(w, res)
+	}
+	res := &GetTransactionsToApproveRes{}
+	if err := callIRI(r.Context(), interc.Next, r, req, res); err != nil {
+		interc.logEntry(levelWarn, "tip_fetch_failed", logFields{RemoteAddr: interc.clientIP(r)}, "unable to fetch tips for the tip cache: %v\n", err)
+		return interc.Next.ServeHTTP(w, r)
+	}
+	interc.tipCache.put(req.Depth, res)
+	return writeJSON(w, res)
+}
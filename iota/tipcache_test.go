@@ -0,0 +1,70 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+)
+
+func tipsRequest(t *testing.T, depth int) *http.Request {
+	body, err := json.Marshal(&GetTransactionsToApproveReq{Command: getTransactionsToApproveCommand, Depth: depth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+}
+
+func TestTipCache(t *testing.T) {
+	cfg := newConfig()
+	cfg.TipCacheTTL = 100 * time.Millisecond
+	interc, _ := newTestInterceptor(t, cfg)
+	// attachRequest uses the null hash as trunk and branch
+	trunk, branch := consts.NullHashTrytes, strings.Repeat("B", 81)
+	iri := &mockIRI{tips: GetTransactionsToApproveRes{TrunkTransaction: trunk, BranchTransaction: branch}}
+	interc.Next = iri
+
+	getTips := func(depth int) *GetTransactionsToApproveRes {
+		w := httptest.NewRecorder()
+		if status, err := interc.ServeHTTP(w, tipsRequest(t, depth)); status != http.StatusOK || err != nil {
+			t.Fatalf("expected 200, got %d: %v", status, err)
+		}
+		res := &GetTransactionsToApproveRes{}
+		if err := json.Unmarshal(w.Body.Bytes(), res); err != nil {
+			t.Fatalf("invalid response %s: %v", w.Body.String(), err)
+		}
+		return res
+	}
+
+	if res := getTips(3); res.TrunkTransaction != trunk || res.BranchTransaction != branch {
+		t.Errorf("expected IRI's tips, got %+v", res)
+	}
+	getTips(3)
+	if iri.tipSelections != 1 {
+		t.Errorf("expected the second call to be answered from the cache, got %d tip selections", iri.tipSelections)
+	}
+	getTips(4)
+	if iri.tipSelections != 2 {
+		t.Errorf("expected other depths not to be answered from the cache, got %d tip selections", iri.tipSelections)
+	}
+
+	time.Sleep(cfg.TipCacheTTL)
+	getTips(3)
+	if iri.tipSelections != 3 {
+		t.Errorf("expected the cache to expire after the TTL, got %d tip selections", iri.tipSelections)
+	}
+
+	// attaching to the cached tips drops them
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "", 0))); status != http.StatusOK || err != nil {
+		t.Fatalf("expected 200, got %d: %v", status, err)
+	}
+	getTips(3)
+	if iri.tipSelections != 4 {
+		t.Errorf("expected the used tips to be dropped from the cache, got %d tip selections", iri.tipSelections)
+	}
+}
@@ -0,0 +1,77 @@
+package iota
+
+import (
+	"sort"
+	"time"
+
+	"github.com/iotaledger/iota.go/transaction"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+const defaultTOFUPinDB = "tofu_pins.db"
+
+var tofuPinBucket = []byte("pins")
+
+// bundlePins pins the bundle hash to the first output address of a bundle the first
+// time the address is seen (trust on first use) and rejects other bundles for it later on.
+type bundlePins struct {
+	db *bolt.DB
+}
+
+func newBundlePins(path string) (*bundlePins, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't open TOFU pin database")
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(tofuPinBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &bundlePins{db: db}, nil
+}
+
+// check pins the bundle's hash to its first output address or returns ErrBundlePinMismatch
+// if a different bundle hash is already pinned to it.
+func (p *bundlePins) check(txs []transaction.Transaction) error {
+	address := firstOutputAddress(txs)
+	if address == "" {
+		return nil
+	}
+	bundleHash := []byte(txs[0].Bundle)
+	return p.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(tofuPinBucket)
+		pinned := b.Get([]byte(address))
+		if pinned == nil {
+			return b.Put([]byte(address), bundleHash)
+		}
+		if string(pinned) != string(bundleHash) {
+			return errors.Wrapf(ErrBundlePinMismatch, "address %s is pinned to bundle %s", address, pinned)
+		}
+		return nil
+	})
+}
+
+func (p *bundlePins) close() error {
+	return p.db.Close()
+}
+
+// firstOutputAddress returns the address of the transaction with the lowest index
+// which doesn't spend tokens.
+func firstOutputAddress(txs []transaction.Transaction) trinary.Hash {
+	sorted := make([]*transaction.Transaction, len(txs))
+	for i := range txs {
+		sorted[i] = &txs[i]
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CurrentIndex < sorted[j].CurrentIndex })
+	for _, tx := range sorted {
+		if tx.Value >= 0 {
+			return tx.Address
+		}
+	}
+	return ""
+}
@@ -0,0 +1,54 @@
+package iota
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func TestTOFUPinMode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "iota-tofu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := newConfig()
+	cfg.TOFUPinMode = true
+	cfg.TOFUPinDB = filepath.Join(dir, "pins.db")
+	interc, _ := newTestInterceptor(t, cfg)
+
+	output := func(tag trinary.Trytes) []trinary.Trytes {
+		tx := testTx(tag, 0)
+		tx.Address = trinary.Pad("OUTPUT", consts.HashTrytesSize)
+		return bundleTrytes(t, "kerl", tx)
+	}
+	first, second := output("FIRST"), output("SECOND")
+
+	for i, trytes := range [][]trinary.Trytes{first, first} {
+		if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, trytes...)); status != http.StatusOK {
+			t.Fatalf("request %d: expected the pinned bundle to be accepted, got %d: %v", i, status, err)
+		}
+	}
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, second...))
+	if status != http.StatusConflict || errors.Cause(err) != ErrBundlePinMismatch {
+		t.Fatalf("expected another bundle for the same output address to be rejected, got %d: %v", status, err)
+	}
+
+	// pins survive restarts
+	if err := interc.bundlePins.close(); err != nil {
+		t.Fatal(err)
+	}
+	interc, _ = newTestInterceptor(t, cfg)
+	defer interc.bundlePins.close()
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, second...)); status != http.StatusConflict {
+		t.Errorf("expected the pin to be persisted, got %d: %v", status, err)
+	}
+}
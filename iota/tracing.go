@@ -0,0 +1,73 @@
+package iota
+
+import (
+	"strings"
+
+	"github.com/openzipkin/zipkin-go"
+	zipkinhttp "github.com/openzipkin/zipkin-go/reporter/http"
+	"github.com/pkg/errors"
+	"go.opencensus.io/exporter/jaeger"
+	oczipkin "go.opencensus.io/exporter/zipkin"
+	"go.opencensus.io/trace"
+)
+
+const (
+	traceExporterZipkin = "zipkin"
+	traceExporterJaeger = "jaeger"
+	// not supported as its exporter's dependencies aren't available
+	traceExporterStackdriver = "stackdriver"
+)
+
+// endpoints the exporters send spans to if none is configured
+var defaultTraceEndpoints = map[string]string{
+	traceExporterZipkin: "http://localhost:9411/api/v2/spans",
+	traceExporterJaeger: "http://localhost:14268/api/traces",
+}
+
+const (
+	traceServiceName = "iotacaddy"
+	attachSpanName   = "iota.attachToTangle"
+)
+
+// attributes of the attachToTangle spans
+const (
+	attrClientIP    = "iota.client_ip"
+	attrMWM         = "iota.mwm"
+	attrBundleTxs   = "iota.bundle_txs"
+	attrBundleHash  = "iota.bundle_hash"
+	attrValueBundle = "iota.value_bundle"
+	attrPoWImpl     = "iota.pow_impl"
+	attrPoWDuration = "iota.pow_duration_ms"
+	attrPoWPrefetch = "iota.pow_prefetched"
+	attrHTTPStatus  = "http.status_code"
+)
+
+// newTraceExporter creates the OpenCensus exporter of the given kind sending spans to the
+// given endpoint and returns it along with a function flushing and closing it.
+func newTraceExporter(kind, endpoint string) (trace.Exporter, func(), error) {
+	if endpoint == "" {
+		endpoint = defaultTraceEndpoints[kind]
+	}
+	switch kind {
+	case traceExporterZipkin:
+		localEndpoint, err := zipkin.NewEndpoint(traceServiceName, "")
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to create the zipkin endpoint")
+		}
+		reporter := zipkinhttp.NewReporter(endpoint)
+		return oczipkin.NewExporter(reporter, localEndpoint), func() { reporter.Close() }, nil
+	case traceExporterJaeger:
+		opts := jaeger.Options{Process: jaeger.Process{ServiceName: traceServiceName}}
+		if strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+			opts.CollectorEndpoint = endpoint
+		} else {
+			opts.AgentEndpoint = endpoint
+		}
+		exporter, err := jaeger.NewExporter(opts)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "unable to create the jaeger exporter")
+		}
+		return exporter, exporter.Flush, nil
+	}
+	return nil, nil, errors.Errorf("unknown OpenCensus exporter '%s', use zipkin or jaeger", kind)
+}
@@ -0,0 +1,84 @@
+package iota
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"go.opencensus.io/trace"
+)
+
+// memExporter keeps the exported spans in memory.
+type memExporter struct {
+	mu    sync.Mutex
+	spans []*trace.SpanData
+}
+
+func (e *memExporter) ExportSpan(s *trace.SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, s)
+}
+
+func TestOpenCensusTrace(t *testing.T) {
+	exporter := &memExporter{}
+	trace.RegisterExporter(exporter)
+	defer trace.UnregisterExporter(exporter)
+
+	cfg := newConfig()
+	cfg.OpenCensusTrace = true
+	interc, _ := newTestInterceptor(t, cfg)
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %v", status, err)
+	}
+	if status, _ := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", cfg.MaxMWM+1, txTrytes(t, "TEST", 0))); status != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", status)
+	}
+	// requests which aren't intercepted have no span
+	interc.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if len(exporter.spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(exporter.spans))
+	}
+	powed := exporter.spans[0]
+	if powed.Name != attachSpanName {
+		t.Errorf("expected span name %s, got %s", attachSpanName, powed.Name)
+	}
+	expected := map[string]interface{}{
+		attrClientIP:    "1.1.1.1",
+		attrMWM:         int64(1),
+		attrBundleTxs:   int64(1),
+		attrValueBundle: false,
+		attrPoWImpl:     "Null",
+		attrHTTPStatus:  int64(http.StatusOK),
+	}
+	for attr, value := range expected {
+		if powed.Attributes[attr] != value {
+			t.Errorf("expected attribute %s to be %v, got %v", attr, value, powed.Attributes[attr])
+		}
+	}
+	for _, attr := range []string{attrBundleHash, attrPoWDuration} {
+		if _, has := powed.Attributes[attr]; !has {
+			t.Errorf("expected attribute %s to be set", attr)
+		}
+	}
+
+	rejected := exporter.spans[1]
+	if rejected.Attributes[attrHTTPStatus] != int64(http.StatusBadRequest) || rejected.Status.Code == trace.StatusCodeOK {
+		t.Errorf("expected the rejected request's span to carry the error, got %v and status %+v", rejected.Attributes, rejected.Status)
+	}
+}
+
+func TestNewTraceExporter(t *testing.T) {
+	for _, kind := range []string{traceExporterZipkin, traceExporterJaeger} {
+		exporter, closeExporter, err := newTraceExporter(kind, "")
+		if err != nil || exporter == nil {
+			t.Fatalf("%s: expected an exporter, got %v", kind, err)
+		}
+		closeExporter()
+	}
+	if _, _, err := newTraceExporter("carrier-pigeon", ""); err == nil {
+		t.Error("expected unknown exporters to fail")
+	}
+}
@@ -0,0 +1,150 @@
+package iota
+
+import (
+	"fmt"
+
+	"github.com/iotaledger/iota.go/consts"
+)
+
+// the highest MWM possible, a hash can't have more trailing zero trits
+const maxPossibleMWM = consts.HashTrinarySize
+
+// ConfigError describes an option which is invalid on its own or in combination with other options.
+type ConfigError struct {
+	Option string
+	Reason string
+}
+
+func (e *ConfigError) Error() string {
+	return fmt.Sprintf("invalid iota option %s: %s", e.Option, e.Reason)
+}
+
+// validateConfig checks the parsed options for values and combinations which can't work.
+func validateConfig(cfg *Config) error {
+	if cfg.MaxMWM < 1 || cfg.MaxMWM > maxPossibleMWM {
+		return &ConfigError{"max MWM", fmt.Sprintf("must be between 1 and %d, got %d", maxPossibleMWM, cfg.MaxMWM)}
+	}
+	if cfg.MinMWM > cfg.MaxMWM {
+		return &ConfigError{"mwm", fmt.Sprintf("the min MWM must not exceed the max MWM of %d, got %d", cfg.MaxMWM, cfg.MinMWM)}
+	}
+	if cfg.MinMWMValueBundle > cfg.MaxMWM {
+		return &ConfigError{"min_mwm_value_bundle", fmt.Sprintf("must not exceed the max MWM of %d, got %d", cfg.MaxMWM, cfg.MinMWMValueBundle)}
+	}
+	if cfg.MWMThreshold > cfg.MaxMWM {
+		return &ConfigError{"mwm_validation_mode", fmt.Sprintf("threshold must not exceed the max MWM of %d, got %d", cfg.MaxMWM, cfg.MWMThreshold)}
+	}
+	if cfg.MaxTxInBundle < 1 {
+		return &ConfigError{"max txs per bundle", fmt.Sprintf("must be at least 1, got %d", cfg.MaxTxInBundle)}
+	}
+	if cfg.MinTxInBundle > cfg.MaxTxInBundle {
+		return &ConfigError{"min_tx_per_bundle", fmt.Sprintf("must not exceed the max txs per bundle of %d, got %d", cfg.MaxTxInBundle, cfg.MinTxInBundle)}
+	}
+	if cfg.PoWConcurrency < 1 {
+		return &ConfigError{"pow_concurrency", fmt.Sprintf("must be at least 1, got %d", cfg.PoWConcurrency)}
+	}
+	if cfg.PoWTimeout < 0 {
+		return &ConfigError{"powtimeout", fmt.Sprintf("must be greater than 0, got %v", cfg.PoWTimeout)}
+	}
+	// 0 disables the limit
+	if cfg.MaxPendingQueueBytes < 0 {
+		return &ConfigError{"max_pending_queue_bytes", fmt.Sprintf("must not be negative, got %d", cfg.MaxPendingQueueBytes)}
+	}
+	if cfg.PartialBundleRecovery && cfg.ValidateBundleHash {
+		return &ConfigError{"partial_bundle_recovery", "skipped transactions change the bundle hash, requires validate_bundle_hash false"}
+	}
+	if cfg.MaxTipAge > 0 && cfg.TipAgeCacheTTL <= 0 {
+		return &ConfigError{"tip_age_cache_ttl_ms", "must be greater than 0"}
+	}
+	if cfg.RequireConfirmedBranch && cfg.ConfirmationCacheTTL <= 0 {
+		return &ConfigError{"confirmation_cache_ttl_ms", "must be greater than 0"}
+	}
+	if (cfg.IRIClientCertFile == "") != (cfg.IRIClientKeyFile == "") {
+		return &ConfigError{"iota_client_cert_file", "requires iota_client_key_file and vice versa"}
+	}
+	if cfg.IRIClientCertFile != "" && cfg.IRIUpstream == "" {
+		return &ConfigError{"iota_client_cert_file", "requires iri_upstream to be set"}
+	}
+	if (cfg.BackendMaxIdleConns > 0 || cfg.BackendMaxConnsPerHost > 0 || cfg.BackendIdleConnTimeout > 0) && cfg.IRIUpstream == "" {
+		return &ConfigError{"backend_max_idle_conns", "requires iri_upstream to be set, the same applies to backend_max_conns_per_host and backend_idle_conn_timeout_sec"}
+	}
+	if cfg.CheckBalances && cfg.BalanceCheckTimeout <= 0 {
+		return &ConfigError{"balance_check_timeout_ms", "must be greater than 0"}
+	}
+	if cfg.CheckBalances && cfg.BalanceCacheTTL <= 0 {
+		return &ConfigError{"balance_cache_ttl_ms", "must be greater than 0"}
+	}
+	if cfg.RebroadcastInterval > 0 && cfg.RebroadcastMaxAttempts < 1 {
+		return &ConfigError{"auto_rebroadcast_max_attempts", "must be at least 1"}
+	}
+	if cfg.InjectCoordinatorTips && cfg.CoordinatorTrunk == "" {
+		return &ConfigError{"inject_coordinator_tips", "requires coordinator_tips to be set"}
+	}
+	if cfg.SignResponses && cfg.SigningKeyFile == "" {
+		return &ConfigError{"ed25519_sign_responses", "requires ed25519_key_file to be set"}
+	}
+	if cfg.ValidateNetworkMagic && cfg.NetworkMagicByte < 0 {
+		return &ConfigError{"validate_network_magic", "requires network_magic_byte to be set"}
+	}
+	if cfg.LogHashFormat == logHashFormatShort && cfg.LogHashLength != defaultLogHashLength {
+		return &ConfigError{"log_hash_truncate_length", "can't be combined with log_hash_format short"}
+	}
+	if cfg.RateLimiterMaxIPs > 0 && cfg.RateLimit <= 0 {
+		return &ConfigError{"rate_limiter_max_ips", "requires rate_limit to be set"}
+	}
+	if cfg.PoWDegradedWebhook != "" && cfg.PoWMinHashesPerSec <= 0 {
+		return &ConfigError{"pow_degraded_webhook", "requires pow_min_hashes_per_sec to be set"}
+	}
+	if cfg.LightNodeMode {
+		for _, option := range []struct {
+			name string
+			set  bool
+		}{
+			{"iri_upstream", cfg.IRIUpstream != ""},
+			{"max_tip_age_minutes", cfg.MaxTipAge > 0},
+			{"require_confirmed_branch", cfg.RequireConfirmedBranch},
+			{"check_balances", cfg.CheckBalances},
+			{"auto_rebroadcast_interval_sec", cfg.RebroadcastInterval > 0},
+			{"intercept_store", cfg.InterceptStore},
+			{"tipcache", cfg.TipCacheTTL > 0},
+			{"min_confirmations_before_reattach", cfg.MinConfirmationsBeforeReattach > 0},
+			{"dedup_readonly_commands", len(cfg.DedupCommands) > 0},
+			{"inject_coordinator_tips", cfg.InjectCoordinatorTips},
+		} {
+			if option.set {
+				return &ConfigError{option.name, "needs IRI and can't be combined with light_node_mode"}
+			}
+		}
+	}
+	if (cfg.EmbedMetadataField == "") != (cfg.MetadataTemplate == "") {
+		return &ConfigError{"embed_metadata_field", "embed_metadata_field and metadata_template must be set together"}
+	}
+	if (cfg.NATSURL == "") != (cfg.NATSSubject == "") {
+		return &ConfigError{"nats_url", "nats_url and nats_subject must be set together"}
+	}
+	if cfg.ResultBackupCompress && cfg.ResultBackupDir == "" {
+		return &ConfigError{"result_backup_compress", "requires result_backup_dir to be set"}
+	}
+	if (cfg.CloudWatchLogGroup == "") != (cfg.CloudWatchLogStream == "") {
+		return &ConfigError{"cloudwatch_log_group", "cloudwatch_log_group and cloudwatch_log_stream must be set together"}
+	}
+	if cfg.AWSRegion != "" && cfg.CloudWatchLogGroup == "" {
+		return &ConfigError{"aws_region", "requires cloudwatch_log_group to be set"}
+	}
+	if cfg.MultiSigRequired > len(cfg.MultiSigKeys) {
+		return &ConfigError{"multi_sig_required", fmt.Sprintf("requires at least %d multi_sig_keys, got %d", cfg.MultiSigRequired, len(cfg.MultiSigKeys))}
+	}
+	keys := make(map[string]bool, len(cfg.MultiSigKeys))
+	for _, key := range cfg.MultiSigKeys {
+		if keys[key] {
+			return &ConfigError{"multi_sig_keys", "keys must be different"}
+		}
+		keys[key] = true
+	}
+	if len(cfg.TrustedProxies) > 0 && cfg.ProxyDepth > 0 {
+		return &ConfigError{"trustedproxy", "can't be combined with proxy_depth"}
+	}
+	if cfg.LogKeepDays > 0 && (cfg.LogFile == logFileNone || cfg.LogFile == logFileStdout) {
+		return &ConfigError{"logkeep", "requires file logging"}
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package iota
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+)
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		option string
+	}{
+		{"defaults", func(cfg *Config) {}, ""},
+		{"max MWM of 0", func(cfg *Config) { cfg.MaxMWM = 0 }, "max MWM"},
+		{"max MWM above the hash size", func(cfg *Config) { cfg.MaxMWM = maxPossibleMWM + 1 }, "max MWM"},
+		{"MWM threshold above max MWM", func(cfg *Config) { cfg.MWMThreshold = cfg.MaxMWM + 1 }, "mwm_validation_mode"},
+		{"no txs per bundle", func(cfg *Config) { cfg.MaxTxInBundle = 0 }, "max txs per bundle"},
+		{"min txs above max txs", func(cfg *Config) { cfg.MinTxInBundle = cfg.MaxTxInBundle + 1 }, "min_tx_per_bundle"},
+		{"negative pending queue bytes", func(cfg *Config) { cfg.MaxPendingQueueBytes = -1 }, "max_pending_queue_bytes"},
+		{"partial recovery with bundle hash validation", func(cfg *Config) { cfg.PartialBundleRecovery = true }, "partial_bundle_recovery"},
+		{"partial recovery without bundle hash validation", func(cfg *Config) {
+			cfg.PartialBundleRecovery = true
+			cfg.ValidateBundleHash = false
+		}, ""},
+		{"tip age without cache TTL", func(cfg *Config) {
+			cfg.MaxTipAge = time.Minute
+			cfg.TipAgeCacheTTL = 0
+		}, "tip_age_cache_ttl_ms"},
+		{"confirmed branch without cache TTL", func(cfg *Config) {
+			cfg.RequireConfirmedBranch = true
+			cfg.ConfirmationCacheTTL = 0
+		}, "confirmation_cache_ttl_ms"},
+		{"client cert without key", func(cfg *Config) {
+			cfg.IRIUpstream = "https://127.0.0.1:14265"
+			cfg.IRIClientCertFile = "client.pem"
+		}, "iota_client_cert_file"},
+		{"client cert without upstream", func(cfg *Config) {
+			cfg.IRIClientCertFile = "client.pem"
+			cfg.IRIClientKeyFile = "client.key"
+		}, "iota_client_cert_file"},
+		{"backend pool without upstream", func(cfg *Config) {
+			cfg.BackendMaxConnsPerHost = 10
+		}, "backend_max_idle_conns"},
+		{"balance check without timeout", func(cfg *Config) {
+			cfg.CheckBalances = true
+			cfg.BalanceCheckTimeout = 0
+		}, "balance_check_timeout_ms"},
+		{"balance check without cache TTL", func(cfg *Config) {
+			cfg.CheckBalances = true
+			cfg.BalanceCacheTTL = 0
+		}, "balance_cache_ttl_ms"},
+		{"rebroadcast without attempts", func(cfg *Config) {
+			cfg.RebroadcastInterval = time.Minute
+			cfg.RebroadcastMaxAttempts = 0
+		}, "auto_rebroadcast_max_attempts"},
+		{"tip injection without tips", func(cfg *Config) { cfg.InjectCoordinatorTips = true }, "inject_coordinator_tips"},
+		{"signing without key", func(cfg *Config) { cfg.SignResponses = true }, "ed25519_sign_responses"},
+		{"network magic without byte", func(cfg *Config) { cfg.ValidateNetworkMagic = true }, "validate_network_magic"},
+		{"short log hash format with truncation", func(cfg *Config) {
+			cfg.LogHashFormat = logHashFormatShort
+			cfg.LogHashLength = 16
+		}, "log_hash_truncate_length"},
+		{"rate limiter max IPs without rate limit", func(cfg *Config) { cfg.RateLimiterMaxIPs = 3 }, "rate_limiter_max_ips"},
+		{"degradation webhook without minimum", func(cfg *Config) { cfg.PoWDegradedWebhook = "http://127.0.0.1/alert" }, "pow_degraded_webhook"},
+		{"NATS URL without subject", func(cfg *Config) { cfg.NATSURL = "nats://127.0.0.1:4222" }, "nats_url"},
+		{"compressed result backup without dir", func(cfg *Config) { cfg.ResultBackupCompress = true }, "result_backup_compress"},
+		{"CloudWatch stream without group", func(cfg *Config) { cfg.CloudWatchLogStream = "pow" }, "cloudwatch_log_group"},
+		{"AWS region without CloudWatch", func(cfg *Config) { cfg.AWSRegion = "eu-central-1" }, "aws_region"},
+		{"more required authorizations than keys", func(cfg *Config) {
+			cfg.MultiSigRequired = 3
+			cfg.MultiSigKeys = []string{"a", "b"}
+		}, "multi_sig_required"},
+		{"duplicate authorization keys", func(cfg *Config) {
+			cfg.MultiSigRequired = 2
+			cfg.MultiSigKeys = []string{"a", "a"}
+		}, "multi_sig_keys"},
+		{"min MWM above the max", func(cfg *Config) { cfg.MinMWM = cfg.MaxMWM + 1 }, "mwm"},
+		{"min MWM of value bundles above the max", func(cfg *Config) { cfg.MinMWMValueBundle = cfg.MaxMWM + 1 }, "min_mwm_value_bundle"},
+		{"no PoW workers", func(cfg *Config) { cfg.PoWConcurrency = 0 }, "pow_concurrency"},
+		{"negative PoW timeout", func(cfg *Config) { cfg.PoWTimeout = -time.Second }, "powtimeout"},
+		{"kept log days without file logging", func(cfg *Config) {
+			cfg.LogFile = logFileStdout
+			cfg.LogKeepDays = 7
+		}, "logkeep"},
+		{"light node mode with balance checks", func(cfg *Config) {
+			cfg.LightNodeMode = true
+			cfg.CheckBalances = true
+		}, "check_balances"},
+		{"light node mode with confirmation checks before re-attachments", func(cfg *Config) {
+			cfg.LightNodeMode = true
+			cfg.MinConfirmationsBeforeReattach = 1
+		}, "min_confirmations_before_reattach"},
+		{"light node mode with deduplicated commands", func(cfg *Config) {
+			cfg.LightNodeMode = true
+			cfg.DedupCommands = []string{"getNodeInfo"}
+		}, "dedup_readonly_commands"},
+		{"light node mode with coordinator tip injection", func(cfg *Config) {
+			cfg.LightNodeMode = true
+			cfg.InjectCoordinatorTips = true
+			cfg.CoordinatorTrunk, cfg.CoordinatorBranch = consts.NullHashTrytes, consts.NullHashTrytes
+		}, "inject_coordinator_tips"},
+		{"trusted proxies with proxy depth", func(cfg *Config) {
+			_, network, _ := net.ParseCIDR("10.0.0.0/8")
+			cfg.TrustedProxies, cfg.ProxyDepth = []*net.IPNet{network}, 1
+		}, "trustedproxy"},
+		{"metadata field without template", func(cfg *Config) { cfg.EmbedMetadataField = metadataFieldTag }, "embed_metadata_field"},
+	}
+	for _, test := range tests {
+		cfg := newConfig()
+		test.modify(cfg)
+		err := validateConfig(cfg)
+		if test.option == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", test.name, err)
+			}
+			continue
+		}
+		cfgErr, ok := err.(*ConfigError)
+		if !ok {
+			t.Errorf("%s: expected a ConfigError, got %v", test.name, err)
+			continue
+		}
+		if cfgErr.Option != test.option {
+			t.Errorf("%s: expected error for option %s, got %v", test.name, test.option, cfgErr)
+		}
+	}
+}
+
+func TestSetupConfigError(t *testing.T) {
+	for _, input := range []string{
+		`iota 0 20 {
+			logfile -
+		}`,
+		`iota 14 0 {
+			logfile -
+		}`,
+		`iota 14 20 {
+			logfile -
+			inject_coordinator_tips true
+		}`,
+		`iota 14 20 {
+			logfile -
+			nats_subject iota.pow
+		}`,
+	} {
+		_, shutdown, err := setupTest(t, input)
+		shutdown()
+		if _, ok := err.(*ConfigError); !ok {
+			t.Errorf("expected a ConfigError for %q, got %v", input, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/iotaledger/iota.go/units"
+)
+
+const valueAlertWebhookTimeout = 5 * time.Second
+
+type valueAlert struct {
+	BundleHash trinary.Hash `json:"bundle_hash"`
+	TotalMi    float64      `json:"total_mi"`
+	Timestamp  int64        `json:"timestamp"`
+	RemoteIP   string       `json:"remote_ip"`
+}
+
+// alertHighValue POSTs the bundle to the alert webhook if its inputs move more than the
+// configured threshold. The webhook is called in the background and failures are only
+// logged, so the response isn't delayed.
+func (interc *Interceptor) alertHighValue(bundleHash trinary.Hash, inputValue int64, ip string) {
+	if -inputValue <= interc.Config.AlertWebhookThreshold {
+		return
+	}
+	alert, _ := json.Marshal(&valueAlert{
+		BundleHash: bundleHash,
+		TotalMi:    units.ConvertUnits(float64(-inputValue), units.I, units.Mi),
+		Timestamp:  time.Now().Unix(),
+		RemoteIP:   ip,
+	})
+	fields := logFields{RemoteAddr: ip, BundleHash: bundleHash}
+	go func() {
+		client := &http.Client{Timeout: valueAlertWebhookTimeout}
+		res, err := client.Post(interc.Config.AlertWebhook, contentTypeJSON, bytes.NewReader(alert))
+		if err != nil {
+			interc.logEntry(levelError, "value_alert_failed", fields, "unable to call value alert webhook for bundle %s: %v\n", interc.logHash(bundleHash), err)
+			return
+		}
+		res.Body.Close()
+		if res.StatusCode >= 300 {
+			interc.logEntry(levelError, "value_alert_failed", fields, "value alert webhook returned status %d for bundle %s\n", res.StatusCode, interc.logHash(bundleHash))
+		}
+	}()
+}
@@ -0,0 +1,93 @@
+package iota
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// safeBuffer is a bytes.Buffer which can be read while being logged to from other goroutines.
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestAlertWebhook(t *testing.T) {
+	alerts := make(chan *valueAlert, 2)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		alert := &valueAlert{}
+		if err := json.NewDecoder(r.Body).Decode(alert); err != nil {
+			t.Errorf("invalid webhook body: %v", err)
+		}
+		alerts <- alert
+	}))
+	defer webhook.Close()
+
+	cfg := newConfig()
+	cfg.AlertWebhook = webhook.URL
+	cfg.AlertWebhookThreshold = 1000000
+	interc, _ := newTestInterceptor(t, cfg)
+	for _, value := range []int64{1000000, 2000000} {
+		bundle := bundleTrytes(t, "kerl", testTx("TEST", value), testTx("TEST", -value))
+		if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+			t.Fatalf("%di: expected 200, got %d: %v", value, status, err)
+		}
+	}
+
+	select {
+	case alert := <-alerts:
+		if alert.TotalMi != 2 || alert.RemoteIP != "1.1.1.1" || alert.BundleHash == "" || alert.Timestamp == 0 {
+			t.Errorf("unexpected alert %+v", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the webhook to be called")
+	}
+	select {
+	case alert := <-alerts:
+		t.Errorf("expected only the bundle above the threshold to be alerted, got %+v", alert)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAlertWebhookFailure(t *testing.T) {
+	var buf safeBuffer
+	origLogger := logger
+	logger = log.New(&buf, "", 0)
+	defer func() { logger = origLogger }()
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	webhook.Close()
+	cfg := newConfig()
+	cfg.AlertWebhook = webhook.URL
+	cfg.AlertWebhookThreshold = 1
+	interc, _ := newTestInterceptor(t, cfg)
+	bundle := bundleTrytes(t, "kerl", testTx("TEST", 10), testTx("TEST", -10))
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, bundle...)); status != http.StatusOK {
+		t.Fatalf("expected the failing webhook not to affect the response, got %d: %v", status, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(buf.String(), "unable to call value alert webhook") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the failed delivery to be logged, got:\n%s", buf.String())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,35 @@
+package iota
+
+import (
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/iotaledger/iota.go/pow"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+const defaultWorkerRestartDelay = time.Second
+
+// amount of recovered PoW panics, exported as iotacaddy_worker_panics_total
+var workerPanics uint64
+
+// safeDoPoW does the PoW of the bundle and turns panics of the PoW implementation, e.g. in
+// a native binding, into errors. After a panic it waits for the restart delay before
+// returning so the caller's PoW slot isn't handed to the next job right away.
+func (interc *Interceptor) safeDoPoW(trunk, branch trinary.Hash, txTrytes []trinary.Trytes, mwm uint64, fn pow.ProofOfWorkFunc) (powed []trinary.Trytes, err error) {
+	restartDelay := interc.Config.WorkerRestartDelay
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		total := atomic.AddUint64(&workerPanics, 1)
+		workerPanicsTotal.Inc()
+		interc.logEntry(levelError, "pow_panic", logFields{}, "PoW panicked, restarting the worker in %v (iotacaddy_worker_panics_total %d): %v\n%s", restartDelay, total, r, debug.Stack())
+		time.Sleep(restartDelay)
+		powed, err = nil, errors.Errorf("PoW panicked: %v", r)
+	}()
+	return pow.DoPoW(trunk, branch, txTrytes, mwm, fn)
+}
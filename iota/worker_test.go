@@ -0,0 +1,55 @@
+package iota
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iotaledger/iota.go/consts"
+	"github.com/iotaledger/iota.go/trinary"
+	"github.com/pkg/errors"
+)
+
+func TestWorkerPanicRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	origLogger := logger
+	logger = log.New(&buf, "", 0)
+	defer func() { logger = origLogger }()
+
+	var calls int32
+	panickingPoW := func(trytes trinary.Trytes, mwm int, parallelism ...int) (trinary.Trytes, error) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			panic("broken binding")
+		}
+		return consts.NullNonceTrytes, nil
+	}
+	cfg := newConfig()
+	cfg.WorkerRestartDelay = 50 * time.Millisecond
+	interc, _ := newTestInterceptor(t, cfg)
+	interc.powFn = panickingPoW
+	before := atomic.LoadUint64(&workerPanics)
+
+	s := time.Now()
+	status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0)))
+	if status != http.StatusInternalServerError || errors.Cause(err) != ErrExecutingProofOfWork {
+		t.Errorf("expected the panic to fail the request with 500, got %d: %v", status, err)
+	}
+	if elapsed := time.Since(s); elapsed < cfg.WorkerRestartDelay {
+		t.Errorf("expected the worker to restart after %v, took %v", cfg.WorkerRestartDelay, elapsed)
+	}
+	if panics := atomic.LoadUint64(&workerPanics) - before; panics != 1 {
+		t.Errorf("expected 1 recorded panic, got %d", panics)
+	}
+	if !strings.Contains(buf.String(), "broken binding") || !strings.Contains(buf.String(), "goroutine") {
+		t.Errorf("expected the panic and its stack trace to be logged, got:\n%s", buf.String())
+	}
+
+	if status, err := interc.ServeHTTP(httptest.NewRecorder(), attachRequest(t, "1.1.1.1:1234", 1, txTrytes(t, "TEST", 0))); status != http.StatusOK {
+		t.Errorf("expected the restarted worker to succeed, got %d: %v", status, err)
+	}
+}